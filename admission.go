@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// A checkpoint or restore holding (or waiting for) part of the worker's capacity
+type AdmissionTicket struct {
+	Id        uint64     `json:"id"`
+	Service   string     `json:"service"`
+	Operation string     `json:"operation"`
+	Weight    int        `json:"weight"`
+	Priority  int        `json:"priority"`
+	Enqueued  time.Time  `json:"enqueued"`
+	Admitted  *time.Time `json:"admitted,omitempty"`
+	Position  int        `json:"position,omitempty"`
+	ready     chan struct{}
+}
+
+type AdmissionStatus struct {
+	Capacity int                `json:"capacity"`
+	InUse    int                `json:"in_use"`
+	Mode     string             `json:"mode"`
+	Running  []*AdmissionTicket `json:"running"`
+	Queued   []*AdmissionTicket `json:"queued"`
+}
+
+// Worker-level limit on concurrent checkpoints/restores, weighted by cpu_budget.
+// The head of the queue is always admitted first so heavy requests are never starved.
+type admissionController struct {
+	mu       sync.Mutex
+	capacity int
+	mode     string
+	inUse    int
+	nextId   uint64
+	running  []*AdmissionTicket
+	queue    []*AdmissionTicket
+}
+
+var admission = newAdmissionController(envInt("ADMISSION_CAPACITY", runtime.NumCPU()), envString("ADMISSION_QUEUE", "fifo"))
+
+func newAdmissionController(capacity int, mode string) *admissionController {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if mode != "priority" {
+		mode = "fifo"
+	}
+	return &admissionController{capacity: capacity, mode: mode}
+}
+
+// Weight of an operation from its cpu_budget, ff_daemon's default budget is medium
+func cpuBudgetWeight(cpuBudget string) int {
+	switch cpuBudget {
+	case "low":
+		return 1
+	case "high":
+		return 4
+	default:
+		return 2
+	}
+}
+
+// Wait until the operation is admitted or ctx is done; the ticket must be released afterwards
+func (a *admissionController) acquire(ctx context.Context, service string, operation string, weight int, priority int) (*AdmissionTicket, error) {
+	a.mu.Lock()
+	if weight < 1 {
+		weight = 1
+	}
+	if weight > a.capacity {
+		weight = a.capacity
+	}
+	a.nextId++
+	ticket := &AdmissionTicket{Id: a.nextId, Service: service, Operation: operation, Weight: weight, Priority: priority, Enqueued: time.Now(), ready: make(chan struct{})}
+	a.queue = append(a.queue, ticket)
+	if a.mode == "priority" {
+		// Stable, so equal priorities keep their arrival order
+		sort.SliceStable(a.queue, func(i, j int) bool { return a.queue[i].Priority > a.queue[j].Priority })
+	}
+	a.dispatch()
+	a.mu.Unlock()
+
+	select {
+	case <-ticket.ready:
+	default:
+		logger.Info("Operation queued for admission", zap.String("containerName", service), zap.String("operation", operation), zap.Int("weight", weight))
+	}
+
+	select {
+	case <-ticket.ready:
+		return ticket, nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		select {
+		case <-ticket.ready:
+			// Admitted while giving up, hand the capacity back
+			a.releaseLocked(ticket)
+		default:
+			a.removeQueued(ticket)
+			a.dispatch()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (a *admissionController) release(ticket *AdmissionTicket) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.releaseLocked(ticket)
+}
+
+func (a *admissionController) releaseLocked(ticket *AdmissionTicket) {
+	for i, t := range a.running {
+		if t == ticket {
+			a.running = append(a.running[:i], a.running[i+1:]...)
+			a.inUse -= ticket.Weight
+			break
+		}
+	}
+	a.dispatch()
+}
+
+// Admit queued tickets in order while they fit, must be called with a.mu held
+func (a *admissionController) dispatch() {
+	for len(a.queue) > 0 && a.inUse+a.queue[0].Weight <= a.capacity {
+		ticket := a.queue[0]
+		a.queue = a.queue[1:]
+		a.inUse += ticket.Weight
+		now := time.Now()
+		ticket.Admitted = &now
+		a.running = append(a.running, ticket)
+		close(ticket.ready)
+	}
+}
+
+func (a *admissionController) removeQueued(ticket *AdmissionTicket) {
+	for i, t := range a.queue {
+		if t == ticket {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (a *admissionController) status(service string) AdmissionStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	status := AdmissionStatus{Capacity: a.capacity, InUse: a.inUse, Mode: a.mode, Running: []*AdmissionTicket{}, Queued: []*AdmissionTicket{}}
+	for _, t := range a.running {
+		if service == "" || t.Service == service {
+			ticket := *t
+			status.Running = append(status.Running, &ticket)
+		}
+	}
+	for i, t := range a.queue {
+		if service == "" || t.Service == service {
+			ticket := *t
+			ticket.Position = i + 1
+			status.Queued = append(status.Queued, &ticket)
+		}
+	}
+	return status
+}
+
+func getAdmissionHandler(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, admission.status(c.Query("service")))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCpuBudgetWeight(t *testing.T) {
+	tests := []struct {
+		cpuBudget string
+		want      int
+	}{
+		{"low", 1},
+		{"medium", 2},
+		{"", 2},
+		{"high", 4},
+		{"unknown", 2},
+	}
+	for _, tt := range tests {
+		if got := cpuBudgetWeight(tt.cpuBudget); got != tt.want {
+			t.Errorf("cpuBudgetWeight(%q) = %d, want %d", tt.cpuBudget, got, tt.want)
+		}
+	}
+}
+
+// Queue an acquire in the background and wait until it shows in the queue, admitted tickets are sent on admitted
+func enqueue(t *testing.T, a *admissionController, operation string, weight int, priority int, admitted chan<- *AdmissionTicket) {
+	t.Helper()
+	queued := len(a.status("").Queued)
+	go func() {
+		ticket, err := a.acquire(context.Background(), "svc", operation, weight, priority)
+		if err == nil {
+			admitted <- ticket
+		}
+	}()
+	waitAdmission(t, func() bool { return len(a.status("").Queued) == queued+1 })
+}
+
+func waitAdmission(t *testing.T, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("admission did not reach the expected state")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionOrder(t *testing.T) {
+	type request struct {
+		operation string
+		priority  int
+	}
+	tests := []struct {
+		name     string
+		mode     string
+		requests []request
+		want     []string
+	}{
+		{"fifo ignores priorities", "fifo", []request{{"a", 0}, {"b", 5}, {"c", 1}}, []string{"a", "b", "c"}},
+		{"priority first", "priority", []request{{"a", 0}, {"b", 5}, {"c", 1}}, []string{"b", "c", "a"}},
+		{"equal priorities keep arrival order", "priority", []request{{"a", 1}, {"b", 1}, {"c", 2}}, []string{"c", "a", "b"}},
+		{"unknown mode is fifo", "lifo", []request{{"a", 0}, {"b", 5}}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmissionController(1, tt.mode)
+			current, err := a.acquire(context.Background(), "svc", "blocker", 1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			admitted := make(chan *AdmissionTicket, len(tt.requests))
+			for _, r := range tt.requests {
+				enqueue(t, a, r.operation, 1, r.priority, admitted)
+			}
+			for _, want := range tt.want {
+				a.release(current)
+				select {
+				case current = <-admitted:
+				case <-time.After(2 * time.Second):
+					t.Fatalf("%s was never admitted", want)
+				}
+				if current.Operation != want {
+					t.Fatalf("admitted %s, want %s", current.Operation, want)
+				}
+			}
+			a.release(current)
+			if status := a.status(""); status.InUse != 0 || len(status.Running) != 0 {
+				t.Errorf("capacity not handed back: %+v", status)
+			}
+		})
+	}
+}
+
+func TestAdmissionWeights(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacity   int
+		weight     int
+		wantWeight int
+	}{
+		{"zero counts as one", 4, 0, 1},
+		{"negative counts as one", 4, -3, 1},
+		{"within capacity", 4, 2, 2},
+		{"clamped to capacity", 4, 10, 4},
+		{"capacity at least one", 0, 2, 1},
+	}
+	for _, tt := range tests {
+		a := newAdmissionController(tt.capacity, "fifo")
+		ticket, err := a.acquire(context.Background(), "svc", "op", tt.weight, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ticket.Weight != tt.wantWeight || a.status("").InUse != tt.wantWeight {
+			t.Errorf("%s: weight %d, in use %d, want %d", tt.name, ticket.Weight, a.status("").InUse, tt.wantWeight)
+		}
+		a.release(ticket)
+	}
+}
+
+// A heavy request at the head of the queue is not overtaken by lighter ones that would fit
+func TestAdmissionHeadOfLine(t *testing.T) {
+	a := newAdmissionController(4, "fifo")
+	running, err := a.acquire(context.Background(), "svc", "running", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan *AdmissionTicket, 2)
+	enqueue(t, a, "heavy", 4, 0, admitted)
+	enqueue(t, a, "light", 1, 0, admitted)
+	select {
+	case ticket := <-admitted:
+		t.Fatalf("%s admitted while the head of the queue waits", ticket.Operation)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	a.release(running)
+	heavy := <-admitted
+	if heavy.Operation != "heavy" {
+		t.Fatalf("admitted %s, want heavy", heavy.Operation)
+	}
+	a.release(heavy)
+	if light := <-admitted; light.Operation != "light" {
+		t.Fatalf("admitted %s, want light", light.Operation)
+	}
+}
+
+func TestAdmissionCanceledWhileQueued(t *testing.T) {
+	a := newAdmissionController(1, "fifo")
+	running, err := a.acquire(context.Background(), "svc", "running", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := a.acquire(ctx, "svc", "canceled", 1, 0)
+		errs <- err
+	}()
+	waitAdmission(t, func() bool { return len(a.status("").Queued) == 1 })
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("acquire returned %v, want context.Canceled", err)
+	}
+	if status := a.status(""); len(status.Queued) != 0 || status.InUse != 1 {
+		t.Fatalf("canceled ticket left behind: %+v", status)
+	}
+	a.release(running)
+	if status := a.status(""); status.InUse != 0 {
+		t.Fatalf("capacity not handed back: %+v", status)
+	}
+}
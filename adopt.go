@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+)
+
+// Containers started with other tooling are subscribed automatically when they carry
+// cm_controller.adopt=true, found at startup and then through the docker events stream
+const adoptLabel = "cm_controller.adopt"
+
+// Port ff_daemon listens on inside the container, 7878 when not set
+const daemonPortLabel = "cm_controller.daemon_port"
+
+// Docker restart policy given to an adopted container: no, always, unless-stopped or on-failure[:max retries]
+const restartPolicyLabel = "cm_controller.restart_policy"
+
+// Opt-in, otherwise anyone able to start a labeled container gets it managed by the controller
+var autoAdopt = envBool("AUTO_ADOPT", false)
+
+// ff_daemon usually starts a little after its container, so adoption waits for it
+var adoptRetry = RetryPolicy{
+	Retries:    envInt("ADOPT_RETRY_ATTEMPTS", 10),
+	Backoff:    envDuration("ADOPT_RETRY_BACKOFF", time.Second),
+	MaxBackoff: envDuration("ADOPT_RETRY_MAX_BACKOFF", 10*time.Second),
+}
+
+var eventsReconnect = envDuration("DOCKER_EVENTS_RECONNECT", 5*time.Second)
+
+// Containers being adopted, so a container listed and seen in the events is adopted once
+var adopting = make(map[string]bool)
+var adoptingMu sync.Mutex
+
+// Time of the last labeled container listing, the events stream picks up from there
+var adoptListedAt time.Time
+
+// Adopt the running labeled containers that are not subscribed yet
+func adoptLabeledContainers() {
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.Error(err))
+		return
+	}
+	adoptListedAt = time.Now()
+	var containers []types.Container
+	err = retryDocker(context.Background(), "docker_list", "", func() error {
+		var err error
+		containers, err = cli.ContainerList(context.Background(), types.ContainerListOptions{Filters: filters.NewArgs(filters.Arg("label", adoptLabel+"=true"))})
+		return err
+	})
+	if err != nil {
+		logger.Error("Error listing containers to adopt", zap.Error(err))
+		return
+	}
+	for _, c := range containers {
+		if len(c.Names) == 0 || isSubscribed(strings.TrimPrefix(c.Names[0], "/")) {
+			continue
+		}
+		go adoptContainer(c.ID, strings.TrimPrefix(c.Names[0], "/"), c.Labels)
+	}
+}
+
+func adoptContainer(containerId string, containerName string, labels map[string]string) {
+	adoptingMu.Lock()
+	if adopting[containerId] {
+		adoptingMu.Unlock()
+		return
+	}
+	adopting[containerId] = true
+	adoptingMu.Unlock()
+	defer func() {
+		adoptingMu.Lock()
+		delete(adopting, containerId)
+		adoptingMu.Unlock()
+	}()
+
+	logger.Info("Adopting labeled container", zap.String("containerName", containerName), zap.String("containerId", containerId))
+	_, err := adoptRetry.do(context.Background(), "adopt", containerName, func() (bool, error) {
+		_, err := subscribeContainer(containerId)
+		return errors.Is(err, errDaemonUnreachable), err
+	})
+	if errors.Is(err, errAlreadySubscribed) {
+		return
+	}
+	if err != nil {
+		logger.Error("Error adopting container", zap.String("containerName", containerName), zap.Error(err))
+		return
+	}
+	if policy := labels[restartPolicyLabel]; policy != "" {
+		if err := setRestartPolicy(containerName, policy); err != nil {
+			logger.Error("Error setting restart policy", zap.String("containerName", containerName), zap.String("restartPolicy", policy), zap.Error(err))
+		}
+	}
+	logger.Info("Container adopted", zap.String("containerName", containerName))
+}
+
+func parseRestartPolicy(policy string) (container.RestartPolicy, error) {
+	name, maxRetries, hasMax := strings.Cut(policy, ":")
+	switch name {
+	case "no", "always", "unless-stopped":
+		if hasMax {
+			return container.RestartPolicy{}, errors.New("only on-failure takes a maximum retry count")
+		}
+		return container.RestartPolicy{Name: name}, nil
+	case "on-failure":
+		restartPolicy := container.RestartPolicy{Name: name}
+		if hasMax {
+			count, err := strconv.Atoi(maxRetries)
+			if err != nil || count < 0 {
+				return container.RestartPolicy{}, errors.New("invalid maximum retry count " + maxRetries)
+			}
+			restartPolicy.MaximumRetryCount = count
+		}
+		return restartPolicy, nil
+	}
+	return container.RestartPolicy{}, errors.New("unknown restart policy " + policy)
+}
+
+func setRestartPolicy(containerName string, policy string) error {
+	restartPolicy, err := parseRestartPolicy(policy)
+	if err != nil {
+		return err
+	}
+	cli, err := newDockerClient()
+	if err != nil {
+		return err
+	}
+	return retryDocker(context.Background(), "docker_update", containerName, func() error {
+		_, err := cli.ContainerUpdate(context.Background(), containerName, container.UpdateConfig{RestartPolicy: restartPolicy})
+		return err
+	})
+}
+
+// Follow container starts of labeled containers, reconnecting (and replaying what was missed) when the stream breaks
+func watchDockerEvents() {
+	since := adoptListedAt
+	for {
+		cli, err := newDockerClient()
+		if err != nil {
+			logger.Error("Error creating docker client", zap.Error(err))
+			time.Sleep(eventsReconnect)
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		options := types.EventsOptions{Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("event", "start"),
+			filters.Arg("label", adoptLabel+"=true"),
+		)}
+		if !since.IsZero() {
+			options.Since = strconv.FormatInt(since.Unix(), 10)
+		}
+		messages, errs := cli.Events(ctx, options)
+		logger.Debug("Watching docker events for containers to adopt")
+	stream:
+		for {
+			select {
+			case message := <-messages:
+				since = time.Unix(message.Time, 0)
+				go adoptContainer(message.Actor.ID, message.Actor.Attributes["name"], message.Actor.Attributes)
+			case err := <-errs:
+				logger.Error("Docker events stream ended", zap.Error(err))
+				break stream
+			}
+		}
+		cancel()
+		time.Sleep(eventsReconnect)
+	}
+}
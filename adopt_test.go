@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    container.RestartPolicy
+		wantErr bool
+	}{
+		{"no", container.RestartPolicy{Name: "no"}, false},
+		{"always", container.RestartPolicy{Name: "always"}, false},
+		{"unless-stopped", container.RestartPolicy{Name: "unless-stopped"}, false},
+		{"on-failure", container.RestartPolicy{Name: "on-failure"}, false},
+		{"on-failure:5", container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5}, false},
+		{"on-failure:0", container.RestartPolicy{Name: "on-failure"}, false},
+		{"on-failure:-1", container.RestartPolicy{}, true},
+		{"on-failure:many", container.RestartPolicy{}, true},
+		{"always:3", container.RestartPolicy{}, true},
+		{"sometimes", container.RestartPolicy{}, true},
+		{"", container.RestartPolicy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRestartPolicy(tt.policy)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q: parseRestartPolicy() = %+v, %v, want %+v, error %v", tt.policy, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAutoAdoptDefault(t *testing.T) {
+	if autoAdopt {
+		t.Error("labeled containers adopted without AUTO_ADOPT")
+	}
+}
+
+func TestAdoptLabeledContainers(t *testing.T) {
+	sim := useSimulation(t)
+	previous := adoptRetry
+	adoptRetry = RetryPolicy{Retries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	t.Cleanup(func() { adoptRetry = previous })
+	runs := [][]string{
+		{"--name", "adopt-policy", "-p", "17911:7878", "--label", adoptLabel + "=true", "--label", restartPolicyLabel + "=on-failure:3"},
+		{"--name", "adopt-bad-policy", "-p", "17912:7878", "--label", adoptLabel + "=true", "--label", restartPolicyLabel + "=sometimes"},
+		{"--name", "adopt-unlabeled", "-p", "17913:7878"},
+		{"--name", "adopt-false", "-p", "17914:7878", "--label", adoptLabel + "=false"},
+	}
+	for _, args := range runs {
+		if _, err := sim.run(append(append([]string{"docker", "run", "-d"}, args...), "img")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adoptLabeledContainers()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !isSubscribed("adopt-policy") || !isSubscribed("adopt-bad-policy") || adoptingCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("labeled containers not adopted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, name := range []string{"adopt-unlabeled", "adopt-false"} {
+		if isSubscribed(name) {
+			t.Errorf("%s adopted", name)
+		}
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	// An invalid policy is logged, the container is adopted without one
+	if restart := sim.containers["adopt-policy"].Restart; restart != (container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}) {
+		t.Errorf("adopt-policy restart policy %+v", restart)
+	}
+	if restart := sim.containers["adopt-bad-policy"].Restart; restart.Name != "" {
+		t.Errorf("adopt-bad-policy restart policy %+v", restart)
+	}
+}
+
+func adoptingCount() int {
+	adoptingMu.Lock()
+	defer adoptingMu.Unlock()
+	return len(adopting)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const redacted = "[REDACTED]"
+
+// One mutating API call, as written to the audit log
+type AuditEntry struct {
+	Time       time.Time       `json:"time"`
+	RequestId  string          `json:"request_id"`
+	Operation  string          `json:"operation"`
+	Caller     string          `json:"caller"`
+	Address    string          `json:"address"`
+	UserAgent  string          `json:"user_agent"`
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	Service    string          `json:"service"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Status     int             `json:"status"`
+	Result     string          `json:"result"`
+	Message    string          `json:"message,omitempty"`
+	DurationMs float64         `json:"duration_ms"`
+}
+
+var auditPath = envString("AUDIT_LOG", "audit.log")
+var auditMu sync.Mutex
+
+// Body keys and env names whose values never reach the audit log
+var secretPattern = regexp.MustCompile(`(?i)pass|secret|token|key|credential|auth`)
+
+// Response writer keeping a copy of the body so the result message can be audited
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < 4096 {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware recording the call to the audit log once the handler is done
+func audit(operation string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestId := c.GetHeader("X-Request-ID")
+		if requestId == "" {
+			b := make([]byte, 8)
+			rand.Read(b)
+			requestId = hex.EncodeToString(b)
+		}
+		c.Header("X-Request-ID", requestId)
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		entry := AuditEntry{
+			Time:       start,
+			RequestId:  requestId,
+			Operation:  operation,
+			Caller:     auditCaller(c),
+			Address:    c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.RequestURI(),
+			Service:    auditService(c, body),
+			Body:       redactBody(body),
+			Status:     writer.Status(),
+			Result:     "success",
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if entry.Status >= 400 {
+			entry.Result = "failure"
+		}
+		var resp map[string]interface{}
+		if json.Unmarshal(writer.body.Bytes(), &resp) == nil {
+			if msg, ok := resp["error"].(string); ok {
+				entry.Message = redactSecrets(msg)
+			} else if msg, ok := resp["message"].(string); ok {
+				entry.Message = redactSecrets(msg)
+			}
+		}
+		writeAuditEntry(entry)
+	}
+}
+
+// Caller identity: explicit header first, then basic auth user
+func auditCaller(c *gin.Context) string {
+	if caller := c.GetHeader("X-Caller-Id"); caller != "" {
+		return caller
+	}
+	if user, _, ok := c.Request.BasicAuth(); ok {
+		return user
+	}
+	return "anonymous"
+}
+
+func auditService(c *gin.Context, body []byte) string {
+	if name := c.Param("name"); name != "" {
+		return name
+	}
+	if name := c.Query("container_name"); name != "" {
+		return name
+	}
+	var startBody struct {
+		ContainerName string `json:"container_name"`
+	}
+	json.Unmarshal(body, &startBody)
+	return startBody.ContainerName
+}
+
+// Copy of a json body with secret looking values replaced
+func redactBody(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return json.RawMessage(strconv.Quote(redacted))
+	}
+	redactedBody, err := json.Marshal(redactValue("", value))
+	if err != nil {
+		return nil
+	}
+	return redactedBody
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = redactValue(k, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(key, item)
+		}
+		return v
+	case string:
+		if key == "envs" {
+			return redactEnv(v)
+		}
+		v = redactSecrets(v)
+		if secretPattern.MatchString(key) && v != "" {
+			return redacted
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// A KEY=VALUE env with secret values replaced. The whole value is hidden when the name looks secret or
+// when it is a secret value, even one too short to be redacted from free text.
+func redactEnv(env string) string {
+	name, value, ok := strings.Cut(env, "=")
+	if ok && value != "" && (secretPattern.MatchString(name) || isSecretValue(value)) {
+		return name + "=" + redacted
+	}
+	return redactSecrets(env)
+}
+
+func writeAuditEntry(entry AuditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("Error encoding audit entry", zap.Error(err))
+		return
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	file, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.Error("Error opening audit log", zap.Error(err))
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		logger.Error("Error writing audit log", zap.Error(err))
+	}
+}
+
+// Parse an RFC3339 time or a duration back from now ("1h")
+func parseSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func getAuditHandler(c *gin.Context) {
+	var since, until time.Time
+	var err error
+	if value := c.Query("since"); value != "" {
+		if since, err = parseSince(value); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid since value"})
+			return
+		}
+	}
+	if value := c.Query("until"); value != "" {
+		if until, err = parseSince(value); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid until value"})
+			return
+		}
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid limit value"})
+		return
+	}
+	service := c.Query("service")
+	operation := c.Query("operation")
+
+	auditMu.Lock()
+	file, err := os.Open(auditPath)
+	if err != nil {
+		auditMu.Unlock()
+		if os.IsNotExist(err) {
+			c.IndentedJSON(http.StatusOK, []AuditEntry{})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Cannot read audit log"})
+		return
+	}
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if (service != "" && entry.Service != service) || (operation != "" && entry.Operation != operation) {
+			continue
+		}
+		if (!since.IsZero() && entry.Time.Before(since)) || (!until.IsZero() && entry.Time.After(until)) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	file.Close()
+	auditMu.Unlock()
+
+	// Keep the most recent entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	c.IndentedJSON(http.StatusOK, entries)
+}
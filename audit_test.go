@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactValue(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"secret looking keys", `{"password":"p","api_token":"t","Auth":"a","image_url":"s3://b"}`,
+			`{"password":"[REDACTED]","api_token":"[REDACTED]","Auth":"[REDACTED]","image_url":"s3://b"}`},
+		{"empty values stay empty", `{"passphrase":""}`, `{"passphrase":""}`},
+		{"non string values are kept", `{"token":5,"secret":true}`, `{"token":5,"secret":true}`},
+		{"env values of secret looking names", `{"envs":["AWS_SECRET_ACCESS_KEY=x","PATH=/bin","NOVALUE"]}`,
+			`{"envs":["AWS_SECRET_ACCESS_KEY=[REDACTED]","PATH=/bin","NOVALUE"]}`},
+		{"nested objects", `{"members":{"svc":{"passphrase_file":"/f","image_url":"s3://b/svc"}}}`,
+			`{"members":{"svc":{"passphrase_file":"[REDACTED]","image_url":"s3://b/svc"}}}`},
+		{"lists under a secret key", `{"keys":["a","b"]}`, `{"keys":["[REDACTED]","[REDACTED]"]}`},
+		{"stored secret values anywhere", `{"image_url":"s3://hunter22@b","envs":["URL=http://hunter22"]}`,
+			`{"image_url":"s3://[REDACTED]@b","envs":["URL=http://[REDACTED]"]}`},
+		{"short secret values in envs", `{"envs":["PIN=abc","MODE=abcd"],"app_args":"abc"}`,
+			`{"envs":["PIN=[REDACTED]","MODE=abcd"],"app_args":"abc"}`},
+	}
+	withRedactedValues(t, "hunter22", "abc")
+	for _, tt := range tests {
+		var body, want interface{}
+		if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := redactValue("", body); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: redactValue() = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	previousPath := auditPath
+	auditPath = filepath.Join(t.TempDir(), "audit.log")
+	t.Cleanup(func() { auditPath = previousPath })
+	r := gin.New()
+	r.POST("/cm_controller/v1/run/:name", audit("run"), func(c *gin.Context) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no service name " + c.Param("name") + " found!"})
+	})
+	r.POST("/cm_controller/v1/start", audit("start"), func(c *gin.Context) {
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "started"})
+	})
+	r.GET("/cm_controller/v1/audit", getAuditHandler)
+
+	requests := []struct {
+		path   string
+		body   string
+		caller string
+	}{
+		{"/run/a", `{"passphrase":"p"}`, "alice"},
+		{"/start", `{"container_name":"b","envs":["TOKEN=t"]}`, ""},
+	}
+	for _, request := range requests {
+		req := httptest.NewRequest("POST", "/cm_controller/v1"+request.path, strings.NewReader(request.body))
+		req.Header.Set("X-Caller-Id", request.caller)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Header().Get("X-Request-ID") == "" {
+			t.Errorf("%s: no request id", request.path)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []AuditEntry
+	}{
+		{"", []AuditEntry{
+			{Operation: "run", Caller: "alice", Service: "a", Body: json.RawMessage(`{"passphrase":"[REDACTED]"}`), Status: 400, Result: "failure", Message: "no service name a found!"},
+			{Operation: "start", Caller: "anonymous", Service: "b", Body: json.RawMessage(`{"container_name":"b","envs":["TOKEN=[REDACTED]"]}`), Status: 200, Result: "success", Message: "started"},
+		}},
+		{"?service=b", []AuditEntry{{Operation: "start", Service: "b"}}},
+		{"?operation=run", []AuditEntry{{Operation: "run", Service: "a"}}},
+		{"?limit=1", []AuditEntry{{Operation: "start", Service: "b"}}},
+		{"?since=1h", []AuditEntry{{Operation: "run"}, {Operation: "start"}}},
+		{"?until=1h", []AuditEntry{}},
+	}
+	for _, tt := range tests {
+		code, entries := getAudit(t, r, tt.query)
+		if code != http.StatusOK || len(entries) != len(tt.want) {
+			t.Errorf("%q: code %d, %d entries, want %d", tt.query, code, len(entries), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			got := entries[i]
+			if got.Operation != want.Operation || (want.Service != "" && got.Service != want.Service) {
+				t.Errorf("%q: entry %d %+v, want %+v", tt.query, i, got, want)
+			}
+			if want.Status == 0 {
+				continue
+			}
+			if got.Caller != want.Caller || got.Status != want.Status || got.Result != want.Result || got.Message != want.Message || !sameJSON(got.Body, want.Body) {
+				t.Errorf("%q: entry %d %+v, want %+v", tt.query, i, got, want)
+			}
+		}
+	}
+	if code, _ := getAudit(t, r, "?since=yesterday"); code != http.StatusBadRequest {
+		t.Errorf("invalid since: code %d", code)
+	}
+}
+
+func getAudit(t *testing.T, r *gin.Engine, query string) (int, []AuditEntry) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/audit"+query, nil))
+	var entries []AuditEntry
+	json.Unmarshal(w.Body.Bytes(), &entries)
+	return w.Code, entries
+}
+
+func sameJSON(a json.RawMessage, b json.RawMessage) bool {
+	var va, vb interface{}
+	json.Unmarshal(a, &va)
+	json.Unmarshal(b, &vb)
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,668 @@
+// cmctl is a command-line client for the cm_controller REST API.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: cmctl [--addr host:port|unix:///path] [-o json|table] <command> [options] [args]
+
+Commands:
+  start -f <start.json>                 start a service's container
+  run <name> [-f <run.json>] [--timeout <d>]         run or restore the application
+  checkpoint <name> [-f <chk.json>] [--timeout <d>]  checkpoint the application
+  stop <name>                           stop a service's container
+  remove <name>                         remove a service's container
+  subscribe <name|id>                   adopt a running container
+  unsubscribe <name>
+  list [--watch] [--interval <d>]       list all services
+  info <name> [--watch] [--interval <d>]
+  resources <name> -f <resources.json>  update a live container's resource limits
+  stats [name]                          resource usage of one or all services
+  logs <name> [--follow] [--tail <n>] [--since <t>]
+  images                                list local images
+  pull <image>                          pull an image
+  preflight [--image <image>]           host checkpoint/restore readiness
+  audit [service] [--operation <op>] [--since <t>] [--until <t>] [--limit <n>]
+                                        audit log of mutating calls
+  log-level [level]                     show or change the controller's log level
+  groups                                list service groups
+  group <name>                          a group and its last checkpoint
+  group-set <name> <member>...          create a group or replace its members
+  group-delete <name>
+  group-checkpoint <name> [-f <group_chk.json>] [--timeout <d>]
+                                        checkpoint a group's members as a consistent set
+  desired [name]                        desired state of one or all declaratively managed services
+  desired-set <name> -f <spec.json>     set a service's desired state
+  desired-delete <name>                 stop managing a service declaratively, its container is left as it is
+  templates [service]                   default run/checkpoint parameters of the worker, or of one service
+  templates-set [service] -f <templates.json>
+                                        replace the worker's or a service's templates
+  templates-delete [service]
+  secrets                               list the secrets, never their values
+  secret-set <name> -f <file>           store a secret, its value read from the file (or stdin with "-f -")
+  secret-delete <name>
+  reconcile                             orphaned service dirs and containers, port and status drift
+  reconcile-apply <cleanup|adopt> [--kind <kind>] [--service <name>]
+                                        apply an action to the matching findings
+  webhooks [service]                    list webhooks, of one service or all
+  webhook-add -f <webhook.json>         register a webhook, its secret is only shown here
+  webhook-delete <id>
+  webhook-deadletter [id] [--limit <n>] deliveries given up after all retries
+
+Bodies given with -f are read from the file, or from stdin with "-f -".
+The controller address defaults to $CMCTL_ADDR or 127.0.0.1:8787, use unix:///path/to.sock for its unix socket.
+`
+
+type client struct {
+	base   string
+	output string
+}
+
+type service struct {
+	ContainerName string `json:"container_name"`
+	ContainerId   string `json:"container_id"`
+	Image         string `json:"image"`
+	DaemonPort    string `json:"daemon_port"`
+	Status        string `json:"status"`
+	Health        struct {
+		Ready bool   `json:"ready"`
+		Live  bool   `json:"live"`
+		Error string `json:"error"`
+	} `json:"health"`
+}
+
+func main() {
+	addr := os.Getenv("CMCTL_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:8787"
+	}
+	global := flag.NewFlagSet("cmctl", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	global.StringVar(&addr, "addr", addr, "controller address")
+	output := global.String("o", "table", "output format (json or table)")
+	global.Parse(os.Args[1:])
+	if global.NArg() == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+	if *output != "json" && *output != "table" {
+		fmt.Fprintln(os.Stderr, "cmctl: output must be json or table")
+		os.Exit(2)
+	}
+	if strings.HasPrefix(addr, "unix://") {
+		socketPath := strings.TrimPrefix(addr, "unix://")
+		// The controller's local socket (--unix-socket), the host part of the URLs is ignored
+		http.DefaultTransport = &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}}
+		addr = "http://unix"
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	c := &client{base: strings.TrimSuffix(addr, "/") + "/cm_controller/v1", output: *output}
+	if err := c.dispatch(global.Arg(0), global.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "cmctl:", err)
+		os.Exit(1)
+	}
+}
+
+func (c *client) dispatch(command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	bodyFile := fs.String("f", "", "request body file (- for stdin)")
+	watch := fs.Bool("watch", false, "refresh until interrupted")
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval of --watch")
+	image := fs.String("image", "", "image name")
+	follow := fs.Bool("follow", false, "stream new log output")
+	tail := fs.String("tail", "all", "number of log lines from the end")
+	since := fs.String("since", "", "show logs or audit entries since a timestamp or duration")
+	timeout := fs.String("timeout", "", "deadline of a run or checkpoint (e.g. 90s)")
+	until := fs.String("until", "", "show audit entries until a timestamp or duration")
+	limit := fs.String("limit", "", "number of most recent audit entries or dead letters")
+	operation := fs.String("operation", "", "audited operation (e.g. run)")
+	kind := fs.String("kind", "", "reconcile finding kind (e.g. dir_without_container)")
+	serviceName := fs.String("service", "", "service of the reconcile findings")
+	// Options may come before or after the name
+	fs.Parse(args)
+	name := fs.Arg(0)
+	if fs.NArg() > 0 {
+		fs.Parse(fs.Args()[1:])
+	}
+
+	switch command {
+	case "start":
+		return c.send("POST", "/start", *bodyFile, true)
+	case "run", "checkpoint":
+		if name == "" {
+			return errors.New(command + " needs a service name")
+		}
+		path := "/" + command + "/" + url.PathEscape(name)
+		if *timeout != "" {
+			path += "?timeout=" + url.QueryEscape(*timeout)
+		}
+		return c.send("POST", path, *bodyFile, false)
+	case "stop", "unsubscribe":
+		if name == "" {
+			return errors.New(command + " needs a service name")
+		}
+		return c.send("POST", "/"+command+"/"+url.PathEscape(name), "", false)
+	case "remove":
+		if name == "" {
+			return errors.New("remove needs a service name")
+		}
+		return c.send("DELETE", "/remove/"+url.PathEscape(name), "", false)
+	case "subscribe":
+		if name == "" {
+			return errors.New("subscribe needs a container name or id")
+		}
+		query := url.Values{"container_name": {name}}
+		return c.send("POST", "/subscribe?"+query.Encode(), "", false)
+	case "list":
+		return c.repeat(*watch, *interval, c.list)
+	case "info":
+		if name == "" {
+			return errors.New("info needs a service name")
+		}
+		return c.repeat(*watch, *interval, func() error { return c.info(name) })
+	case "resources":
+		if name == "" {
+			return errors.New("resources needs a service name")
+		}
+		return c.send("PATCH", "/service/"+url.PathEscape(name)+"/resources", *bodyFile, true)
+	case "stats":
+		if name == "" {
+			return c.get("/stats")
+		}
+		return c.get("/service/" + url.PathEscape(name) + "/stats")
+	case "logs":
+		if name == "" {
+			return errors.New("logs needs a service name")
+		}
+		query := url.Values{"tail": {*tail}, "follow": {fmt.Sprint(*follow)}}
+		if *since != "" {
+			query.Set("since", *since)
+		}
+		return c.stream("/service/" + url.PathEscape(name) + "/logs?" + query.Encode())
+	case "images":
+		return c.get("/images")
+	case "pull":
+		if name == "" {
+			return errors.New("pull needs an image name")
+		}
+		body, _ := json.Marshal(map[string]string{"image": name})
+		return c.streamPost("/images/pull", body)
+	case "preflight":
+		path := "/node/preflight"
+		if *image != "" {
+			path += "?image=" + url.QueryEscape(*image)
+		}
+		return c.get(path)
+	case "audit":
+		query := url.Values{}
+		for key, value := range map[string]string{"service": name, "operation": *operation, "since": *since, "until": *until, "limit": *limit} {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+		return c.audit("/audit?" + query.Encode())
+	case "webhooks":
+		if name == "" {
+			return c.get("/webhooks")
+		}
+		return c.get("/webhooks?" + url.Values{"service": {name}}.Encode())
+	case "webhook-add":
+		return c.send("POST", "/webhooks", *bodyFile, true)
+	case "webhook-delete":
+		if name == "" {
+			return errors.New("webhook-delete needs a webhook id")
+		}
+		return c.send("DELETE", "/webhooks/"+url.PathEscape(name), "", false)
+	case "webhook-deadletter":
+		query := url.Values{}
+		if name != "" {
+			query.Set("webhook", name)
+		}
+		if *limit != "" {
+			query.Set("limit", *limit)
+		}
+		return c.get("/webhooks/deadletter?" + query.Encode())
+	case "groups":
+		return c.get("/group")
+	case "group", "group-delete":
+		if name == "" {
+			return errors.New(command + " needs a group name")
+		}
+		if command == "group-delete" {
+			return c.send("DELETE", "/group/"+url.PathEscape(name), "", false)
+		}
+		return c.get("/group/" + url.PathEscape(name))
+	case "group-set":
+		if name == "" || fs.NArg() == 0 {
+			return errors.New("group-set needs a group name and its members")
+		}
+		body, _ := json.Marshal(map[string][]string{"members": fs.Args()})
+		status, respBody, err := c.do("PUT", "/group/"+url.PathEscape(name), body)
+		if err != nil {
+			return err
+		}
+		return c.printResult(status, respBody)
+	case "group-checkpoint":
+		if name == "" {
+			return errors.New("group-checkpoint needs a group name")
+		}
+		path := "/group/" + url.PathEscape(name) + "/checkpoint"
+		if *timeout != "" {
+			path += "?timeout=" + url.QueryEscape(*timeout)
+		}
+		return c.groupCheckpoint(path, *bodyFile)
+	case "desired":
+		if name == "" {
+			return c.desired()
+		}
+		return c.get("/desired/" + url.PathEscape(name))
+	case "desired-set":
+		if name == "" {
+			return errors.New("desired-set needs a service name")
+		}
+		return c.send("PUT", "/desired/"+url.PathEscape(name), *bodyFile, true)
+	case "desired-delete":
+		if name == "" {
+			return errors.New("desired-delete needs a service name")
+		}
+		return c.send("DELETE", "/desired/"+url.PathEscape(name), "", false)
+	case "templates", "templates-set", "templates-delete":
+		path := "/templates"
+		if name != "" {
+			path = "/service/" + url.PathEscape(name) + "/templates"
+		}
+		switch command {
+		case "templates-set":
+			return c.send("PUT", path, *bodyFile, true)
+		case "templates-delete":
+			return c.send("DELETE", path, "", false)
+		}
+		return c.get(path)
+	case "secrets":
+		return c.get("/secrets")
+	case "secret-set":
+		if name == "" || *bodyFile == "" {
+			return errors.New("secret-set needs a secret name and its value with -f <file>")
+		}
+		// Read from a file or stdin so the value stays out of the shell history and the process list
+		value, err := readBody(*bodyFile)
+		if err != nil {
+			return err
+		}
+		body, _ := json.Marshal(map[string]string{"value": strings.TrimRight(string(value), "\r\n")})
+		status, respBody, err := c.do("PUT", "/secrets/"+url.PathEscape(name), body)
+		if err != nil {
+			return err
+		}
+		return c.printResult(status, respBody)
+	case "secret-delete":
+		if name == "" {
+			return errors.New("secret-delete needs a secret name")
+		}
+		return c.send("DELETE", "/secrets/"+url.PathEscape(name), "", false)
+	case "reconcile":
+		return c.reconcile()
+	case "reconcile-apply":
+		if name == "" {
+			return errors.New("reconcile-apply needs an action, cleanup or adopt")
+		}
+		body, _ := json.Marshal(map[string]string{"action": name, "kind": *kind, "service": *serviceName})
+		return c.reconcileApply(body)
+	case "log-level":
+		if name == "" {
+			return c.get("/log/level")
+		}
+		body, _ := json.Marshal(map[string]string{"level": name})
+		status, respBody, err := c.do("PUT", "/log/level", body)
+		if err != nil {
+			return err
+		}
+		return c.printResult(status, respBody)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return errors.New("unknown command " + command)
+	}
+}
+
+func readBody(bodyFile string) ([]byte, error) {
+	if bodyFile == "" {
+		return []byte("{}"), nil
+	}
+	if bodyFile == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(bodyFile)
+}
+
+func (c *client) do(method string, path string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
+
+// Send a request and print the controller's message (or the raw json with -o json)
+func (c *client) send(method string, path string, bodyFile string, bodyRequired bool) error {
+	if bodyRequired && bodyFile == "" {
+		return errors.New("a request body is required, use -f <file>")
+	}
+	var body []byte
+	if method != "DELETE" {
+		var err error
+		if body, err = readBody(bodyFile); err != nil {
+			return err
+		}
+	}
+	status, respBody, err := c.do(method, path, body)
+	if err != nil {
+		return err
+	}
+	return c.printResult(status, respBody)
+}
+
+func (c *client) get(path string) error {
+	status, respBody, err := c.do("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	return c.printResult(status, respBody)
+}
+
+func (c *client) printResult(status int, respBody []byte) error {
+	if c.output == "json" {
+		fmt.Println(strings.TrimSpace(string(respBody)))
+	} else {
+		var result map[string]interface{}
+		if json.Unmarshal(respBody, &result) == nil && (result["message"] != nil || result["error"] != nil) {
+			for _, key := range []string{"message", "error", "logs"} {
+				if value, ok := result[key]; ok && value != "" {
+					fmt.Println(value)
+				}
+			}
+		} else {
+			fmt.Println(strings.TrimSpace(string(respBody)))
+		}
+	}
+	if status >= 400 {
+		return fmt.Errorf("request failed with status %d", status)
+	}
+	return nil
+}
+
+// Copy a streamed response (logs) to stdout as it arrives
+func (c *client) stream(path string) error {
+	resp, err := http.Get(c.base + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return c.printResult(resp.StatusCode, body)
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+func (c *client) streamPost(path string, body []byte) error {
+	resp, err := http.Post(c.base+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+func (c *client) repeat(watch bool, interval time.Duration, fn func() error) error {
+	if !watch {
+		return fn()
+	}
+	for {
+		if c.output == "table" {
+			// Clear the terminal between refreshes
+			fmt.Print("\033[H\033[2J")
+			fmt.Println(time.Now().Format(time.RFC3339))
+		}
+		if err := fn(); err != nil {
+			fmt.Fprintln(os.Stderr, "cmctl:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (c *client) list() error {
+	status, respBody, err := c.do("GET", "/service", nil)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" || status >= 400 {
+		return c.printResult(status, respBody)
+	}
+	var services []service
+	if err := json.Unmarshal(respBody, &services); err != nil {
+		return err
+	}
+	printServices(services)
+	return nil
+}
+
+func (c *client) info(name string) error {
+	status, respBody, err := c.do("GET", "/service/"+url.PathEscape(name), nil)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" || status >= 400 {
+		return c.printResult(status, respBody)
+	}
+	var s service
+	if err := json.Unmarshal(respBody, &s); err != nil {
+		return err
+	}
+	printServices([]service{s})
+	return nil
+}
+
+func (c *client) audit(path string) error {
+	status, respBody, err := c.do("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" || status >= 400 {
+		return c.printResult(status, respBody)
+	}
+	var entries []struct {
+		Time      time.Time `json:"time"`
+		Operation string    `json:"operation"`
+		Service   string    `json:"service"`
+		Caller    string    `json:"caller"`
+		Status    int       `json:"status"`
+		Message   string    `json:"message"`
+	}
+	if err := json.Unmarshal(respBody, &entries); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOPERATION\tSERVICE\tCALLER\tSTATUS\tMESSAGE")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", e.Time.Format(time.RFC3339), e.Operation, e.Service, e.Caller, e.Status, e.Message)
+	}
+	return w.Flush()
+}
+
+func (c *client) desired() error {
+	status, respBody, err := c.do("GET", "/desired", nil)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" || status >= 400 {
+		return c.printResult(status, respBody)
+	}
+	var list []struct {
+		Spec struct {
+			ContainerName string `json:"container_name"`
+			State         string `json:"state"`
+		} `json:"spec"`
+		Observed   string `json:"observed"`
+		Converged  bool   `json:"converged"`
+		LastAction string `json:"last_action"`
+		LastError  string `json:"last_error"`
+		Failures   int    `json:"failures"`
+	}
+	if err := json.Unmarshal(respBody, &list); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tOBSERVED\tCONVERGED\tLAST ACTION\tFAILURES\tLAST ERROR")
+	for _, d := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%d\t%s\n", d.Spec.ContainerName, d.Spec.State, d.Observed, d.Converged, d.LastAction, d.Failures, d.LastError)
+	}
+	return w.Flush()
+}
+
+type reconcileFinding struct {
+	Kind    string   `json:"kind"`
+	Service string   `json:"service"`
+	Detail  string   `json:"detail"`
+	Actions []string `json:"actions"`
+}
+
+func (c *client) reconcile() error {
+	status, respBody, err := c.do("GET", "/reconcile", nil)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" || status >= 400 {
+		return c.printResult(status, respBody)
+	}
+	var report struct {
+		Findings []reconcileFinding `json:"findings"`
+		Errors   []string           `json:"errors"`
+	}
+	if err := json.Unmarshal(respBody, &report); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tKIND\tACTIONS\tDETAIL")
+	for _, f := range report.Findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Service, f.Kind, strings.Join(f.Actions, ","), f.Detail)
+	}
+	w.Flush()
+	for _, e := range report.Errors {
+		fmt.Println("error:", e)
+	}
+	return nil
+}
+
+func (c *client) reconcileApply(body []byte) error {
+	status, respBody, err := c.do("POST", "/reconcile", body)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" || status >= 400 {
+		return c.printResult(status, respBody)
+	}
+	var results []struct {
+		Finding reconcileFinding `json:"finding"`
+		Action  string           `json:"action"`
+		Result  string           `json:"result"`
+		Error   string           `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tKIND\tACTION\tRESULT\tERROR")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Finding.Service, r.Finding.Kind, r.Action, r.Result, r.Error)
+	}
+	return w.Flush()
+}
+
+// Checkpoint a group and print each member's result
+func (c *client) groupCheckpoint(path string, bodyFile string) error {
+	body, err := readBody(bodyFile)
+	if err != nil {
+		return err
+	}
+	status, respBody, err := c.do("POST", path, body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Valid   bool   `json:"valid"`
+		Error   string `json:"error"`
+		Members []struct {
+			Service  string `json:"service"`
+			ImageUrl string `json:"image_url"`
+			Result   string `json:"result"`
+			Status   string `json:"status"`
+			Message  string `json:"message"`
+			Restore  *struct {
+				Result string `json:"result"`
+			} `json:"restore"`
+		} `json:"members"`
+	}
+	if c.output == "json" || json.Unmarshal(respBody, &result) != nil || result.Members == nil {
+		return c.printResult(status, respBody)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MEMBER\tRESULT\tSTATUS\tRESTORE\tIMAGE URL\tMESSAGE")
+	for _, m := range result.Members {
+		restore := ""
+		if m.Restore != nil {
+			restore = m.Restore.Result
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", m.Service, m.Result, m.Status, restore, m.ImageUrl, strings.TrimSpace(m.Message))
+	}
+	w.Flush()
+	if !result.Valid {
+		fmt.Println("group checkpoint invalid:", result.Error)
+	}
+	if status >= 400 {
+		return fmt.Errorf("request failed with status %d", status)
+	}
+	return nil
+}
+
+func printServices(services []service) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tREADY\tLIVE\tIMAGE\tDAEMON PORT\tCONTAINER ID")
+	for _, s := range services {
+		containerId := s.ContainerId
+		if len(containerId) > 12 {
+			containerId = containerId[:12]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\t%s\t%s\n", s.ContainerName, s.Status, s.Health.Ready, s.Health.Live, s.Image, s.DaemonPort, containerId)
+	}
+	w.Flush()
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
 	"go.uber.org/zap"
 )
 
@@ -18,15 +26,94 @@ type Service struct {
 	DaemonPort    string `json:"daemon_port"`
 	Status        string `json:"status"`
 	//running,checkpointed,standby,exited
+	Health ServiceHealth `json:"health"`
+	// Last run/checkpoint sent to ff_daemon
+	LastOperation *ServiceOperation `json:"last_operation,omitempty"`
+	// Set when the last operation timed out or was canceled, so Status may be stale until ff_daemon reports again
+	NeedsReconcile bool `json:"needs_reconcile"`
+	// Status byte in comms/status when NeedsReconcile was set, the flag is cleared once it changes
+	reconcileStatus byte
+	// Network identity the container is (re-)created with, when one was given
+	Network *NetworkConfig `json:"network,omitempty"`
+	// How ff_daemon's comms reach the service dir: bind, linked or none (set for subscribed containers)
+	Comms string `json:"comms,omitempty"`
+}
+
+type ServiceOperation struct {
+	Operation string    `json:"operation"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	//success,failure,timeout,canceled
+	Result  string `json:"result"`
+	Error   string `json:"error,omitempty"`
+	Retries int    `json:"retries"`
+}
+
+// Last result of the background probe of a service's ff_daemon
+type ServiceHealth struct {
+	Ready       bool      `json:"ready"`
+	Live        bool      `json:"live"`
+	LastProbe   time.Time `json:"last_probe"`
+	LastSuccess time.Time `json:"last_success"`
+	Failures    int       `json:"failures"`
+	Error       string    `json:"error,omitempty"`
 }
 
 var services = make(map[string]Service)
 var mu sync.Mutex
 
+// Reasons a container cannot be subscribed
+var (
+	errContainerNotFound   = errors.New("container not found")
+	errContainerNotRunning = errors.New("container is not running")
+	errAlreadySubscribed   = errors.New("service already subscribed")
+	errNoDaemonPort        = errors.New("ff_daemon port is not published")
+	errDaemonUnreachable   = errors.New("ff_daemon does not respond")
+)
+
+// Where ff_daemon expects the controller's service dir inside the container
+const commsMountTarget = "/opt/controller"
+
+// Nothing new written to the status pipe since the last read
+var errNoStatus = errors.New("No byte read")
+
+// Services held by a run, checkpoint or group checkpoint, with what holds them (e.g. "group g1 checkpoint")
+var servicesBusy = make(map[string]string)
+var busyMu sync.Mutex
+
+var errServiceBusy = errors.New("service is busy")
+
+// Hold a service for an operation, fails with errServiceBusy while another one holds it
+func acquireService(containerName string, holder string) error {
+	busyMu.Lock()
+	defer busyMu.Unlock()
+	if current, ok := servicesBusy[containerName]; ok {
+		return fmt.Errorf("%w: %s in progress on %s", errServiceBusy, current, containerName)
+	}
+	servicesBusy[containerName] = holder
+	return nil
+}
+
+func releaseService(containerName string) {
+	busyMu.Lock()
+	defer busyMu.Unlock()
+	delete(servicesBusy, containerName)
+}
+
+// nil when no operation holds the service
+func checkServiceFree(containerName string) error {
+	busyMu.Lock()
+	defer busyMu.Unlock()
+	if current, ok := servicesBusy[containerName]; ok {
+		return fmt.Errorf("%w: %s in progress on %s", errServiceBusy, current, containerName)
+	}
+	return nil
+}
+
 func serviceSubscribe(containerName string, containerId string, image string, daemonPort string) (Service, error) {
-	if _, ok := services[containerName]; ok {
+	if service, ok := getService(containerName); ok {
 		logger.Error("Service already subscribed", zap.String("containerName", containerName))
-		return services[containerName], errors.New("Service already subscribed")
+		return service, errors.New("Service already subscribed")
 	}
 	err := createServiceDir(containerName)
 	if err != nil {
@@ -38,21 +125,131 @@ func serviceSubscribe(containerName string, containerId string, image string, da
 		logger.Error("Error writing service port", zap.String("containerName", containerName), zap.Error(err_p))
 		return Service{}, err_p
 	}
-	newService := Service{containerName, containerId, image, daemonPort, "new"}
+	newService := Service{ContainerName: containerName, ContainerId: containerId, Image: image, DaemonPort: daemonPort, Status: "new"}
+	if network, ok := loadServiceNetwork(containerName); ok {
+		newService.Network = &network
+	}
+	mu.Lock()
 	services[containerName] = newService
+	mu.Unlock()
+	startProber(containerName)
+
+	return newService, nil
+}
+
+// ff_daemon's port inside the container (daemonPortLabel, 7878 by default) and the host port it is
+// reached on, "" when docker publishes none
+func daemonHostPort(containerInfo types.ContainerJSON) (string, string) {
+	containerPort := "7878"
+	if containerInfo.Config != nil && containerInfo.Config.Labels[daemonPortLabel] != "" {
+		containerPort = containerInfo.Config.Labels[daemonPortLabel]
+	}
+	if containerInfo.HostConfig != nil && containerInfo.HostConfig.NetworkMode.IsHost() {
+		return containerPort, containerPort
+	}
+	if containerInfo.NetworkSettings != nil {
+		for _, binding := range containerInfo.NetworkSettings.Ports[nat.Port(containerPort+"/tcp")] {
+			if binding.HostPort != "" {
+				return containerPort, binding.HostPort
+			}
+		}
+	}
+	return containerPort, ""
+}
+
+// Subscribe an existing container, by name or id, with what docker reports about it
+func subscribeContainer(nameOrId string) (Service, error) {
+	containerInfo, err := getContainerInfo(nameOrId)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return Service{}, fmt.Errorf("%w: %s", errContainerNotFound, nameOrId)
+		}
+		return Service{}, err
+	}
+	containerName := strings.TrimPrefix(containerInfo.Name, "/")
+	if isSubscribed(containerName) {
+		return Service{}, fmt.Errorf("%w: %s", errAlreadySubscribed, containerName)
+	}
+	if containerInfo.State == nil || !containerInfo.State.Running {
+		return Service{}, fmt.Errorf("%w: %s", errContainerNotRunning, containerName)
+	}
+	containerPort, daemonPort := daemonHostPort(containerInfo)
+	if daemonPort == "" {
+		return Service{}, fmt.Errorf("%w: %s publishes no host port for %s/tcp", errNoDaemonPort, containerName, containerPort)
+	}
+	if err := probeDaemon(context.Background(), daemonPort); err != nil {
+		return Service{}, fmt.Errorf("%w on port %s: %v", errDaemonUnreachable, daemonPort, err)
+	}
+	image := ""
+	if containerInfo.Config != nil {
+		image = containerInfo.Config.Image
+	}
 
+	newService, err := serviceSubscribe(containerName, containerInfo.ID, image, daemonPort)
+	if err != nil {
+		return Service{}, err
+	}
+	comms := setupComms(containerName, containerInfo.Mounts)
+	mu.Lock()
+	if entry, ok := services[containerName]; ok {
+		entry.Comms = comms
+		services[containerName] = entry
+		newService = entry
+	}
+	mu.Unlock()
+	logger.Info("Container subscribed", zap.String("containerName", containerName), zap.String("containerId", containerInfo.ID), zap.String("daemonPort", daemonPort), zap.String("comms", comms))
 	return newService, nil
 }
 
+// Point the service dir's comms at what the container mounts on /opt/controller. A running
+// container cannot get a new mount, so without one the status only comes from the probes.
+func setupComms(containerName string, mounts []types.MountPoint) string {
+	for _, mountPoint := range mounts {
+		if mountPoint.Destination != commsMountTarget {
+			continue
+		}
+		serviceDir, err := filepath.Abs("services/" + containerName)
+		if err != nil {
+			logger.Error("Error resolving service dir", zap.String("containerName", containerName), zap.Error(err))
+			return "none"
+		}
+		if filepath.Clean(mountPoint.Source) == serviceDir {
+			return "bind"
+		}
+		// Mounted from another host dir, read the status ff_daemon writes there
+		source := filepath.Join(mountPoint.Source, "comms")
+		if _, err := os.Stat(source); err != nil {
+			logger.Warn("Container mounts /opt/controller without comms", zap.String("containerName", containerName), zap.String("source", mountPoint.Source), zap.Error(err))
+			return "none"
+		}
+		commsPath := "services/" + containerName + "/comms"
+		if err := os.RemoveAll(commsPath); err != nil {
+			logger.Error("Error removing comms dir", zap.String("containerName", containerName), zap.Error(err))
+			return "none"
+		}
+		if err := os.Symlink(source, commsPath); err != nil {
+			logger.Error("Error linking comms dir", zap.String("containerName", containerName), zap.Error(err))
+			return "none"
+		}
+		return "linked"
+	}
+	logger.Warn("Container does not mount the service dir on /opt/controller, status is only probed", zap.String("containerName", containerName))
+	return "none"
+}
+
 func serviceUnsubscribe(containerName string) error {
-	if _, ok := services[containerName]; ok {
+	if isSubscribed(containerName) {
 		err := deleteServiceDir(containerName)
 		if err != nil {
 			logger.Error("Error deleting service dir", zap.String("containerName", containerName), zap.Error(err))
 			return err
 		}
+		stopProber(containerName)
+		mu.Lock()
 		delete(services, containerName)
+		mu.Unlock()
 		logger.Debug("Service unsubscribed", zap.String("containerName", containerName))
+		closeServiceLog(containerName)
 	} else {
 		logger.Error("Service not found", zap.String("containerName", containerName))
 		return fmt.Errorf("No container name %s", containerName)
@@ -66,15 +263,31 @@ func getAllServices() {
 }
 
 func (s Service) getUpdateServiceStatus() string {
+	status, _ := s.refreshStatus()
+	return status
+}
+
+// Refresh the cached status from docker and the status file. Returns "" once the service is
+// unsubscribed because its container is gone, and the cached status with the error when docker
+// could not be asked.
+func (s Service) refreshStatus() (string, error) {
 	//fmt.Println("Enter getUpdateServiceStatus")
 	logger.Debug("Getting service status", zap.String("containerName", s.ContainerName))
 	if contStat, err := getContainerStatus(s.ContainerName); err == nil {
 		//fmt.Println(contStat)
 		if contStat == "running" {
 			stat, err := readStatusFile(s.ContainerName)
+			if err == errNoStatus {
+				return s.Status, nil
+			}
 			if err != nil {
 				logger.Error("Error reading status from status file", zap.String("containerName", s.ContainerName), zap.Error(err))
-				return s.Status
+				return s.Status, nil
+			}
+			// ff_daemon reported again, the status is known whatever happened to the last operation.
+			// The status file keeps its last byte, so an unchanged one says nothing new.
+			if s.NeedsReconcile && stat != s.reconcileStatus {
+				clearNeedsReconcile(s.ContainerName)
 			}
 			if stat == '0' {
 				//fmt.Println("case 0")
@@ -93,13 +306,16 @@ func (s Service) getUpdateServiceStatus() string {
 			//fmt.Println("case 3")
 			updateServiceStatus(s.ContainerName, contStat)
 		}
+	} else if errdefs.IsNotFound(err) {
+		logger.Warn("Container is gone, unsubscribing the service", zap.String("containerName", s.ContainerName))
+		serviceUnsubscribe(s.ContainerName)
+		return "", nil
 	} else {
+		// A docker socket error or timeout says nothing about the container, the cached status stands
 		logger.Error("Error getting container status", zap.String("containerName", s.ContainerName), zap.Error(err))
-		serviceUnsubscribe(s.ContainerName)
-		return ""
-
+		return s.Status, err
 	}
-	return s.Status
+	return s.Status, nil
 }
 
 // Get "container" status (as docker status)
@@ -127,22 +343,70 @@ func updateServiceStatus(containerName string, status string) error {
 
 }
 
+// Outcome of a callFastFreeze return code, ctx tells a timeout from a canceled call
+func newServiceOperation(operation string, started time.Time, ffRet int, ffMsg string, retries int, ctx context.Context) ServiceOperation {
+	op := ServiceOperation{Operation: operation, Started: started, Finished: time.Now(), Result: "success", Retries: retries}
+	switch ffRet {
+	case 1:
+		op.Result = "failure"
+		op.Error = ffMsg
+	case 2:
+		op.Result = "canceled"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			op.Result = "timeout"
+		}
+		op.Error = ffMsg
+	}
+	return op
+}
+
+func recordServiceOperation(containerName string, op ServiceOperation) {
+	needsReconcile := op.Result == "timeout" || op.Result == "canceled"
+	var status byte
+	if needsReconcile {
+		// 0 when ff_daemon never reported, then any status it writes clears the flag
+		status, _ = readStatusFile(containerName)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if entry, ok := services[containerName]; ok {
+		entry.LastOperation = &op
+		entry.NeedsReconcile = needsReconcile
+		entry.reconcileStatus = status
+		services[containerName] = entry
+	}
+}
+
+func clearNeedsReconcile(containerName string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if entry, ok := services[containerName]; ok && entry.NeedsReconcile {
+		entry.NeedsReconcile = false
+		services[containerName] = entry
+		logger.Info("Service reconciled after an interrupted operation", zap.String("containerName", containerName))
+	}
+}
+
 func readStatusFile(containerName string) (byte, error) {
 	logger.Debug("Reading status file", zap.String("containerName", containerName))
 	fileName := "services/" + containerName + "/comms/status"
 
-	// Open the named pipe for reading
-	pipe, err := os.OpenFile(fileName, os.O_RDONLY, os.ModeNamedPipe)
+	// Open the named pipe for reading without blocking on a silent ff_daemon
+	// (raw syscalls, os.File would park the read in the poller until data arrives)
+	fd, err := syscall.Open(fileName, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
 	if err != nil {
 		fmt.Println("Error opening status file:", err)
 		return 0, err
 	}
-	defer pipe.Close()
+	defer syscall.Close(fd)
 
 	// Read one byte at a time from the named pipe
 
 	var b [1]byte
-	n, err := pipe.Read(b[:])
+	n, err := syscall.Read(fd, b[:])
+	if err == syscall.EAGAIN {
+		return 0, errNoStatus
+	}
 	if err != nil {
 		fmt.Println("Error reading from status file:", err)
 		return 0, err
@@ -151,7 +415,7 @@ func readStatusFile(containerName string) (byte, error) {
 	if n > 0 {
 		return b[0], nil
 	}
-	return 0, fmt.Errorf("No byte read")
+	return 0, errNoStatus
 }
 
 func createRootServiceDir() {
@@ -230,11 +494,40 @@ func writeServicePort(containerName string, port string) error {
 	return nil
 }
 
+// Write v as indented json, aside first and then renamed so a crash never leaves a truncated file
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
 func isSubscribed(name string) bool {
-	if _, ok := services[name]; ok {
-		return true
+	_, ok := getService(name)
+	return ok
+}
+
+func getService(name string) (Service, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	service, ok := services[name]
+	return service, ok
+}
+
+// Snapshot of all subscribed services
+func listServices() []Service {
+	mu.Lock()
+	defer mu.Unlock()
+	allServices := make([]Service, 0, len(services))
+	for _, service := range services {
+		allServices = append(allServices, service)
 	}
-	return false
+	return allServices
 }
 
 // It will scan the /services directory and check all services stuatus via getUpdateServiceStatus()
@@ -251,7 +544,7 @@ func checkServices() {
 		if dirEntry.IsDir() {
 			conInfo, err := getContainerInfo(dirEntry.Name())
 			if err != nil {
-				logger.Error("Error getting container info", zap.String("containerName", dirEntry.Name()), zap.Error(err))
+				logger.Error("Error getting container info, see GET /reconcile", zap.String("containerName", dirEntry.Name()), zap.Error(err))
 				continue
 			}
 			portFilePath := fmt.Sprintf("services/%s/port", dirEntry.Name())
@@ -260,13 +553,21 @@ func checkServices() {
 				logger.Error("Error reading port file", zap.String("containerName", dirEntry.Name()), zap.Error(err))
 				continue
 			}
-			service := Service{dirEntry.Name(), conInfo.ID, conInfo.Config.Image, port, "new"}
+			service := Service{ContainerName: dirEntry.Name(), ContainerId: conInfo.ID, Image: conInfo.Config.Image, DaemonPort: port, Status: "new"}
+			if network, ok := loadServiceNetwork(dirEntry.Name()); ok {
+				service.Network = &network
+			}
+			mu.Lock()
 			services[dirEntry.Name()] = service
+			mu.Unlock()
 			service.getUpdateServiceStatus()
+			startProber(dirEntry.Name())
 			logger.Debug("Added a former service", zap.String("containerName", dirEntry.Name()))
 		}
 	}
-
+	if autoAdopt {
+		adoptLabeledContainers()
+	}
 }
 
 func readServicePort(filePath string) (string, error) {
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestNewServiceOperation(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+
+	tests := []struct {
+		name      string
+		ffRet     int
+		ctx       context.Context
+		wantRes   string
+		wantError string
+	}{
+		{"success", 0, context.Background(), "success", ""},
+		{"failure", 1, context.Background(), "failure", "ff msg"},
+		{"client gone", 2, canceled, "canceled", "ff msg"},
+		{"deadline", 2, expired, "timeout", "ff msg"},
+	}
+	for _, tt := range tests {
+		op := newServiceOperation("checkpoint", time.Now(), tt.ffRet, "ff msg", 2, tt.ctx)
+		if op.Operation != "checkpoint" || op.Result != tt.wantRes || op.Error != tt.wantError || op.Retries != 2 {
+			t.Errorf("%s: newServiceOperation() = %+v", tt.name, op)
+		}
+	}
+}
+
+func TestSubscribeHandler(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.POST("/cm_controller/v1/subscribe", subscribeHandler)
+	serviceDir, _ := filepath.Abs("services/sub-bind")
+	linkedDir := t.TempDir()
+	os.Mkdir(filepath.Join(linkedDir, "comms"), 0700)
+	runs := [][]string{
+		{"--name", "sub-bind", "-p", "17901:7878", "--mount", "type=bind,source=" + serviceDir + ",target=" + commsMountTarget},
+		{"--name", "sub-linked", "-p", "17902:7878", "--mount", "type=bind,source=" + linkedDir + ",target=" + commsMountTarget},
+		{"--name", "sub-none", "-p", "17903:7878"},
+		{"--name", "sub-noport", "-p", "17904:80"},
+		{"--name", "sub-unreachable", "-p", "17905:7878"},
+		{"--name", "sub-stopped", "-p", "17907:7878"},
+	}
+	ids := map[string]string{}
+	for _, args := range runs {
+		id, err := sim.run(append(append([]string{"docker", "run", "-d"}, args...), "img"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[args[1]] = id
+	}
+	sim.ContainerStop(context.Background(), "sub-stopped", container.StopOptions{})
+	// The container keeps running without its ff_daemon
+	sim.mu.Lock()
+	daemon := sim.containers["sub-unreachable"].daemon
+	sim.containers["sub-unreachable"].daemon = nil
+	sim.mu.Unlock()
+	daemon.stop()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantComms string
+	}{
+		{"no container", "", http.StatusBadRequest, ""},
+		{"not found", "container_name=missing", http.StatusNotFound, ""},
+		{"by id, service dir mounted", "container_name=" + ids["sub-bind"][:12], http.StatusOK, "bind"},
+		{"already subscribed", "container_name=sub-bind", http.StatusConflict, ""},
+		{"comms mounted from elsewhere", "container_name=sub-linked", http.StatusOK, "linked"},
+		{"nothing mounted", "container_id=sub-none", http.StatusOK, "none"},
+		{"daemon port not published", "container_name=sub-noport", http.StatusUnprocessableEntity, ""},
+		{"daemon not answering", "container_name=sub-unreachable", http.StatusBadGateway, ""},
+		{"not running", "container_name=sub-stopped", http.StatusConflict, ""},
+	}
+	for _, tt := range tests {
+		code, response := serve(r, "POST", "/subscribe?"+tt.query, "")
+		if code != tt.wantCode {
+			t.Errorf("%s: code %d %v, want %d", tt.name, code, response, tt.wantCode)
+			continue
+		}
+		if code != http.StatusOK {
+			if _, ok := response["error"]; !ok {
+				t.Errorf("%s: no error in %v", tt.name, response)
+			}
+			continue
+		}
+		name, _ := response["container_name"].(string)
+		if response["comms"] != tt.wantComms || response["container_id"] != ids[name] || response["image"] != "img" || !isSubscribed(name) {
+			t.Errorf("%s: subscribed %v", tt.name, response)
+		}
+	}
+	for _, name := range []string{"sub-noport", "sub-unreachable", "sub-stopped"} {
+		if isSubscribed(name) {
+			t.Errorf("%s subscribed after a failure", name)
+		}
+	}
+	// The service dir's comms read what ff_daemon writes in the dir the container mounts
+	if target, err := os.Readlink("services/sub-linked/comms"); err != nil || target != filepath.Join(linkedDir, "comms") {
+		t.Errorf("linked comms %s: %v", target, err)
+	}
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Full spec of a service the reconciliation loop drives the container and ff_daemon toward
+type DesiredSpec struct {
+	StartBody
+	//running,checkpointed,stopped
+	State string `json:"state"`
+	// Run arguments, their image_url is where the application is restored from
+	Run json.RawMessage `json:"run,omitempty"`
+	// Checkpoint image to restore from, overrides the run image_url
+	RestoreFrom string `json:"restore_from,omitempty"`
+	// Checkpoint arguments used to reach the checkpointed state
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+	// api or manifest, a manifest re-apply drops the manifest services it no longer lists
+	Source string `json:"source,omitempty"`
+}
+
+type DesiredStatus struct {
+	Spec DesiredSpec `json:"spec"`
+	// absent, a docker status (created, exited, paused...) or the service status of a running container
+	Observed      string    `json:"observed"`
+	Converged     bool      `json:"converged"`
+	LastAction    string    `json:"last_action,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastReconcile time.Time `json:"last_reconcile"`
+	Failures      int       `json:"failures"`
+	NextAttempt   time.Time `json:"next_attempt"`
+}
+
+var desiredPath = envString("DESIRED_FILE", "desired.json")
+var reconcileInterval = envDuration("RECONCILE_INTERVAL", 10*time.Second)
+var reconcileBackoff = envDuration("RECONCILE_BACKOFF", 5*time.Second)
+var reconcileMaxBackoff = envDuration("RECONCILE_MAX_BACKOFF", 5*time.Minute)
+
+var desired = make(map[string]*DesiredStatus)
+
+// Services with a convergence step in progress
+var converging = make(map[string]bool)
+var desiredMu sync.Mutex
+
+// Wakes the loop up early, e.g. after a spec changed
+var reconcileKick = make(chan struct{}, 1)
+
+// Nothing to do until something else happens (ff_daemon reporting, a container starting...)
+var errConvergeWait = errors.New("waiting")
+
+func loadDesired() {
+	data, err := os.ReadFile(desiredPath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Error reading desired state file", zap.Error(err))
+		return
+	}
+	var specs []DesiredSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		logger.Error("Error parsing desired state file", zap.Error(err))
+		return
+	}
+	desiredMu.Lock()
+	defer desiredMu.Unlock()
+	for _, spec := range specs {
+		desired[spec.ContainerName] = &DesiredStatus{Spec: spec}
+	}
+	logger.Info("Desired state loaded", zap.Int("count", len(specs)))
+}
+
+// Must be called with desiredMu held
+func saveDesired() error {
+	specs := make([]DesiredSpec, 0, len(desired))
+	for _, status := range desired {
+		specs = append(specs, status.Spec)
+	}
+	return writeJSONFile(desiredPath, specs)
+}
+
+func kickReconcile() {
+	select {
+	case reconcileKick <- struct{}{}:
+	default:
+	}
+}
+
+func reconcileLoop() {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for {
+		convergeAll()
+		select {
+		case <-ticker.C:
+		case <-reconcileKick:
+		}
+	}
+}
+
+// Start a convergence step for every desired service that is not already converging or backing off
+func convergeAll() {
+	desiredMu.Lock()
+	defer desiredMu.Unlock()
+	now := time.Now()
+	for name, status := range desired {
+		if converging[name] || now.Before(status.NextAttempt) {
+			continue
+		}
+		converging[name] = true
+		go convergeService(name, status.Spec)
+	}
+}
+
+// One step toward the spec, the loop takes the next one on its next pass
+func convergeService(name string, spec DesiredSpec) {
+	observed, action, err := convergeStep(name, spec)
+
+	desiredMu.Lock()
+	defer desiredMu.Unlock()
+	delete(converging, name)
+	status, ok := desired[name]
+	if !ok {
+		return
+	}
+	status.Observed = observed
+	status.LastReconcile = time.Now()
+	status.Converged = action == "" && err == nil
+	if action != "" {
+		status.LastAction = action
+	}
+	switch {
+	case err == nil || err == errConvergeWait:
+		status.Failures = 0
+		status.NextAttempt = time.Time{}
+		status.LastError = ""
+		if action != "" {
+			// Keep going right away rather than waiting for the next tick
+			kickReconcile()
+		}
+	default:
+		status.Failures++
+		backoff := reconcileBackoff << (status.Failures - 1)
+		if backoff > reconcileMaxBackoff || backoff <= 0 {
+			backoff = reconcileMaxBackoff
+		}
+		status.NextAttempt = time.Now().Add(backoff)
+		status.LastError = err.Error()
+		logger.Warn("Convergence failed", zap.String("containerName", name), zap.String("action", action), zap.Int("failures", status.Failures), zap.Duration("backoff", backoff), zap.Error(err))
+	}
+}
+
+// Observed state of a service: absent, the docker status of a container that is not running,
+// or else the service status ("unsubscribed" when the controller does not know the container)
+func observeService(name string) (string, error) {
+	containerStatus, err := getContainerStatus(name)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return "absent", nil
+		}
+		return "", err
+	}
+	if containerStatus != "running" {
+		return containerStatus, nil
+	}
+	service, ok := getService(name)
+	if !ok {
+		return "unsubscribed", nil
+	}
+	if service.NeedsReconcile {
+		return "needs_reconcile", nil
+	}
+	return service.Status, nil
+}
+
+// Returns the observed state, the action taken ("" when converged or waiting) and its error
+func convergeStep(name string, spec DesiredSpec) (string, string, error) {
+	observed, err := observeService(name)
+	if err != nil {
+		return "unknown", "", err
+	}
+	// A service another operation holds (a group checkpoint pauses its members) is left alone until it is done
+	if checkServiceFree(name) != nil {
+		return observed, "", errConvergeWait
+	}
+	switch spec.State {
+	case "stopped":
+		action, err := convergeStopped(name, observed)
+		return observed, action, err
+	case "checkpointed":
+		action, err := convergeCheckpointed(name, spec, observed)
+		return observed, action, err
+	default:
+		action, err := convergeRunning(name, spec, observed)
+		return observed, action, err
+	}
+}
+
+// Container created (or started again) and subscribed, ff_daemon in standby or better
+func convergeContainer(name string, spec DesiredSpec, observed string) (string, bool, error) {
+	switch observed {
+	case "absent":
+		createServiceDir(name)
+		err := startService(name, spec.Image, spec.AppPorts, spec.Envs, spec.Mounts, spec.Caps, spec.Resources, spec.Network)
+		return "start", false, err
+	case "dead":
+		// docker cannot start it again, it is created from the spec on the next pass
+		return "remove", false, removeContainer(name)
+	case "exited", "created":
+		// The existing container is started as it is, a docker run would conflict with its name
+		if err := startContainer(name); err != nil {
+			return "start", false, err
+		}
+		if isSubscribed(name) {
+			return "start", false, nil
+		}
+		// Until ff_daemon is up the next pass observes it unsubscribed and subscribes it then
+		if _, err := subscribeContainer(name); err != nil && !errors.Is(err, errDaemonUnreachable) {
+			return "start", false, err
+		}
+		return "start", false, nil
+	case "paused":
+		return "unpause", false, unpauseContainer(name)
+	case "unsubscribed":
+		// A container started outside the controller, adopted as it is
+		_, err := subscribeContainer(name)
+		return "subscribe", false, err
+	case "new", "needs_reconcile", "restarting", "removing":
+		// ff_daemon has not reported (again) yet
+		return "", false, errConvergeWait
+	}
+	return "", true, nil
+}
+
+func convergeRunning(name string, spec DesiredSpec, observed string) (string, error) {
+	if action, ready, err := convergeContainer(name, spec, observed); !ready {
+		return action, err
+	}
+	switch observed {
+	case "running":
+		return "", nil
+	case "standby", "checkpointed":
+		return "run", convergeFastFreeze(name, spec, 0)
+	}
+	return "", fmt.Errorf("unexpected service status %s", observed)
+}
+
+// From standby the application is run (restored) first, so there is something to checkpoint
+func convergeCheckpointed(name string, spec DesiredSpec, observed string) (string, error) {
+	if action, ready, err := convergeContainer(name, spec, observed); !ready {
+		return action, err
+	}
+	switch observed {
+	case "checkpointed":
+		return "", nil
+	case "standby":
+		return "run", convergeFastFreeze(name, spec, 0)
+	case "running":
+		return "checkpoint", convergeFastFreeze(name, spec, 1)
+	}
+	return "", fmt.Errorf("unexpected service status %s", observed)
+}
+
+func convergeStopped(name string, observed string) (string, error) {
+	switch observed {
+	case "absent", "exited", "created", "dead":
+		return "", nil
+	case "paused":
+		if err := unpauseContainer(name); err != nil {
+			return "unpause", err
+		}
+	}
+	return "stop", stopContainer(name)
+}
+
+// Arguments sent to ff_daemon for a run (mode 0) or a checkpoint (mode 1) of the spec
+func (spec DesiredSpec) fastFreezeBody(mode int) ([]byte, error) {
+	args := map[string]interface{}{}
+	raw := spec.Run
+	if mode == 1 {
+		raw = spec.Checkpoint
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, err
+		}
+	}
+	if mode == 0 && spec.RestoreFrom != "" {
+		args["image_url"] = spec.RestoreFrom
+	}
+	if mode == 1 {
+		// Reaching the checkpointed state means the application stops
+		args["leave_running"] = false
+	}
+	return json.Marshal(args)
+}
+
+func convergeFastFreeze(name string, spec DesiredSpec, mode int) error {
+	requestBody, err := spec.fastFreezeBody(mode)
+	if err != nil {
+		return err
+	}
+	err = fastFreezeOperation(name, mode, requestBody)
+	if errors.Is(err, errServiceBusy) {
+		// Observed again once the other operation is done
+		return errConvergeWait
+	}
+	return err
+}
+
+// Run or checkpoint through ff_daemon like the API does: admitted, under the operation's deadline and recorded
+func fastFreezeOperation(name string, mode int, requestBody []byte) error {
+	operation := "run"
+	if mode == 1 {
+		operation = "checkpoint"
+	}
+	requestBody, err := applyParamTemplates(name, operation, requestBody)
+	if err != nil {
+		return err
+	}
+	var body CheckpointBody
+	json.Unmarshal(requestBody, &body)
+	timeout, weight := runTimeout, cpuBudgetWeight("")
+	if mode == 1 {
+		timeout, weight = checkpointTimeout, cpuBudgetWeight(body.Cpu_budget)
+	}
+
+	// Shared with the API's run and checkpoint, so neither interleaves with the other on a service
+	if err := acquireService(name, "desired "+operation); err != nil {
+		return err
+	}
+	defer releaseService(name)
+	ticket, err := admission.acquire(context.Background(), name, operation, weight, 0)
+	if err != nil {
+		return err
+	}
+	defer admission.release(ticket)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	started := time.Now()
+	ffRet, ffMsg, retries := callFastFreeze(ctx, mode, requestBody, name)
+	op := newServiceOperation(operation, started, ffRet, ffMsg, retries, ctx)
+	recordServiceOperation(name, op)
+	notifyOperation(name, op, ffMsg, body.ImgUrl)
+	if ffRet != 0 {
+		return fmt.Errorf("%s %s: %s", operation, op.Result, ffMsg)
+	}
+	if mode == 0 || body.LeaveRun {
+		updateServiceStatus(name, "running")
+	} else {
+		updateServiceStatus(name, "checkpointed")
+	}
+	logger.Info("Service operation done", zap.String("containerName", name), zap.String("operation", operation))
+	return nil
+}
+
+func (spec DesiredSpec) validate() error {
+	switch spec.State {
+	case "running", "checkpointed", "stopped":
+	default:
+		return errors.New("state must be running, checkpointed or stopped")
+	}
+	if spec.Image == "" {
+		return errors.New("image is required")
+	}
+	for _, raw := range []json.RawMessage{spec.Run, spec.Checkpoint} {
+		var args map[string]interface{}
+		if len(raw) > 0 && json.Unmarshal(raw, &args) != nil {
+			return errors.New("run and checkpoint must be json objects")
+		}
+	}
+	return spec.Network.validate()
+}
+
+func putDesiredHandler(c *gin.Context) {
+	name := c.Param("name")
+	var spec DesiredSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid spec: " + err.Error()})
+		return
+	}
+	if spec.ContainerName != "" && spec.ContainerName != name {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "container_name does not match " + name})
+		return
+	}
+	spec.ContainerName = name
+	spec.Source = "api"
+	if err := spec.validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid spec: " + err.Error()})
+		return
+	}
+
+	desiredMu.Lock()
+	previous, existed := desired[name]
+	status := &DesiredStatus{Spec: spec}
+	if existed {
+		status.Observed = previous.Observed
+		status.LastReconcile = previous.LastReconcile
+	}
+	desired[name] = status
+	err := saveDesired()
+	if err != nil {
+		if existed {
+			desired[name] = previous
+		} else {
+			delete(desired, name)
+		}
+	}
+	desiredMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving desired state", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the spec"})
+		return
+	}
+	logger.Info("Desired state set", zap.String("containerName", name), zap.String("state", spec.State))
+	kickReconcile()
+	c.IndentedJSON(http.StatusOK, *status)
+}
+
+func getDesiredHandler(c *gin.Context) {
+	name := c.Param("name")
+	desiredMu.Lock()
+	defer desiredMu.Unlock()
+	status, ok := desired[name]
+	if !ok {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no desired state for " + name + " found!"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, *status)
+}
+
+func listDesiredHandler(c *gin.Context) {
+	desiredMu.Lock()
+	defer desiredMu.Unlock()
+	list := make([]DesiredStatus, 0, len(desired))
+	for _, status := range desired {
+		list = append(list, *status)
+	}
+	c.IndentedJSON(http.StatusOK, list)
+}
+
+// Stop managing a service declaratively, its container is left as it is
+func deleteDesiredHandler(c *gin.Context) {
+	name := c.Param("name")
+	desiredMu.Lock()
+	defer desiredMu.Unlock()
+	status, ok := desired[name]
+	if !ok {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no desired state for " + name + " found!"})
+		return
+	}
+	delete(desired, name)
+	if err := saveDesired(); err != nil {
+		desired[name] = status
+		logger.Error("Error saving desired state", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the spec"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Desired state of " + name + " deleted"})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Spec of a simulated service, created like startSimulated does
+func simulatedSpec(name string, state string) DesiredSpec {
+	return DesiredSpec{StartBody: StartBody{ContainerName: name, Image: "img"}, State: state}
+}
+
+func simContainerStatus(sim *simDockerClient, name string) string {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	c, ok := sim.containers[name]
+	if !ok {
+		return "absent"
+	}
+	return c.Status
+}
+
+func TestConvergeContainer(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		setup         func(name string)
+		wantAction    string
+		wantReady     bool
+		wantErr       error
+		wantContainer string
+		wantSubscribe bool
+	}{
+		{"absent is created", func(string) {}, "start", false, nil, "running", true},
+		{"exited is started, not created again", func(name string) {
+			startSimulated(t, r, name)
+			sim.ContainerStop(ctx, name, container.StopOptions{})
+		}, "start", false, nil, "running", true},
+		{"exited and unsubscribed is started and subscribed", func(name string) {
+			startSimulated(t, r, name)
+			serviceUnsubscribe(name)
+			sim.ContainerStop(ctx, name, container.StopOptions{})
+		}, "start", false, nil, "running", true},
+		{"created is started", func(name string) {
+			startSimulated(t, r, name)
+			sim.ContainerStop(ctx, name, container.StopOptions{})
+			sim.mu.Lock()
+			sim.containers[name].Status = "created"
+			sim.mu.Unlock()
+		}, "start", false, nil, "running", true},
+		{"dead is removed", func(name string) {
+			startSimulated(t, r, name)
+			sim.ContainerStop(ctx, name, container.StopOptions{})
+			sim.mu.Lock()
+			sim.containers[name].Status = "dead"
+			sim.mu.Unlock()
+		}, "remove", false, nil, "absent", true},
+		{"paused is unpaused", func(name string) {
+			startSimulated(t, r, name)
+			sim.ContainerPause(ctx, name)
+		}, "unpause", false, nil, "running", true},
+		{"unsubscribed is adopted", func(name string) {
+			startSimulated(t, r, name)
+			serviceUnsubscribe(name)
+		}, "subscribe", false, nil, "running", true},
+		{"running is ready", func(name string) {
+			startSimulated(t, r, name)
+		}, "", true, nil, "running", true},
+	}
+	for i, tt := range tests {
+		name := "converge-" + string(rune('a'+i))
+		tt.setup(name)
+		observed, err := observeService(name)
+		if err != nil {
+			t.Fatalf("%s: observe: %v", tt.name, err)
+		}
+		action, ready, err := convergeContainer(name, simulatedSpec(name, "running"), observed)
+		if action != tt.wantAction || ready != tt.wantReady || err != tt.wantErr {
+			t.Errorf("%s: observed %s, convergeContainer() = %q, %v, %v, want %q, %v, %v", tt.name, observed, action, ready, err, tt.wantAction, tt.wantReady, tt.wantErr)
+		}
+		if status := simContainerStatus(sim, name); status != tt.wantContainer {
+			t.Errorf("%s: container %s, want %s", tt.name, status, tt.wantContainer)
+		}
+		if tt.wantContainer != "absent" && isSubscribed(name) != tt.wantSubscribe {
+			t.Errorf("%s: subscribed %v, want %v", tt.name, isSubscribed(name), tt.wantSubscribe)
+		}
+	}
+
+	// ff_daemon not reporting yet, nothing to do until it does
+	for _, observed := range []string{"new", "needs_reconcile", "restarting", "removing"} {
+		if action, ready, err := convergeContainer("converge-wait", simulatedSpec("converge-wait", "running"), observed); action != "" || ready || err != errConvergeWait {
+			t.Errorf("%s: convergeContainer() = %q, %v, %v, want waiting", observed, action, ready, err)
+		}
+	}
+}
+
+func TestConvergeStep(t *testing.T) {
+	r, _ := simulatedRouter(t)
+	tests := []struct {
+		name         string
+		state        string
+		running      bool
+		busy         bool
+		wantObserved string
+		wantAction   string
+		wantErr      error
+		wantStatus   string
+	}{
+		{"standby is run", "running", false, false, "standby", "run", nil, "running"},
+		{"running is converged", "running", true, false, "running", "", nil, "running"},
+		{"running is checkpointed", "checkpointed", true, false, "running", "checkpoint", nil, "checkpointed"},
+		{"standby is run before its checkpoint", "checkpointed", false, false, "standby", "run", nil, "running"},
+		{"running is stopped", "stopped", true, false, "running", "stop", nil, ""},
+		{"busy is left alone", "stopped", true, true, "running", "", errConvergeWait, "running"},
+	}
+	for i, tt := range tests {
+		name := "step-" + string(rune('a'+i))
+		startSimulated(t, r, name)
+		if tt.running {
+			if code, response := serve(r, "POST", "/run/"+name, `{}`); code != http.StatusOK {
+				t.Fatalf("%s: run: %d %v", tt.name, code, response)
+			}
+		}
+		if tt.busy {
+			if err := acquireService(name, "group g checkpoint"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		spec := simulatedSpec(name, tt.state)
+		spec.Checkpoint = []byte(`{"image_url":"file:/tmp/` + name + `"}`)
+		observed, action, err := convergeStep(name, spec)
+		if tt.busy {
+			releaseService(name)
+		}
+		if observed != tt.wantObserved || action != tt.wantAction || err != tt.wantErr {
+			t.Errorf("%s: convergeStep() = %s, %q, %v, want %s, %q, %v", tt.name, observed, action, err, tt.wantObserved, tt.wantAction, tt.wantErr)
+		}
+		if tt.wantStatus == "" {
+			continue
+		}
+		if service, _ := getService(name); service.Status != tt.wantStatus {
+			t.Errorf("%s: service status %s, want %s", tt.name, service.Status, tt.wantStatus)
+		}
+	}
+}
+
+func TestOperationOnBusyService(t *testing.T) {
+	r, _ := simulatedRouter(t)
+	startSimulated(t, r, "busy-svc")
+	if err := acquireService("busy-svc", "group g checkpoint"); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/run/busy-svc", "/checkpoint/busy-svc"} {
+		if code, response := serve(r, "POST", path, `{}`); code != http.StatusConflict {
+			t.Errorf("%s: code %d %v, want %d", path, code, response, http.StatusConflict)
+		}
+	}
+	releaseService("busy-svc")
+	if code, response := serve(r, "POST", "/run/busy-svc", `{}`); code != http.StatusOK {
+		t.Errorf("run once released: code %d %v", code, response)
+	}
+}
@@ -16,19 +16,29 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-units"
 	"go.uber.org/zap"
 )
 
 var lastDaemonPort int = 7877
 
-func startService(containerName string, imageName string, portMappings []string, inputEnv []string, mounts []mount.Mount, caps []string) error {
+func startService(containerName string, imageName string, portMappings []string, inputEnv []string, mounts []mount.Mount, caps []string, resources ResourceLimits, network NetworkConfig) error {
 	logger.Debug("Starting service", zap.String("containerName", containerName))
 	if !isSubscribed(containerName) {
-		err := runContainer(containerName, imageName, portMappings, inputEnv, mounts, caps)
+		network, err := resolveServiceNetwork(containerName, network)
+		if err != nil {
+			logger.Error("Invalid network config", zap.String("containerName", containerName), zap.Error(err))
+			return err
+		}
+		err = runContainer(containerName, imageName, portMappings, inputEnv, mounts, caps, resources, network)
 		if err != nil {
 			logger.Error("Error running container", zap.String("containerName", containerName), zap.Error(err))
 			return err
 		}
+		if !network.isEmpty() && network.Hostname == "" {
+			pinServiceHostname(containerName, network)
+		}
 	} else {
 		status, err := getContainerStatus(containerName)
 		if err != nil {
@@ -50,8 +60,15 @@ func startService(containerName string, imageName string, portMappings []string,
 	return nil
 }
 
-func runContainer(containerName string, imageName string, portMappings []string, inputEnv []string, mounts []mount.Mount, caps []string) error {
+func runContainer(containerName string, imageName string, portMappings []string, inputEnv []string, mounts []mount.Mount, caps []string, resources ResourceLimits, network NetworkConfig) error {
 	logger.Debug("Running container", zap.String("containerName", containerName))
+	// Host checks are advisory, a failure is logged and reported with the heartbeat;
+	// only an image already found without ff_daemon cannot run as a service
+	runHostPreflight()
+	if err := newPreflightReport([]PreflightCheck{checkKnownImage(imageName)}).err(); err != nil {
+		logger.Error("Preflight failed before docker run", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
 	hostDaemonPort := lastDaemonPort + 1
 	for isPortInUse(strconv.Itoa(hostDaemonPort)) {
 		hostDaemonPort += 1
@@ -62,6 +79,7 @@ func runContainer(containerName string, imageName string, portMappings []string,
 	cmdArgs := []string{
 		"docker", "run",
 		"--name", containerName,
+		"--label", managedLabel + "=true",
 	}
 
 	for _, port := range portMappings {
@@ -94,31 +112,43 @@ func runContainer(containerName string, imageName string, portMappings []string,
 	}
 	cmdArgs = append(cmdArgs, "--mount", "type=bind,source="+curr_path+"/services/"+containerName+",target=/opt/controller")
 
-	//Add env arguments
+	//Add resource limit arguments
+	cmdArgs = append(cmdArgs, resources.dockerRunArgs()...)
+
+	//Add network arguments
+	cmdArgs = append(cmdArgs, network.dockerRunArgs()...)
+
+	//Add env arguments. Secret references are resolved only now so specs and manifests keep the names,
+	//and the envs holding them go through an env file so the values never show on the command line
+	var secretEnvs []string
 	for _, env := range inputEnv {
-		cmdArgs = append(cmdArgs, "-e", env)
+		if secretRefPattern.MatchString(env) {
+			secretEnvs = append(secretEnvs, env)
+		} else {
+			cmdArgs = append(cmdArgs, "-e", env)
+		}
+	}
+	if len(secretEnvs) > 0 {
+		envFile, err := writeSecretEnvFile(secretEnvs)
+		if err != nil {
+			logger.Error("Error resolving secrets", zap.String("containerName", containerName), zap.Error(err))
+			return err
+		}
+		defer os.Remove(envFile)
+		cmdArgs = append(cmdArgs, "--env-file", envFile)
 	}
 	cmdArgs = append(cmdArgs, "-d", "--init")
 
 	// Add the image name to the command arguments and command to start with
 	cmdArgs = append(cmdArgs, imageName, "ff_daemon")
 
-	// Create the command using the constructed arguments
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
-
-	var stdoutBuf bytes.Buffer
-	// Set the output and error pipes to capture the command's output
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = os.Stderr
-
 	// Run the Docker CLI command
-	logger.Debug("Running Docker run command", zap.String("containerName", containerName), zap.String("command", strings.Join(cmdArgs, " ")))
-	err_r := cmd.Run()
+	logger.Debug("Running Docker run command", zap.String("containerName", containerName), zap.String("command", strings.Join(redactRunArgs(cmdArgs), " ")))
+	containerId, err_r := dockerRun(cmdArgs)
 	if err_r != nil {
 		logger.Error("Error running Docker command", zap.String("containerName", containerName), zap.Error(err_r))
 		return err_r
 	}
-	containerId := strings.TrimSuffix(stdoutBuf.String(), "\n")
 	conStat, err := getContainerStatus(containerName)
 	if err != nil || conStat != "running" {
 		logger.Error("Error getting container status after docker run", zap.String("containerName", containerName), zap.Error(err))
@@ -138,11 +168,49 @@ func runContainer(containerName string, imageName string, portMappings []string,
 	return nil
 }
 
+// docker run arguments as logged, the -e envs masked like in the audit log
+func redactRunArgs(cmdArgs []string) []string {
+	redactedArgs := append([]string(nil), cmdArgs...)
+	for i := 1; i < len(redactedArgs); i++ {
+		if redactedArgs[i-1] == "-e" {
+			redactedArgs[i] = redactEnv(redactedArgs[i])
+		}
+	}
+	return redactedArgs
+}
+
+// Run the docker CLI with cmdArgs and return the new container id (from stdout)
+func dockerRun(cmdArgs []string) (string, error) {
+	if simulate {
+		return simDocker.run(cmdArgs)
+	}
+	// Create the command using the constructed arguments
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+
+	var stdoutBuf bytes.Buffer
+	// Set the output and error pipes to capture the command's output
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(stdoutBuf.String(), "\n"), nil
+}
+
+// Docker API client, the in-process fake when running with --simulate
+func newDockerClient() (client.APIClient, error) {
+	if simulate {
+		return simDocker, nil
+	}
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
+
 // use docker client to start a container with container name
 func startContainer(containerName string) error {
 	// Create a Docker client
 	logger.Debug("Starting container", zap.String("containerName", containerName))
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := newDockerClient()
 	if err != nil {
 		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
 		return err
@@ -151,7 +219,10 @@ func startContainer(containerName string) error {
 	ctx := context.Background()
 
 	// Start the container
-	if err := cli.ContainerStart(ctx, containerName, types.ContainerStartOptions{}); err != nil {
+	err = retryDocker(ctx, "docker_start", containerName, func() error {
+		return cli.ContainerStart(ctx, containerName, types.ContainerStartOptions{})
+	})
+	if err != nil {
 		logger.Error("Error starting container", zap.String("containerName", containerName), zap.Error(err))
 		return err
 	}
@@ -172,7 +243,7 @@ func isPortInUse(port string) bool {
 
 func getAllContainerInfo() {
 	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := newDockerClient()
 	if err != nil {
 		panic(err)
 	}
@@ -194,7 +265,7 @@ func getContainerInfo(containerId string) (types.ContainerJSON, error) {
 
 	// Create a Docker client
 	logger.Debug("Getting container info", zap.String("containerId", containerId))
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := newDockerClient()
 	if err != nil {
 		logger.Error("Error creating docker client", zap.String("containerId", containerId), zap.Error(err))
 		return types.ContainerJSON{}, err
@@ -204,7 +275,12 @@ func getContainerInfo(containerId string) (types.ContainerJSON, error) {
 
 	//fmt.Printf("Will call Inspect for %s\n", containerId)
 	// Inspect the container to get detailed information
-	containerInfo, err := cli.ContainerInspect(ctx, containerId)
+	var containerInfo types.ContainerJSON
+	err = retryDocker(ctx, "docker_inspect", containerId, func() error {
+		var err error
+		containerInfo, err = cli.ContainerInspect(ctx, containerId)
+		return err
+	})
 	if err != nil {
 		logger.Error("Error inspecting container", zap.String("containerId", containerId), zap.Error(err))
 		return types.ContainerJSON{}, err
@@ -221,7 +297,7 @@ func getContainerInfo(containerId string) (types.ContainerJSON, error) {
 func stopContainer(containerName string) error {
 	// Create a Docker client
 	logger.Debug("Stopping container", zap.String("containerName", containerName))
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := newDockerClient()
 	if err != nil {
 		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
 		return err
@@ -233,7 +309,10 @@ func stopContainer(containerName string) error {
 		Timeout: nil,
 	}
 	// Stop the container
-	if err := cli.ContainerStop(ctx, containerName, stopOptions); err != nil {
+	err = retryDocker(ctx, "docker_stop", containerName, func() error {
+		return cli.ContainerStop(ctx, containerName, stopOptions)
+	})
+	if err != nil {
 		logger.Error("Error stopping container", zap.String("containerName", containerName), zap.Error(err))
 		return err
 	}
@@ -241,10 +320,55 @@ func stopContainer(containerName string) error {
 	return nil
 }
 
+// Freeze every process of the container (cgroup freezer), ff_daemon included
+func pauseContainer(containerName string) error {
+	logger.Debug("Pausing container", zap.String("containerName", containerName))
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	err = retryDocker(ctx, "docker_pause", containerName, func() error {
+		return cli.ContainerPause(ctx, containerName)
+	})
+	if err != nil {
+		logger.Error("Error pausing container", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	logger.Info("Container paused", zap.String("containerName", containerName))
+	return nil
+}
+
+func unpauseContainer(containerName string) error {
+	logger.Debug("Unpausing container", zap.String("containerName", containerName))
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	err = retryDocker(ctx, "docker_unpause", containerName, func() error {
+		return cli.ContainerUnpause(ctx, containerName)
+	})
+	if err != nil {
+		logger.Error("Error unpausing container", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	logger.Info("Container unpaused", zap.String("containerName", containerName))
+	return nil
+}
+
 func removeContainer(containerName string) error {
 	// Create a Docker client
 	logger.Debug("Removing container", zap.String("containerName", containerName))
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := newDockerClient()
 	if err != nil {
 		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
 		return err
@@ -253,7 +377,17 @@ func removeContainer(containerName string) error {
 	ctx := context.Background()
 
 	// Delete the container
-	if err := cli.ContainerRemove(ctx, containerName, types.ContainerRemoveOptions{}); err != nil {
+	attempts := 0
+	err = retryDocker(ctx, "docker_remove", containerName, func() error {
+		attempts++
+		err := cli.ContainerRemove(ctx, containerName, types.ContainerRemoveOptions{})
+		// An earlier attempt that failed with a 5xx may still have removed it
+		if attempts > 1 && errdefs.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
 		logger.Error("Error removing container", zap.String("containerName", containerName), zap.Error(err))
 		return err
 	}
@@ -267,5 +401,133 @@ func removeContainer(containerName string) error {
 		return err
 	}
 	logger.Info("Container removed", zap.String("containerName", containerName))
+	notify("service.removed", containerName, nil)
 	return nil
 }
+
+// Translate the resource limits to docker run flags, zero values are left to docker defaults
+func (r ResourceLimits) dockerRunArgs() []string {
+	var args []string
+	if r.CpuShares != 0 {
+		args = append(args, "--cpu-shares", strconv.FormatInt(r.CpuShares, 10))
+	}
+	if r.CpuPeriod != 0 {
+		args = append(args, "--cpu-period", strconv.FormatInt(r.CpuPeriod, 10))
+	}
+	if r.CpuQuota != 0 {
+		args = append(args, "--cpu-quota", strconv.FormatInt(r.CpuQuota, 10))
+	}
+	if r.Cpus != "" {
+		args = append(args, "--cpus", r.Cpus)
+	}
+	if r.CpusetCpus != "" {
+		args = append(args, "--cpuset-cpus", r.CpusetCpus)
+	}
+	if r.CpusetMems != "" {
+		args = append(args, "--cpuset-mems", r.CpusetMems)
+	}
+	if r.Memory != "" {
+		args = append(args, "--memory", r.Memory)
+	}
+	if r.MemorySwap != "" {
+		args = append(args, "--memory-swap", r.MemorySwap)
+	}
+	if r.MemoryReservation != "" {
+		args = append(args, "--memory-reservation", r.MemoryReservation)
+	}
+	if r.PidsLimit != 0 {
+		args = append(args, "--pids-limit", strconv.FormatInt(r.PidsLimit, 10))
+	}
+	for _, ulimit := range r.Ulimits {
+		args = append(args, "--ulimit", ulimit)
+	}
+	return args
+}
+
+// Translate the resource limits to a docker update config, ulimits cannot be changed on a live container
+func (r ResourceLimits) updateConfig() (container.UpdateConfig, error) {
+	var updateConfig container.UpdateConfig
+	if len(r.Ulimits) > 0 {
+		return updateConfig, errors.New("ulimits cannot be updated on a running container")
+	}
+	var err error
+	resources := &updateConfig.Resources
+	resources.CPUShares = r.CpuShares
+	resources.CPUPeriod = r.CpuPeriod
+	resources.CPUQuota = r.CpuQuota
+	resources.CpusetCpus = r.CpusetCpus
+	resources.CpusetMems = r.CpusetMems
+	if r.Cpus != "" {
+		cpus, err := strconv.ParseFloat(r.Cpus, 64)
+		if err != nil {
+			return updateConfig, fmt.Errorf("invalid cpus %q: %w", r.Cpus, err)
+		}
+		resources.NanoCPUs = int64(cpus * 1e9)
+	}
+	if resources.Memory, err = parseMemory(r.Memory); err != nil {
+		return updateConfig, err
+	}
+	if resources.MemorySwap, err = parseMemory(r.MemorySwap); err != nil {
+		return updateConfig, err
+	}
+	if resources.MemoryReservation, err = parseMemory(r.MemoryReservation); err != nil {
+		return updateConfig, err
+	}
+	if r.PidsLimit != 0 {
+		pidsLimit := r.PidsLimit
+		resources.PidsLimit = &pidsLimit
+	}
+	return updateConfig, nil
+}
+
+func parseMemory(size string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	// -1 means unlimited (only valid for swap)
+	if size == "-1" {
+		return -1, nil
+	}
+	bytes, err := units.RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size %q: %w", size, err)
+	}
+	return bytes, nil
+}
+
+// Resource limits docker cannot apply as given, the caller's mistake rather than docker's
+var errInvalidResources = errors.New("invalid resource limits")
+
+// Apply new resource limits to a live service's container
+func updateContainerResources(containerName string, limits ResourceLimits) ([]string, error) {
+	logger.Debug("Updating container resources", zap.String("containerName", containerName))
+	updateConfig, err := limits.updateConfig()
+	if err != nil {
+		logger.Error("Invalid resource limits", zap.String("containerName", containerName), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", errInvalidResources, err)
+	}
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
+		return nil, err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	var resp container.ContainerUpdateOKBody
+	err = retryDocker(ctx, "docker_update", containerName, func() error {
+		var err error
+		resp, err = cli.ContainerUpdate(ctx, containerName, updateConfig)
+		return err
+	})
+	if err != nil {
+		logger.Error("Error updating container resources", zap.String("containerName", containerName), zap.Error(err))
+		if errdefs.IsInvalidParameter(err) {
+			return nil, fmt.Errorf("%w: %v", errInvalidResources, err)
+		}
+		return nil, err
+	}
+	logger.Info("Container resources updated", zap.String("containerName", containerName))
+	return resp.Warnings, nil
+}
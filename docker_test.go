@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"-1", -1, false},
+		{"1024", 1024, false},
+		{"512k", 512 << 10, false},
+		{"512m", 512 << 20, false},
+		{"2g", 2 << 30, false},
+		{"1.5GB", 3 << 29, false},
+		{"lots", 0, true},
+		{"-2", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMemory(tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMemory(%q) error = %v, wantErr %v", tt.size, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMemory(%q) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestResourceLimitsDockerRunArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits ResourceLimits
+		want   []string
+	}{
+		{"empty", ResourceLimits{}, nil},
+		{"cpu", ResourceLimits{CpuShares: 512, CpuPeriod: 100000, CpuQuota: 50000, Cpus: "1.5", CpusetCpus: "0-1", CpusetMems: "0"},
+			[]string{"--cpu-shares", "512", "--cpu-period", "100000", "--cpu-quota", "50000", "--cpus", "1.5", "--cpuset-cpus", "0-1", "--cpuset-mems", "0"}},
+		{"memory", ResourceLimits{Memory: "512m", MemorySwap: "-1", MemoryReservation: "256m"},
+			[]string{"--memory", "512m", "--memory-swap", "-1", "--memory-reservation", "256m"}},
+		{"pids and ulimits", ResourceLimits{PidsLimit: 100, Ulimits: []string{"nofile=1024:2048", "nproc=64"}},
+			[]string{"--pids-limit", "100", "--ulimit", "nofile=1024:2048", "--ulimit", "nproc=64"}},
+	}
+	for _, tt := range tests {
+		if got := tt.limits.dockerRunArgs(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dockerRunArgs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResourceLimitsUpdateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  ResourceLimits
+		check   func(container.Resources) bool
+		wantErr bool
+	}{
+		{"cpus", ResourceLimits{Cpus: "1.5", CpuShares: 512}, func(r container.Resources) bool { return r.NanoCPUs == 1500000000 && r.CPUShares == 512 }, false},
+		{"memory", ResourceLimits{Memory: "512m", MemorySwap: "-1"}, func(r container.Resources) bool { return r.Memory == 512<<20 && r.MemorySwap == -1 }, false},
+		{"pids", ResourceLimits{PidsLimit: 64}, func(r container.Resources) bool { return r.PidsLimit != nil && *r.PidsLimit == 64 }, false},
+		{"invalid cpus", ResourceLimits{Cpus: "many"}, nil, true},
+		{"invalid memory", ResourceLimits{Memory: "lots"}, nil, true},
+		{"ulimits", ResourceLimits{Ulimits: []string{"nofile=1024"}}, nil, true},
+	}
+	for _, tt := range tests {
+		updateConfig, err := tt.limits.updateConfig()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: updateConfig() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.check != nil && !tt.check(updateConfig.Resources) {
+			t.Errorf("%s: updateConfig() = %+v", tt.name, updateConfig.Resources)
+		}
+		// Refused before docker is asked, the API answers 400
+		if tt.wantErr {
+			if _, err := updateContainerResources("svc", tt.limits); !errors.Is(err, errInvalidResources) {
+				t.Errorf("%s: updateContainerResources() error = %v, want errInvalidResources", tt.name, err)
+			}
+		}
+	}
+}
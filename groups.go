@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Services of one application that are checkpointed together as a consistent set
+type Group struct {
+	Name           string           `json:"name"`
+	Members        []string         `json:"members"`
+	Created        time.Time        `json:"created"`
+	LastCheckpoint *GroupCheckpoint `json:"last_checkpoint,omitempty"`
+}
+
+type GroupCheckpoint struct {
+	Group    string    `json:"group"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Only a valid group checkpoint can be restored, an invalid one has members missing or taken at other times
+	Valid   bool                `json:"valid"`
+	Error   string              `json:"error,omitempty"`
+	Members []GroupMemberResult `json:"members"`
+}
+
+type GroupMemberResult struct {
+	Service  string `json:"service"`
+	ImageUrl string `json:"image_url"`
+	//success,failure,timeout,canceled,skipped
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+	Retries int    `json:"retries"`
+	Status  string `json:"status"`
+	// Parameters sent to ff_daemon, after merging the defaults
+	Params json.RawMessage `json:"params,omitempty"`
+	// Run restoring the member from its new image, when the group checkpoint failed after it
+	Restore *ServiceOperation `json:"restore,omitempty"`
+}
+
+// Checkpoint arguments shared by all members, each member's entry in Members is merged over them.
+// A member without its own image_url is checkpointed to <image_url>/<member>.
+type GroupCheckpointBody struct {
+	Checkpoint map[string]interface{}            `json:"checkpoint"`
+	Members    map[string]map[string]interface{} `json:"members"`
+}
+
+type GroupBody struct {
+	Members []string `json:"members"`
+}
+
+var groupsPath = envString("GROUPS_FILE", "groups.json")
+
+var groups = make(map[string]Group)
+
+// Groups with a checkpoint in progress
+var groupsBusy = make(map[string]bool)
+var groupsMu sync.Mutex
+
+func loadGroups() {
+	data, err := os.ReadFile(groupsPath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Error reading groups file", zap.Error(err))
+		return
+	}
+	var saved []Group
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.Error("Error parsing groups file", zap.Error(err))
+		return
+	}
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	for _, group := range saved {
+		groups[group.Name] = group
+	}
+	logger.Info("Groups loaded", zap.Int("count", len(saved)))
+}
+
+// Must be called with groupsMu held
+func saveGroups() error {
+	saved := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		saved = append(saved, group)
+	}
+	return writeJSONFile(groupsPath, saved)
+}
+
+func getGroup(name string) (Group, bool) {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	group, ok := groups[name]
+	return group, ok
+}
+
+// Checkpoint arguments of one member as sent to its ff_daemon
+func (b GroupCheckpointBody) memberBody(member string) ([]byte, CheckpointBody, error) {
+	args := make(map[string]interface{}, len(b.Checkpoint))
+	for k, v := range b.Checkpoint {
+		args[k] = v
+	}
+	if _, ok := b.Members[member]["image_url"]; !ok {
+		if imageUrl, ok := args["image_url"].(string); ok && imageUrl != "" {
+			args["image_url"] = strings.TrimSuffix(imageUrl, "/") + "/" + member
+		}
+	}
+	for k, v := range b.Members[member] {
+		args[k] = v
+	}
+	requestBody, err := json.Marshal(args)
+	if err != nil {
+		return nil, CheckpointBody{}, err
+	}
+	if requestBody, err = applyParamTemplates(member, "checkpoint", requestBody); err != nil {
+		return nil, CheckpointBody{}, err
+	}
+	var checkpointBody CheckpointBody
+	err = json.Unmarshal(requestBody, &checkpointBody)
+	return requestBody, checkpointBody, err
+}
+
+// Pause every member, then unpause, checkpoint and re-pause them one at a time, and finally resume
+// them all. Each member runs only while it is checkpointed, the others stay paused, so no member
+// moves on past the state the others were saved in. A member still runs during its own checkpoint
+// and requests in flight between members are not drained: the set is consistent for applications
+// that only talk to each other while all are running. The first failure stops the checkpoint and
+// makes it invalid, the members are resumed either way and the ones already checkpointed and stopped
+// are restored from their new image, so a failed group is left running. The caller holds every member.
+func checkpointGroup(ctx context.Context, group Group, body GroupCheckpointBody, timeout time.Duration) GroupCheckpoint {
+	result := GroupCheckpoint{Group: group.Name, Started: time.Now(), Valid: true, Members: []GroupMemberResult{}}
+	fail := func(msg string) {
+		if result.Valid {
+			result.Valid = false
+			result.Error = msg
+		}
+	}
+	// Status to put back once the members are resumed, paused containers report "paused"
+	statuses := make(map[string]string)
+	paused := make(map[string]bool)
+	for _, member := range group.Members {
+		service, _ := getService(member)
+		statuses[member] = service.Status
+	}
+
+	for _, member := range group.Members {
+		if err := pauseContainer(member); err != nil {
+			fail("cannot pause " + member + ": " + err.Error())
+			break
+		}
+		paused[member] = true
+	}
+	logger.Info("Group paused", zap.String("group", group.Name), zap.Bool("complete", result.Valid))
+
+	for _, member := range group.Members {
+		requestBody, checkpointBody, err := body.memberBody(member)
+		memberResult := GroupMemberResult{Service: member, ImageUrl: checkpointBody.ImgUrl, Result: "skipped", Params: requestBody}
+		if err != nil {
+			fail("invalid checkpoint arguments for " + member + ": " + err.Error())
+		}
+		if !result.Valid {
+			result.Members = append(result.Members, memberResult)
+			continue
+		}
+		if err := unpauseContainer(member); err != nil {
+			fail("cannot unpause " + member + ": " + err.Error())
+			result.Members = append(result.Members, memberResult)
+			continue
+		}
+		paused[member] = false
+
+		memberCtx, cancel := context.WithTimeout(ctx, timeout)
+		started := time.Now()
+		ffRet, ffMsg, retries := callFastFreeze(memberCtx, 1, requestBody, member)
+		op := newServiceOperation("checkpoint", started, ffRet, ffMsg, retries, memberCtx)
+		cancel()
+		recordServiceOperation(member, op)
+		notifyOperation(member, op, ffMsg, checkpointBody.ImgUrl)
+		memberResult.Result = op.Result
+		memberResult.Message = ffMsg
+		memberResult.Retries = retries
+		if ffRet == 0 {
+			if checkpointBody.LeaveRun {
+				statuses[member] = "running"
+			} else {
+				statuses[member] = "checkpointed"
+			}
+		} else {
+			fail("checkpoint of " + member + " " + op.Result + ": " + ffMsg)
+		}
+		result.Members = append(result.Members, memberResult)
+
+		// Frozen again until the rest of the group is done
+		if result.Valid {
+			if err := pauseContainer(member); err != nil {
+				fail("cannot pause " + member + " again: " + err.Error())
+			} else {
+				paused[member] = true
+			}
+		}
+	}
+
+	for _, member := range group.Members {
+		if paused[member] {
+			if err := unpauseContainer(member); err != nil {
+				logger.Error("Group member left paused", zap.String("containerName", member), zap.String("group", group.Name), zap.Error(err))
+				fail("cannot resume " + member + ": " + err.Error())
+				continue
+			}
+			paused[member] = false
+		}
+		if statuses[member] != "" {
+			updateServiceStatus(member, statuses[member])
+		}
+	}
+	if !result.Valid {
+		for i := range result.Members {
+			member := result.Members[i].Service
+			if result.Members[i].Result == "success" && statuses[member] == "checkpointed" && !paused[member] {
+				result.Members[i].Restore = restoreGroupMember(group.Name, result.Members[i], timeout)
+			}
+		}
+	}
+	for i := range result.Members {
+		if service, ok := getService(result.Members[i].Service); ok {
+			result.Members[i].Status = service.Status
+		}
+	}
+	result.Finished = time.Now()
+	return result
+}
+
+// Run a member stopped by its checkpoint again from the image it was just saved in. The group
+// checkpoint failed, so this is done even when the client is gone.
+func restoreGroupMember(groupName string, member GroupMemberResult, timeout time.Duration) *ServiceOperation {
+	logger.Info("Restoring group member after a failed group checkpoint", zap.String("containerName", member.Service), zap.String("group", groupName))
+	requestBody, _ := json.Marshal(map[string]interface{}{"image_url": member.ImageUrl})
+	requestBody, err := applyParamTemplates(member.Service, "run", requestBody)
+	if err != nil {
+		op := ServiceOperation{Operation: "run", Started: time.Now(), Finished: time.Now(), Result: "failure", Error: err.Error()}
+		return &op
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	started := time.Now()
+	ffRet, ffMsg, retries := callFastFreeze(ctx, 0, requestBody, member.Service)
+	op := newServiceOperation("run", started, ffRet, ffMsg, retries, ctx)
+	recordServiceOperation(member.Service, op)
+	notifyOperation(member.Service, op, ffMsg, member.ImageUrl)
+	if ffRet == 0 {
+		updateServiceStatus(member.Service, "running")
+	} else {
+		logger.Error("Group member left checkpointed", zap.String("containerName", member.Service), zap.String("group", groupName), zap.String("error", ffMsg))
+	}
+	return &op
+}
+
+func listGroupsHandler(c *gin.Context) {
+	groupsMu.Lock()
+	list := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, group)
+	}
+	groupsMu.Unlock()
+	c.IndentedJSON(http.StatusOK, list)
+}
+
+func getGroupHandler(c *gin.Context) {
+	name := c.Param("name")
+	group, ok := getGroup(name)
+	if !ok {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no group name " + name + " found!"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, group)
+}
+
+func updateGroupHandler(c *gin.Context) {
+	name := c.Param("name")
+	var groupBody GroupBody
+	if err := c.ShouldBindJSON(&groupBody); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid group: " + err.Error()})
+		return
+	}
+	if len(groupBody.Members) == 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "A group needs at least one member"})
+		return
+	}
+	seen := make(map[string]bool)
+	for _, member := range groupBody.Members {
+		if member == "" || seen[member] {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Empty or duplicate member " + member})
+			return
+		}
+		seen[member] = true
+	}
+
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	if groupsBusy[name] {
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": "Group " + name + " is being checkpointed"})
+		return
+	}
+	previous, existed := groups[name]
+	group := Group{Name: name, Members: groupBody.Members, Created: time.Now()}
+	if existed {
+		group.Created = previous.Created
+	}
+	groups[name] = group
+	if err := saveGroups(); err != nil {
+		if existed {
+			groups[name] = previous
+		} else {
+			delete(groups, name)
+		}
+		logger.Error("Error saving groups", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the group"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, group)
+}
+
+func deleteGroupHandler(c *gin.Context) {
+	name := c.Param("name")
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	group, ok := groups[name]
+	if !ok {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no group name " + name + " found!"})
+		return
+	}
+	if groupsBusy[name] {
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": "Group " + name + " is being checkpointed"})
+		return
+	}
+	delete(groups, name)
+	if err := saveGroups(); err != nil {
+		groups[name] = group
+		logger.Error("Error saving groups", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the group"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Group " + name + " deleted"})
+}
+
+func groupCheckpointHandler(c *gin.Context) {
+	name := c.Param("name")
+	requestBody, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	var body GroupCheckpointBody
+	if len(requestBody) > 0 {
+		if err := json.Unmarshal(requestBody, &body); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid group checkpoint arguments: " + err.Error()})
+			return
+		}
+	}
+	priority, err := strconv.Atoi(c.DefaultQuery("priority", "0"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid priority value"})
+		return
+	}
+	timeout, err := operationTimeout(c, checkpointTimeout)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout value"})
+		return
+	}
+	group, ok := getGroup(name)
+	if !ok {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no group name " + name + " found!"})
+		return
+	}
+	for member := range body.Members {
+		if !contains(group.Members, member) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": member + " is not a member of group " + name})
+			return
+		}
+	}
+	weight := 0
+	for _, member := range group.Members {
+		if !isSubscribed(member) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "no service name " + member + " found!"})
+			return
+		}
+		if status, err := getContainerStatus(member); err != nil || status != "running" {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": "Container " + member + " is not running"})
+			return
+		}
+		_, checkpointBody, err := body.memberBody(member)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid checkpoint arguments for " + member})
+			return
+		}
+		weight += cpuBudgetWeight(checkpointBody.Cpu_budget)
+	}
+
+	groupsMu.Lock()
+	if groupsBusy[name] {
+		groupsMu.Unlock()
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": "Group " + name + " is already being checkpointed"})
+		return
+	}
+	groupsBusy[name] = true
+	groupsMu.Unlock()
+	defer func() {
+		groupsMu.Lock()
+		delete(groupsBusy, name)
+		groupsMu.Unlock()
+	}()
+
+	// No individual run, checkpoint or convergence step touches a member until the group is done
+	for i, member := range group.Members {
+		if err := acquireService(member, "group "+name+" checkpoint"); err != nil {
+			for _, held := range group.Members[:i] {
+				releaseService(held)
+			}
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	defer func() {
+		for _, member := range group.Members {
+			releaseService(member)
+		}
+	}()
+
+	// The whole group is admitted at once, nothing is paused while it waits
+	ticket, err := admission.acquire(c.Request.Context(), name, "group_checkpoint", weight, priority)
+	if err != nil {
+		logger.Info("Group checkpoint abandoned while queued", zap.String("group", name), zap.Error(err))
+		return
+	}
+	defer admission.release(ticket)
+
+	logger.Info("Checkpointing group", zap.String("group", name), zap.Strings("members", group.Members))
+	result := checkpointGroup(c.Request.Context(), group, body, timeout)
+
+	groupsMu.Lock()
+	if current, ok := groups[name]; ok {
+		current.LastCheckpoint = &result
+		groups[name] = current
+		if err := saveGroups(); err != nil {
+			logger.Error("Error saving groups", zap.Error(err))
+		}
+	}
+	groupsMu.Unlock()
+
+	event := "group.checkpoint.completed"
+	if !result.Valid {
+		event = "group.checkpoint.failed"
+	}
+	notify(event, name, map[string]interface{}{"valid": result.Valid, "error": result.Error, "members": result.Members})
+	if !result.Valid {
+		logger.Error("Group checkpoint invalid", zap.String("group", name), zap.String("error", result.Error))
+		c.IndentedJSON(http.StatusInternalServerError, result)
+		return
+	}
+	logger.Info("Group checkpointed", zap.String("group", name))
+	c.IndentedJSON(http.StatusOK, result)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gin-gonic/gin"
+)
+
+// Simulated router with the group routes, groups kept in the test's temp dir
+func groupRouter(t *testing.T) (*gin.Engine, *simDockerClient) {
+	t.Helper()
+	r, sim := simulatedRouter(t)
+	r.PUT("/cm_controller/v1/group/:name", updateGroupHandler)
+	r.GET("/cm_controller/v1/group/:name", getGroupHandler)
+	r.POST("/cm_controller/v1/group/:name/checkpoint", groupCheckpointHandler)
+	previousPath := groupsPath
+	groupsPath = filepath.Join(t.TempDir(), "groups.json")
+	t.Cleanup(func() {
+		groupsMu.Lock()
+		groups = make(map[string]Group)
+		groupsMu.Unlock()
+		groupsPath = previousPath
+	})
+	return r, sim
+}
+
+// Start the members, run the ones given and put them in a group
+func startGroup(t *testing.T, r *gin.Engine, name string, members []string, running []string) {
+	t.Helper()
+	for _, member := range members {
+		startSimulated(t, r, member)
+	}
+	for _, member := range running {
+		if code, response := serve(r, "POST", "/run/"+member, `{}`); code != http.StatusOK {
+			t.Fatalf("run %s: %d %v", member, code, response)
+		}
+	}
+	body, _ := json.Marshal(GroupBody{Members: members})
+	if code, response := serve(r, "PUT", "/group/"+name, string(body)); code != http.StatusOK {
+		t.Fatalf("group: %d %v", code, response)
+	}
+}
+
+func checkpointGroupRequest(t *testing.T, r *gin.Engine, name string, body string) (int, GroupCheckpoint) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/cm_controller/v1/group/"+name+"/checkpoint", strings.NewReader(body)))
+	var result GroupCheckpoint
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return w.Code, result
+}
+
+func TestGroupCheckpointOrder(t *testing.T) {
+	r, sim := groupRouter(t)
+	members := []string{"order-a", "order-b", "order-c"}
+	startGroup(t, r, "order", members, members)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, _ := sim.Events(ctx, types.EventsOptions{Filters: filters.NewArgs(
+		filters.Arg("type", "container"), filters.Arg("event", "pause"), filters.Arg("event", "unpause"))})
+
+	code, result := checkpointGroupRequest(t, r, "order", `{"checkpoint":{"image_url":"file:/tmp/order"}}`)
+	if code != http.StatusOK || !result.Valid || len(result.Members) != len(members) {
+		t.Fatalf("group checkpoint: %d %+v", code, result)
+	}
+	for i, member := range result.Members {
+		if member.Service != members[i] || member.Result != "success" || member.Status != "checkpointed" || member.ImageUrl != "file:/tmp/order/"+members[i] || member.Restore != nil {
+			t.Errorf("member %d: %+v", i, member)
+		}
+	}
+
+	// All paused first, then each one runs alone for its checkpoint, then all resumed
+	want := []string{
+		"pause order-a", "pause order-b", "pause order-c",
+		"unpause order-a", "pause order-a",
+		"unpause order-b", "pause order-b",
+		"unpause order-c", "pause order-c",
+		"unpause order-a", "unpause order-b", "unpause order-c",
+	}
+	var got []events.Message
+	for len(got) < len(want) {
+		select {
+		case message := <-messages:
+			got = append(got, message)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d docker events, want %d", len(got), len(want))
+		}
+	}
+	for i, message := range got {
+		if name := message.Action + " " + message.Actor.Attributes["name"]; name != want[i] {
+			t.Errorf("docker event %d: %s, want %s", i, name, want[i])
+		}
+	}
+	// Each member was checkpointed in its own unpaused window
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	for i, member := range members {
+		checkpoint, ok := sim.checkpoints["file:/tmp/order/"+member]
+		unpaused, paused := got[3+2*i].TimeNano, got[4+2*i].TimeNano
+		if !ok || checkpoint.Time.UnixNano() < unpaused || checkpoint.Time.UnixNano() > paused {
+			t.Errorf("%s checkpointed at %v outside of its window", member, checkpoint.Time)
+		}
+	}
+}
+
+func TestGroupCheckpointPartialFailure(t *testing.T) {
+	r, sim := groupRouter(t)
+	// The application of partial-b is not running, its checkpoint fails
+	members := []string{"partial-d", "partial-a", "partial-b", "partial-c"}
+	startGroup(t, r, "partial", members, []string{"partial-d", "partial-a", "partial-c"})
+
+	body := `{"checkpoint":{"image_url":"file:/tmp/partial"},"members":{"partial-a":{"leave_running":true}}}`
+	code, result := checkpointGroupRequest(t, r, "partial", body)
+	if code != http.StatusInternalServerError || result.Valid || !strings.Contains(result.Error, "partial-b") {
+		t.Fatalf("group checkpoint: %d %+v", code, result)
+	}
+
+	tests := []struct {
+		member      string
+		wantResult  string
+		wantRestore string
+		wantStatus  string
+	}{
+		// Checkpointed and stopped, restored from its new image
+		{"partial-d", "success", "success", "running"},
+		// Left running by its checkpoint, nothing to restore
+		{"partial-a", "success", "", "running"},
+		{"partial-b", "failure", "", "standby"},
+		{"partial-c", "skipped", "", "running"},
+	}
+	for i, tt := range tests {
+		member := result.Members[i]
+		restore := ""
+		if member.Restore != nil {
+			restore = member.Restore.Result
+		}
+		if member.Service != tt.member || member.Result != tt.wantResult || restore != tt.wantRestore || member.Status != tt.wantStatus {
+			t.Errorf("%s: %+v, restore %q", tt.member, member, restore)
+		}
+		if service, _ := getService(tt.member); service.Status != tt.wantStatus {
+			t.Errorf("%s: service status %s, want %s", tt.member, service.Status, tt.wantStatus)
+		}
+	}
+	// The restore ran from the image the member was just checkpointed to, and left nothing paused
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if logs := sim.containers["partial-d"].logs.String(); !strings.Contains(logs, "run requested (image_url=file:/tmp/partial/partial-d)") || !strings.Contains(logs, "Application restored successfully") {
+		t.Errorf("partial-d logs %s", logs)
+	}
+	for _, member := range members {
+		if status := sim.containers[member].Status; status != "running" {
+			t.Errorf("%s container %s", member, status)
+		}
+	}
+}
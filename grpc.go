@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"cm_controller/grpcapi"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grpcapi/cm_controller.proto
+
+// Port of the gRPC API (--grpc-port), not served when empty
+var grpcPort string
+
+// How often CheckpointWithProgress reports while a checkpoint is queued or running
+var progressInterval = envDuration("GRPC_PROGRESS_INTERVAL", time.Second)
+
+const apiPrefix = "/cm_controller/v1"
+
+// Request messages are sent to the REST handlers as json with the REST field names
+var requestJSON = protojson.MarshalOptions{UseProtoNames: true}
+var responseJSON = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// The gRPC API. Each RPC is served by the REST handler of the route it mirrors, called in-process
+// through the gin engine, so both APIs share admission, audit and validation.
+type grpcServer struct {
+	grpcapi.UnimplementedCmControllerServer
+	engine *gin.Engine
+}
+
+func serveGrpc(r *gin.Engine) error {
+	listener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	grpcapi.RegisterCmControllerServer(server, &grpcServer{engine: r})
+	logger.Info("Serving the gRPC API", zap.String("port", grpcPort))
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			logger.Error("gRPC server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Response writer handing the body of an in-process REST call over as it is written
+type bridgeWriter struct {
+	header  http.Header
+	status  int
+	started chan struct{}
+	once    sync.Once
+	body    *io.PipeWriter
+}
+
+func (w *bridgeWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bridgeWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		close(w.started)
+	})
+}
+
+func (w *bridgeWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// Writes already reach the reader unbuffered
+func (w *bridgeWriter) Flush() {}
+
+// Serve a REST call through the gin engine, returning its status and its body as it is written.
+// The caller's id, request id and address are passed on for the audit log.
+func (s *grpcServer) serve(ctx context.Context, method string, path string, body []byte) (int, *io.PipeReader, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, apiPrefix+path, reader)
+	if err != nil {
+		return 0, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, header := range []string{"X-Request-ID", "X-Caller-Id", "User-Agent", "Authorization"} {
+			if values := md.Get(header); len(values) > 0 {
+				req.Header.Set(header, values[0])
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		req.RemoteAddr = p.Addr.String()
+	}
+	pr, pw := io.Pipe()
+	w := &bridgeWriter{header: http.Header{}, started: make(chan struct{}), body: pw}
+	go func() {
+		s.engine.ServeHTTP(w, req)
+		w.WriteHeader(http.StatusOK)
+		pw.Close()
+	}()
+	<-w.started
+	return w.status, pr, nil
+}
+
+// A failed REST call as a gRPC error: the REST error message, with the whole REST body as a Struct detail
+func restError(httpStatus int, body []byte) error {
+	message := http.StatusText(httpStatus)
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) == nil {
+		if msg, ok := fields["error"].(string); ok && msg != "" {
+			message = msg
+		} else if msg, ok := fields["message"].(string); ok && msg != "" {
+			message = msg
+		}
+	}
+	st := status.New(grpcCode(httpStatus), message)
+	if fields != nil {
+		if detail, err := structpb.NewStruct(fields); err == nil {
+			if withDetail, err := st.WithDetails(detail); err == nil {
+				st = withDetail
+			}
+		}
+	}
+	return st.Err()
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+func requestBody(in proto.Message) ([]byte, error) {
+	if in == nil || !in.ProtoReflect().IsValid() {
+		return []byte("{}"), nil
+	}
+	body, err := requestJSON.Marshal(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return body, nil
+}
+
+// Unary REST call, the json answer is decoded into out. A json array answer is decoded into
+// out's listField.
+func (s *grpcServer) call(ctx context.Context, method string, path string, in proto.Message, out proto.Message, listField string) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = requestBody(in); err != nil {
+			return err
+		}
+	}
+	httpStatus, reader, err := s.serve(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if httpStatus >= 400 {
+		return restError(httpStatus, data)
+	}
+	if listField != "" {
+		data = append(append([]byte(`{"`+listField+`":`), data...), '}')
+	}
+	if err := responseJSON.Unmarshal(data, out); err != nil {
+		return status.Error(codes.Internal, "Cannot decode the response: "+err.Error())
+	}
+	return nil
+}
+
+// Streamed REST call, the body is read until the handler is done
+func (s *grpcServer) openStream(ctx context.Context, method string, path string, in proto.Message) (*io.PipeReader, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = requestBody(in); err != nil {
+			return nil, err
+		}
+	}
+	httpStatus, reader, err := s.serve(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if httpStatus >= 400 {
+		data, _ := io.ReadAll(reader)
+		return nil, restError(httpStatus, data)
+	}
+	return reader, nil
+}
+
+// Streamed REST call answering one json document per line, fn gets each of them
+func (s *grpcServer) streamLines(ctx context.Context, method string, path string, in proto.Message, fn func([]byte) error) error {
+	reader, err := s.openStream(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return scanner.Err()
+}
+
+func withQuery(path string, query url.Values) string {
+	if encoded := query.Encode(); encoded != "" {
+		return path + "?" + encoded
+	}
+	return path
+}
+
+func operationQuery(priority int32, timeout string) url.Values {
+	query := url.Values{}
+	if priority != 0 {
+		query.Set("priority", strconv.Itoa(int(priority)))
+	}
+	if timeout != "" {
+		query.Set("timeout", timeout)
+	}
+	return query
+}
+
+func (s *grpcServer) Up(ctx context.Context, _ *emptypb.Empty) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "GET", "/up", nil, out, "")
+}
+
+func (s *grpcServer) Run(ctx context.Context, req *grpcapi.RunRequest) (*grpcapi.OperationReply, error) {
+	out := &grpcapi.OperationReply{}
+	path := withQuery("/run/"+url.PathEscape(req.Name), operationQuery(req.Priority, req.Timeout))
+	return out, s.call(ctx, "POST", path, req.Args, out, "")
+}
+
+func (s *grpcServer) Checkpoint(ctx context.Context, req *grpcapi.CheckpointRequest) (*grpcapi.OperationReply, error) {
+	out := &grpcapi.OperationReply{}
+	path := withQuery("/checkpoint/"+url.PathEscape(req.Name), operationQuery(req.Priority, req.Timeout))
+	return out, s.call(ctx, "POST", path, req.Args, out, "")
+}
+
+// Where a checkpoint of the service stands: waiting for admission or with ff_daemon
+func checkpointProgress(name string, started time.Time) *grpcapi.CheckpointProgress {
+	progress := &grpcapi.CheckpointProgress{Phase: "checkpointing", Service: name, ElapsedMs: time.Since(started).Milliseconds()}
+	for _, ticket := range admission.status(name).Queued {
+		if ticket.Operation == "checkpoint" {
+			progress.Phase = "queued"
+			progress.Position = int32(ticket.Position)
+		}
+	}
+	if service, ok := getService(name); ok {
+		progress.Status = service.Status
+		progress.NeedsReconcile = service.NeedsReconcile
+	}
+	return progress
+}
+
+func (s *grpcServer) CheckpointWithProgress(req *grpcapi.CheckpointRequest, stream grpcapi.CmController_CheckpointWithProgressServer) error {
+	ctx := stream.Context()
+	started := time.Now()
+	type outcome struct {
+		reply *grpcapi.OperationReply
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		reply, err := s.Checkpoint(ctx, req)
+		done <- outcome{reply, err}
+	}()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	if err := stream.Send(checkpointProgress(req.Name, started)); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ticker.C:
+			if err := stream.Send(checkpointProgress(req.Name, started)); err != nil {
+				return err
+			}
+		case result := <-done:
+			final := checkpointProgress(req.Name, started)
+			if result.err == nil {
+				final.Phase = "completed"
+				final.Message = result.reply.Message
+				final.Retries = result.reply.Retries
+				final.Params = result.reply.Params
+				return stream.Send(final)
+			}
+			final.Phase = "failed"
+			st := status.Convert(result.err)
+			final.Message = st.Message()
+			for _, detail := range st.Details() {
+				if fields, ok := detail.(*structpb.Struct); ok {
+					final.Retries = int32(fields.Fields["retries"].GetNumberValue())
+					final.NeedsReconcile = final.NeedsReconcile || fields.Fields["needs_reconcile"].GetBoolValue()
+					final.Logs = fields.Fields["logs"].GetStringValue()
+					final.Params = fields.Fields["params"].GetStructValue()
+				}
+			}
+			if err := stream.Send(final); err != nil {
+				return err
+			}
+			return result.err
+		}
+	}
+}
+
+func (s *grpcServer) Subscribe(ctx context.Context, req *grpcapi.SubscribeRequest) (*grpcapi.Service, error) {
+	out := &grpcapi.Service{}
+	return out, s.call(ctx, "POST", withQuery("/subscribe", url.Values{"container_name": {req.ContainerName}}), nil, out, "")
+}
+
+func (s *grpcServer) Unsubscribe(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "POST", "/unsubscribe/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) Start(ctx context.Context, req *grpcapi.StartRequest) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "POST", "/start", req, out, "")
+}
+
+func (s *grpcServer) Stop(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "POST", "/stop/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) Remove(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/remove/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) GetContainerInfo(ctx context.Context, req *grpcapi.ServiceName) (*structpb.Struct, error) {
+	out := &structpb.Struct{}
+	return out, s.call(ctx, "GET", "/service/container_info/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) GetService(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.Service, error) {
+	out := &grpcapi.Service{}
+	return out, s.call(ctx, "GET", "/service/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) ListServices(ctx context.Context, _ *emptypb.Empty) (*grpcapi.ServiceList, error) {
+	out := &grpcapi.ServiceList{}
+	return out, s.call(ctx, "GET", "/service", nil, out, "services")
+}
+
+func (s *grpcServer) GetServiceHealth(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.ServiceHealth, error) {
+	out := &grpcapi.ServiceHealth{}
+	return out, s.call(ctx, "GET", "/service/"+url.PathEscape(req.Name)+"/health", nil, out, "")
+}
+
+func (s *grpcServer) UpdateResources(ctx context.Context, req *grpcapi.UpdateResourcesRequest) (*grpcapi.UpdateResourcesReply, error) {
+	out := &grpcapi.UpdateResourcesReply{}
+	return out, s.call(ctx, "PATCH", "/service/"+url.PathEscape(req.Name)+"/resources", req.Resources, out, "")
+}
+
+func (s *grpcServer) GetServiceStats(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.ServiceStats, error) {
+	out := &grpcapi.ServiceStats{}
+	return out, s.call(ctx, "GET", "/service/"+url.PathEscape(req.Name)+"/stats", nil, out, "")
+}
+
+func (s *grpcServer) StreamServiceStats(req *grpcapi.ServiceName, stream grpcapi.CmController_StreamServiceStatsServer) error {
+	return s.streamLines(stream.Context(), "GET", "/service/"+url.PathEscape(req.Name)+"/stats?stream=true", nil, func(line []byte) error {
+		stats := &grpcapi.ServiceStats{}
+		if err := responseJSON.Unmarshal(line, stats); err != nil {
+			return status.Error(codes.Internal, "Cannot decode the stats: "+err.Error())
+		}
+		return stream.Send(stats)
+	})
+}
+
+func (s *grpcServer) GetAllServicesStats(ctx context.Context, _ *emptypb.Empty) (*grpcapi.AggregateStats, error) {
+	out := &grpcapi.AggregateStats{}
+	return out, s.call(ctx, "GET", "/stats", nil, out, "")
+}
+
+func (s *grpcServer) GetServiceLogs(req *grpcapi.LogsRequest, stream grpcapi.CmController_GetServiceLogsServer) error {
+	query := url.Values{}
+	if req.Stdout || req.Stderr {
+		query.Set("stdout", strconv.FormatBool(req.Stdout))
+		query.Set("stderr", strconv.FormatBool(req.Stderr))
+	}
+	for key, value := range map[string]string{"since": req.Since, "until": req.Until, "tail": req.Tail} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	query.Set("timestamps", strconv.FormatBool(req.Timestamps))
+	query.Set("follow", strconv.FormatBool(req.Follow))
+	reader, err := s.openStream(stream.Context(), "GET", withQuery("/service/"+url.PathEscape(req.Name)+"/logs", query), nil)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&grpcapi.LogChunk{Data: append([]byte(nil), buf[:n]...)}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+func (s *grpcServer) PullImage(req *grpcapi.PullRequest, stream grpcapi.CmController_PullImageServer) error {
+	var pullErr string
+	err := s.streamLines(stream.Context(), "POST", "/images/pull", req, func(line []byte) error {
+		progress := &grpcapi.PullProgress{}
+		if err := responseJSON.Unmarshal(line, progress); err != nil {
+			return status.Error(codes.Internal, "Cannot decode the pull progress: "+err.Error())
+		}
+		if progress.Error != "" {
+			pullErr = progress.Error
+		}
+		return stream.Send(progress)
+	})
+	if err == nil && pullErr != "" {
+		return status.Error(codes.Internal, "Failed to pull the image:"+pullErr)
+	}
+	return err
+}
+
+func (s *grpcServer) ListImages(ctx context.Context, _ *emptypb.Empty) (*grpcapi.ImageList, error) {
+	out := &grpcapi.ImageList{}
+	return out, s.call(ctx, "GET", "/images", nil, out, "images")
+}
+
+func (s *grpcServer) Preflight(ctx context.Context, req *grpcapi.PreflightRequest) (*grpcapi.PreflightReport, error) {
+	out := &grpcapi.PreflightReport{}
+	query := url.Values{}
+	if req.Image != "" {
+		query.Set("image", req.Image)
+	}
+	return out, s.call(ctx, "GET", withQuery("/node/preflight", query), nil, out, "")
+}
+
+func (s *grpcServer) GetAudit(ctx context.Context, req *grpcapi.AuditRequest) (*grpcapi.AuditLog, error) {
+	out := &grpcapi.AuditLog{}
+	query := url.Values{}
+	for key, value := range map[string]string{"since": req.Since, "until": req.Until, "service": req.Service, "operation": req.Operation} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if req.Limit != 0 {
+		query.Set("limit", strconv.Itoa(int(req.Limit)))
+	}
+	return out, s.call(ctx, "GET", withQuery("/audit", query), nil, out, "entries")
+}
+
+func (s *grpcServer) GetAdmission(ctx context.Context, req *grpcapi.AdmissionRequest) (*grpcapi.AdmissionStatus, error) {
+	out := &grpcapi.AdmissionStatus{}
+	query := url.Values{}
+	if req.Service != "" {
+		query.Set("service", req.Service)
+	}
+	return out, s.call(ctx, "GET", withQuery("/admission", query), nil, out, "")
+}
+
+func (s *grpcServer) GetMetrics(ctx context.Context, _ *emptypb.Empty) (*grpcapi.Metrics, error) {
+	out := &grpcapi.Metrics{}
+	return out, s.call(ctx, "GET", "/metrics", nil, out, "")
+}
+
+func (s *grpcServer) CreateWebhook(ctx context.Context, req *grpcapi.Webhook) (*grpcapi.Webhook, error) {
+	out := &grpcapi.Webhook{}
+	return out, s.call(ctx, "POST", "/webhooks", req, out, "")
+}
+
+func (s *grpcServer) ListWebhooks(ctx context.Context, req *grpcapi.ListWebhooksRequest) (*grpcapi.WebhookList, error) {
+	out := &grpcapi.WebhookList{}
+	query := url.Values{}
+	if req.Service != "" {
+		query.Set("service", req.Service)
+	}
+	return out, s.call(ctx, "GET", withQuery("/webhooks", query), nil, out, "webhooks")
+}
+
+func (s *grpcServer) DeleteWebhook(ctx context.Context, req *grpcapi.WebhookId) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/webhooks/"+url.PathEscape(req.Id), nil, out, "")
+}
+
+func (s *grpcServer) GetDeadLetters(ctx context.Context, req *grpcapi.DeadLettersRequest) (*grpcapi.DeadLetterList, error) {
+	out := &grpcapi.DeadLetterList{}
+	query := url.Values{}
+	if req.Limit != 0 {
+		query.Set("limit", strconv.Itoa(int(req.Limit)))
+	}
+	if req.Webhook != "" {
+		query.Set("webhook", req.Webhook)
+	}
+	return out, s.call(ctx, "GET", withQuery("/webhooks/deadletter", query), nil, out, "dead_letters")
+}
+
+func (s *grpcServer) WatchEvents(req *grpcapi.WatchEventsRequest, stream grpcapi.CmController_WatchEventsServer) error {
+	for _, event := range req.Events {
+		if !contains(webhookEvents, event) {
+			return status.Error(codes.InvalidArgument, "Unknown event "+event)
+		}
+	}
+	filter := Webhook{Service: req.Service, Events: req.Events}
+	events, stop := watchEvents()
+	defer stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if !filter.wants(event.Event, event.Service) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			out := &grpcapi.Event{}
+			if err := responseJSON.Unmarshal(data, out); err != nil {
+				logger.Error("Error encoding event", zap.String("containerName", event.Service), zap.Error(err))
+				continue
+			}
+			if err := stream.Send(out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *grpcServer) ListGroups(ctx context.Context, _ *emptypb.Empty) (*grpcapi.GroupList, error) {
+	out := &grpcapi.GroupList{}
+	return out, s.call(ctx, "GET", "/group", nil, out, "groups")
+}
+
+func (s *grpcServer) GetGroup(ctx context.Context, req *grpcapi.GroupName) (*grpcapi.Group, error) {
+	out := &grpcapi.Group{}
+	return out, s.call(ctx, "GET", "/group/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) UpdateGroup(ctx context.Context, req *grpcapi.UpdateGroupRequest) (*grpcapi.Group, error) {
+	out := &grpcapi.Group{}
+	body := &grpcapi.UpdateGroupRequest{Members: req.Members}
+	return out, s.call(ctx, "PUT", "/group/"+url.PathEscape(req.Name), body, out, "")
+}
+
+func (s *grpcServer) DeleteGroup(ctx context.Context, req *grpcapi.GroupName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/group/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) CheckpointGroup(ctx context.Context, req *grpcapi.GroupCheckpointRequest) (*grpcapi.GroupCheckpoint, error) {
+	out := &grpcapi.GroupCheckpoint{}
+	body := &grpcapi.GroupCheckpointRequest{Checkpoint: req.Checkpoint, Members: req.Members}
+	path := withQuery("/group/"+url.PathEscape(req.Name)+"/checkpoint", operationQuery(req.Priority, req.Timeout))
+	return out, s.call(ctx, "POST", path, body, out, "")
+}
+
+func (s *grpcServer) ListDesired(ctx context.Context, _ *emptypb.Empty) (*grpcapi.DesiredList, error) {
+	out := &grpcapi.DesiredList{}
+	return out, s.call(ctx, "GET", "/desired", nil, out, "services")
+}
+
+func (s *grpcServer) GetDesired(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.DesiredStatus, error) {
+	out := &grpcapi.DesiredStatus{}
+	return out, s.call(ctx, "GET", "/desired/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) PutDesired(ctx context.Context, req *grpcapi.PutDesiredRequest) (*grpcapi.DesiredStatus, error) {
+	out := &grpcapi.DesiredStatus{}
+	return out, s.call(ctx, "PUT", "/desired/"+url.PathEscape(req.Name), req.Spec, out, "")
+}
+
+func (s *grpcServer) DeleteDesired(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/desired/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) GetManifest(ctx context.Context, _ *emptypb.Empty) (*grpcapi.ManifestStatus, error) {
+	out := &grpcapi.ManifestStatus{}
+	return out, s.call(ctx, "GET", "/manifest", nil, out, "")
+}
+
+func (s *grpcServer) GetReconcileReport(ctx context.Context, _ *emptypb.Empty) (*grpcapi.ReconcileReport, error) {
+	out := &grpcapi.ReconcileReport{}
+	return out, s.call(ctx, "GET", "/reconcile", nil, out, "")
+}
+
+func (s *grpcServer) ApplyReconcile(ctx context.Context, req *grpcapi.ReconcileRequest) (*grpcapi.ReconcileResultList, error) {
+	out := &grpcapi.ReconcileResultList{}
+	return out, s.call(ctx, "POST", "/reconcile", req, out, "results")
+}
+
+func (s *grpcServer) ListSecrets(ctx context.Context, _ *emptypb.Empty) (*grpcapi.SecretList, error) {
+	out := &grpcapi.SecretList{}
+	return out, s.call(ctx, "GET", "/secrets", nil, out, "secrets")
+}
+
+func (s *grpcServer) PutSecret(ctx context.Context, req *grpcapi.PutSecretRequest) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	body := &grpcapi.PutSecretRequest{Value: req.Value}
+	return out, s.call(ctx, "PUT", "/secrets/"+url.PathEscape(req.Name), body, out, "")
+}
+
+func (s *grpcServer) DeleteSecret(ctx context.Context, req *grpcapi.SecretName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/secrets/"+url.PathEscape(req.Name), nil, out, "")
+}
+
+func (s *grpcServer) GetWorkerTemplates(ctx context.Context, _ *emptypb.Empty) (*grpcapi.ParamTemplates, error) {
+	out := &grpcapi.ParamTemplates{}
+	return out, s.call(ctx, "GET", "/templates", nil, out, "")
+}
+
+func (s *grpcServer) PutWorkerTemplates(ctx context.Context, req *grpcapi.ParamTemplates) (*grpcapi.ParamTemplates, error) {
+	out := &grpcapi.ParamTemplates{}
+	return out, s.call(ctx, "PUT", "/templates", req, out, "")
+}
+
+func (s *grpcServer) DeleteWorkerTemplates(ctx context.Context, _ *emptypb.Empty) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/templates", nil, out, "")
+}
+
+func (s *grpcServer) GetServiceTemplates(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.ParamTemplates, error) {
+	out := &grpcapi.ParamTemplates{}
+	return out, s.call(ctx, "GET", "/service/"+url.PathEscape(req.Name)+"/templates", nil, out, "")
+}
+
+func (s *grpcServer) PutServiceTemplates(ctx context.Context, req *grpcapi.PutServiceTemplatesRequest) (*grpcapi.ParamTemplates, error) {
+	out := &grpcapi.ParamTemplates{}
+	return out, s.call(ctx, "PUT", "/service/"+url.PathEscape(req.Name)+"/templates", req.Templates, out, "")
+}
+
+func (s *grpcServer) DeleteServiceTemplates(ctx context.Context, req *grpcapi.ServiceName) (*grpcapi.Message, error) {
+	out := &grpcapi.Message{}
+	return out, s.call(ctx, "DELETE", "/service/"+url.PathEscape(req.Name)+"/templates", nil, out, "")
+}
+
+func (s *grpcServer) GetLogLevel(ctx context.Context, _ *emptypb.Empty) (*grpcapi.LogLevel, error) {
+	out := &grpcapi.LogLevel{}
+	return out, s.call(ctx, "GET", "/log/level", nil, out, "")
+}
+
+func (s *grpcServer) SetLogLevel(ctx context.Context, req *grpcapi.LogLevel) (*grpcapi.LogLevel, error) {
+	out := &grpcapi.LogLevel{}
+	return out, s.call(ctx, "PUT", "/log/level", req, out, "")
+}
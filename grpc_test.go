@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cm_controller/grpcapi"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// gRPC client of a server over the simulated router, connected in memory
+func grpcClient(t *testing.T) (grpcapi.CmControllerClient, *simDockerClient) {
+	t.Helper()
+	r, sim := simulatedRouter(t)
+	r.POST("/cm_controller/v1/subscribe", subscribeHandler)
+	r.GET("/cm_controller/v1/service/:name/logs", getServiceLogsHandler)
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	grpcapi.RegisterCmControllerServer(server, &grpcServer{engine: r})
+	go server.Serve(listener)
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return grpcapi.NewCmControllerClient(conn), sim
+}
+
+func TestGrpcCode(t *testing.T) {
+	tests := []struct {
+		httpStatus int
+		want       codes.Code
+	}{
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, codes.Unauthenticated},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusConflict, codes.AlreadyExists},
+		{http.StatusUnprocessableEntity, codes.FailedPrecondition},
+		{http.StatusTooManyRequests, codes.ResourceExhausted},
+		{http.StatusBadGateway, codes.Unavailable},
+		{http.StatusServiceUnavailable, codes.Unavailable},
+		{http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{http.StatusInternalServerError, codes.Internal},
+		{http.StatusTeapot, codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := grpcCode(tt.httpStatus); got != tt.want {
+			t.Errorf("grpcCode(%d) = %s, want %s", tt.httpStatus, got, tt.want)
+		}
+	}
+}
+
+func TestGrpcUnary(t *testing.T) {
+	client, sim := grpcClient(t)
+	ctx := context.Background()
+	if _, err := sim.run([]string{"docker", "run", "-d", "--name", "grpc-svc", "-p", "17931:7878", "img"}); err != nil {
+		t.Fatal(err)
+	}
+	sim.run([]string{"docker", "run", "-d", "--name", "grpc-noport", "img"})
+
+	service, err := client.Subscribe(ctx, &grpcapi.SubscribeRequest{ContainerName: "grpc-svc"})
+	if err != nil || service.ContainerName != "grpc-svc" || service.DaemonPort != "17931" || service.Image != "img" {
+		t.Fatalf("Subscribe() = %v, %v", service, err)
+	}
+	reply, err := client.Run(ctx, &grpcapi.RunRequest{Name: "grpc-svc", Args: &grpcapi.RunArgs{AppArgs: "--fast"}})
+	if err != nil || reply.Params.AsMap()["app_args"] != "--fast" {
+		t.Fatalf("Run() = %v, %v", reply, err)
+	}
+	if service, err := client.GetService(ctx, &grpcapi.ServiceName{Name: "grpc-svc"}); err != nil || service.Status != "running" || service.LastOperation.GetOperation() != "run" {
+		t.Errorf("GetService() = %v, %v", service, err)
+	}
+
+	// REST errors keep their message, with the REST body as a detail
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{"unknown service", func() error {
+			_, err := client.GetService(ctx, &grpcapi.ServiceName{Name: "missing"})
+			return err
+		}, codes.InvalidArgument, "no service name missing found!"},
+		{"container not found", func() error {
+			_, err := client.Subscribe(ctx, &grpcapi.SubscribeRequest{ContainerName: "missing"})
+			return err
+		}, codes.NotFound, "container not found"},
+		{"already subscribed", func() error {
+			_, err := client.Subscribe(ctx, &grpcapi.SubscribeRequest{ContainerName: "grpc-svc"})
+			return err
+		}, codes.AlreadyExists, "already subscribed"},
+		{"daemon port not published", func() error {
+			_, err := client.Subscribe(ctx, &grpcapi.SubscribeRequest{ContainerName: "grpc-noport"})
+			return err
+		}, codes.FailedPrecondition, "7878/tcp"},
+		{"ff_daemon failure", func() error {
+			_, err := client.Run(ctx, &grpcapi.RunRequest{Name: "grpc-svc"})
+			return err
+		}, codes.Internal, ""},
+		{"invalid timeout", func() error {
+			_, err := client.Checkpoint(ctx, &grpcapi.CheckpointRequest{Name: "grpc-svc", Timeout: "soon"})
+			return err
+		}, codes.InvalidArgument, "timeout"},
+	}
+	for _, tt := range tests {
+		st, _ := status.FromError(tt.call())
+		if st.Code() != tt.wantCode || !strings.Contains(st.Message(), tt.wantMsg) {
+			t.Errorf("%s: %s %q, want %s %q", tt.name, st.Code(), st.Message(), tt.wantCode, tt.wantMsg)
+			continue
+		}
+		if details := st.Details(); len(details) != 1 {
+			t.Errorf("%s: details %v", tt.name, details)
+		} else if detail, ok := details[0].(*structpb.Struct); !ok || detail.Fields["error"] == nil && detail.Fields["message"] == nil {
+			t.Errorf("%s: detail %v", tt.name, details[0])
+		}
+	}
+}
+
+func TestGrpcServiceLogs(t *testing.T) {
+	client, sim := grpcClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := sim.run([]string{"docker", "run", "-d", "--name", "grpc-logs", "-p", "17932:7878", "img"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Subscribe(ctx, &grpcapi.SubscribeRequest{ContainerName: "grpc-logs"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.GetServiceLogs(ctx, &grpcapi.LogsRequest{Name: "grpc-logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs.Write(chunk.Data)
+	}
+	if !strings.Contains(logs.String(), "listening on 0.0.0.0:7878") {
+		t.Errorf("logs %q", logs.String())
+	}
+
+	// Followed, new output arrives on the open stream
+	follow, err := client.GetServiceLogs(ctx, &grpcapi.LogsRequest{Name: "grpc-logs", Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.mu.Lock()
+	c := sim.containers["grpc-logs"]
+	sim.mu.Unlock()
+	sim.log(c, "followed line")
+	var followed strings.Builder
+	for !strings.Contains(followed.String(), "followed line") {
+		chunk, err := follow.Recv()
+		if err != nil {
+			t.Fatalf("follow: %v after %q", err, followed.String())
+		}
+		followed.Write(chunk.Data)
+	}
+
+	// A REST error before any output ends the stream with its status
+	missing, err := client.GetServiceLogs(ctx, &grpcapi.LogsRequest{Name: "missing"})
+	if err == nil {
+		_, err = missing.Recv()
+	}
+	if st, _ := status.FromError(err); st.Code() != codes.InvalidArgument || st.Message() != "no service name missing found!" {
+		t.Errorf("logs of an unknown service: %v", err)
+	}
+}
@@ -0,0 +1,2247 @@
+// gRPC API of cm_controller, served with --grpc-port next to the REST routes.
+//
+// Every RPC mirrors a REST route (named in its comment) and goes through the same handler, so
+// admission, audit and validation are shared. Field names are the REST json keys; times are
+// RFC 3339 strings and free-form json (ff_daemon arguments, docker inspect) is a Struct.
+// A failed call returns the REST status as a gRPC code, with the REST error body as a Struct detail.
+//
+// Regenerate with: protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grpcapi/cm_controller.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: grpcapi/cm_controller.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CmController_Up_FullMethodName                     = "/cm_controller.v1.CmController/Up"
+	CmController_Run_FullMethodName                    = "/cm_controller.v1.CmController/Run"
+	CmController_Checkpoint_FullMethodName             = "/cm_controller.v1.CmController/Checkpoint"
+	CmController_CheckpointWithProgress_FullMethodName = "/cm_controller.v1.CmController/CheckpointWithProgress"
+	CmController_Subscribe_FullMethodName              = "/cm_controller.v1.CmController/Subscribe"
+	CmController_Unsubscribe_FullMethodName            = "/cm_controller.v1.CmController/Unsubscribe"
+	CmController_Start_FullMethodName                  = "/cm_controller.v1.CmController/Start"
+	CmController_Stop_FullMethodName                   = "/cm_controller.v1.CmController/Stop"
+	CmController_Remove_FullMethodName                 = "/cm_controller.v1.CmController/Remove"
+	CmController_GetContainerInfo_FullMethodName       = "/cm_controller.v1.CmController/GetContainerInfo"
+	CmController_GetService_FullMethodName             = "/cm_controller.v1.CmController/GetService"
+	CmController_ListServices_FullMethodName           = "/cm_controller.v1.CmController/ListServices"
+	CmController_GetServiceHealth_FullMethodName       = "/cm_controller.v1.CmController/GetServiceHealth"
+	CmController_UpdateResources_FullMethodName        = "/cm_controller.v1.CmController/UpdateResources"
+	CmController_GetServiceStats_FullMethodName        = "/cm_controller.v1.CmController/GetServiceStats"
+	CmController_StreamServiceStats_FullMethodName     = "/cm_controller.v1.CmController/StreamServiceStats"
+	CmController_GetAllServicesStats_FullMethodName    = "/cm_controller.v1.CmController/GetAllServicesStats"
+	CmController_GetServiceLogs_FullMethodName         = "/cm_controller.v1.CmController/GetServiceLogs"
+	CmController_PullImage_FullMethodName              = "/cm_controller.v1.CmController/PullImage"
+	CmController_ListImages_FullMethodName             = "/cm_controller.v1.CmController/ListImages"
+	CmController_Preflight_FullMethodName              = "/cm_controller.v1.CmController/Preflight"
+	CmController_GetAudit_FullMethodName               = "/cm_controller.v1.CmController/GetAudit"
+	CmController_GetAdmission_FullMethodName           = "/cm_controller.v1.CmController/GetAdmission"
+	CmController_GetMetrics_FullMethodName             = "/cm_controller.v1.CmController/GetMetrics"
+	CmController_CreateWebhook_FullMethodName          = "/cm_controller.v1.CmController/CreateWebhook"
+	CmController_ListWebhooks_FullMethodName           = "/cm_controller.v1.CmController/ListWebhooks"
+	CmController_DeleteWebhook_FullMethodName          = "/cm_controller.v1.CmController/DeleteWebhook"
+	CmController_GetDeadLetters_FullMethodName         = "/cm_controller.v1.CmController/GetDeadLetters"
+	CmController_WatchEvents_FullMethodName            = "/cm_controller.v1.CmController/WatchEvents"
+	CmController_ListGroups_FullMethodName             = "/cm_controller.v1.CmController/ListGroups"
+	CmController_GetGroup_FullMethodName               = "/cm_controller.v1.CmController/GetGroup"
+	CmController_UpdateGroup_FullMethodName            = "/cm_controller.v1.CmController/UpdateGroup"
+	CmController_DeleteGroup_FullMethodName            = "/cm_controller.v1.CmController/DeleteGroup"
+	CmController_CheckpointGroup_FullMethodName        = "/cm_controller.v1.CmController/CheckpointGroup"
+	CmController_ListDesired_FullMethodName            = "/cm_controller.v1.CmController/ListDesired"
+	CmController_GetDesired_FullMethodName             = "/cm_controller.v1.CmController/GetDesired"
+	CmController_PutDesired_FullMethodName             = "/cm_controller.v1.CmController/PutDesired"
+	CmController_DeleteDesired_FullMethodName          = "/cm_controller.v1.CmController/DeleteDesired"
+	CmController_GetManifest_FullMethodName            = "/cm_controller.v1.CmController/GetManifest"
+	CmController_GetReconcileReport_FullMethodName     = "/cm_controller.v1.CmController/GetReconcileReport"
+	CmController_ApplyReconcile_FullMethodName         = "/cm_controller.v1.CmController/ApplyReconcile"
+	CmController_ListSecrets_FullMethodName            = "/cm_controller.v1.CmController/ListSecrets"
+	CmController_PutSecret_FullMethodName              = "/cm_controller.v1.CmController/PutSecret"
+	CmController_DeleteSecret_FullMethodName           = "/cm_controller.v1.CmController/DeleteSecret"
+	CmController_GetWorkerTemplates_FullMethodName     = "/cm_controller.v1.CmController/GetWorkerTemplates"
+	CmController_PutWorkerTemplates_FullMethodName     = "/cm_controller.v1.CmController/PutWorkerTemplates"
+	CmController_DeleteWorkerTemplates_FullMethodName  = "/cm_controller.v1.CmController/DeleteWorkerTemplates"
+	CmController_GetServiceTemplates_FullMethodName    = "/cm_controller.v1.CmController/GetServiceTemplates"
+	CmController_PutServiceTemplates_FullMethodName    = "/cm_controller.v1.CmController/PutServiceTemplates"
+	CmController_DeleteServiceTemplates_FullMethodName = "/cm_controller.v1.CmController/DeleteServiceTemplates"
+	CmController_GetLogLevel_FullMethodName            = "/cm_controller.v1.CmController/GetLogLevel"
+	CmController_SetLogLevel_FullMethodName            = "/cm_controller.v1.CmController/SetLogLevel"
+)
+
+// CmControllerClient is the client API for CmController service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CmControllerClient interface {
+	// GET /up
+	Up(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Message, error)
+	// POST /run/{name}
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*OperationReply, error)
+	// POST /checkpoint/{name}
+	Checkpoint(ctx context.Context, in *CheckpointRequest, opts ...grpc.CallOption) (*OperationReply, error)
+	// POST /checkpoint/{name}, reporting progress until the checkpoint is done
+	CheckpointWithProgress(ctx context.Context, in *CheckpointRequest, opts ...grpc.CallOption) (CmController_CheckpointWithProgressClient, error)
+	// POST /subscribe
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (*Service, error)
+	// POST /unsubscribe/{name}
+	Unsubscribe(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error)
+	// POST /start
+	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*Message, error)
+	// POST /stop/{name}
+	Stop(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error)
+	// DELETE /remove/{name}
+	Remove(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error)
+	// GET /service/container_info/{name}, docker's inspect output
+	GetContainerInfo(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*structpb.Struct, error)
+	// GET /service/{name}
+	GetService(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Service, error)
+	// GET /service
+	ListServices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ServiceList, error)
+	// GET /service/{name}/health
+	GetServiceHealth(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*ServiceHealth, error)
+	// PATCH /service/{name}/resources
+	UpdateResources(ctx context.Context, in *UpdateResourcesRequest, opts ...grpc.CallOption) (*UpdateResourcesReply, error)
+	// GET /service/{name}/stats
+	GetServiceStats(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*ServiceStats, error)
+	// GET /service/{name}/stats?stream=true
+	StreamServiceStats(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (CmController_StreamServiceStatsClient, error)
+	// GET /stats
+	GetAllServicesStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AggregateStats, error)
+	// GET /service/{name}/logs
+	GetServiceLogs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (CmController_GetServiceLogsClient, error)
+	// POST /images/pull
+	PullImage(ctx context.Context, in *PullRequest, opts ...grpc.CallOption) (CmController_PullImageClient, error)
+	// GET /images
+	ListImages(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ImageList, error)
+	// GET /node/preflight
+	Preflight(ctx context.Context, in *PreflightRequest, opts ...grpc.CallOption) (*PreflightReport, error)
+	// GET /audit
+	GetAudit(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*AuditLog, error)
+	// GET /admission
+	GetAdmission(ctx context.Context, in *AdmissionRequest, opts ...grpc.CallOption) (*AdmissionStatus, error)
+	// GET /metrics
+	GetMetrics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Metrics, error)
+	// POST /webhooks
+	CreateWebhook(ctx context.Context, in *Webhook, opts ...grpc.CallOption) (*Webhook, error)
+	// GET /webhooks
+	ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*WebhookList, error)
+	// DELETE /webhooks/{id}
+	DeleteWebhook(ctx context.Context, in *WebhookId, opts ...grpc.CallOption) (*Message, error)
+	// GET /webhooks/deadletter
+	GetDeadLetters(ctx context.Context, in *DeadLettersRequest, opts ...grpc.CallOption) (*DeadLetterList, error)
+	// The events webhooks receive, streamed as they happen
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (CmController_WatchEventsClient, error)
+	// GET /group
+	ListGroups(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GroupList, error)
+	// GET /group/{name}
+	GetGroup(ctx context.Context, in *GroupName, opts ...grpc.CallOption) (*Group, error)
+	// PUT /group/{name}
+	UpdateGroup(ctx context.Context, in *UpdateGroupRequest, opts ...grpc.CallOption) (*Group, error)
+	// DELETE /group/{name}
+	DeleteGroup(ctx context.Context, in *GroupName, opts ...grpc.CallOption) (*Message, error)
+	// POST /group/{name}/checkpoint
+	CheckpointGroup(ctx context.Context, in *GroupCheckpointRequest, opts ...grpc.CallOption) (*GroupCheckpoint, error)
+	// GET /desired
+	ListDesired(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DesiredList, error)
+	// GET /desired/{name}
+	GetDesired(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*DesiredStatus, error)
+	// PUT /desired/{name}
+	PutDesired(ctx context.Context, in *PutDesiredRequest, opts ...grpc.CallOption) (*DesiredStatus, error)
+	// DELETE /desired/{name}
+	DeleteDesired(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error)
+	// GET /manifest
+	GetManifest(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ManifestStatus, error)
+	// GET /reconcile
+	GetReconcileReport(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ReconcileReport, error)
+	// POST /reconcile
+	ApplyReconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*ReconcileResultList, error)
+	// GET /secrets, values are never returned
+	ListSecrets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SecretList, error)
+	// PUT /secrets/{name}
+	PutSecret(ctx context.Context, in *PutSecretRequest, opts ...grpc.CallOption) (*Message, error)
+	// DELETE /secrets/{name}
+	DeleteSecret(ctx context.Context, in *SecretName, opts ...grpc.CallOption) (*Message, error)
+	// GET /templates
+	GetWorkerTemplates(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ParamTemplates, error)
+	// PUT /templates
+	PutWorkerTemplates(ctx context.Context, in *ParamTemplates, opts ...grpc.CallOption) (*ParamTemplates, error)
+	// DELETE /templates
+	DeleteWorkerTemplates(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Message, error)
+	// GET /service/{name}/templates
+	GetServiceTemplates(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*ParamTemplates, error)
+	// PUT /service/{name}/templates
+	PutServiceTemplates(ctx context.Context, in *PutServiceTemplatesRequest, opts ...grpc.CallOption) (*ParamTemplates, error)
+	// DELETE /service/{name}/templates
+	DeleteServiceTemplates(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error)
+	// GET /log/level
+	GetLogLevel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LogLevel, error)
+	// PUT /log/level
+	SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error)
+}
+
+type cmControllerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCmControllerClient(cc grpc.ClientConnInterface) CmControllerClient {
+	return &cmControllerClient{cc}
+}
+
+func (c *cmControllerClient) Up(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_Up_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*OperationReply, error) {
+	out := new(OperationReply)
+	err := c.cc.Invoke(ctx, CmController_Run_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Checkpoint(ctx context.Context, in *CheckpointRequest, opts ...grpc.CallOption) (*OperationReply, error) {
+	out := new(OperationReply)
+	err := c.cc.Invoke(ctx, CmController_Checkpoint_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) CheckpointWithProgress(ctx context.Context, in *CheckpointRequest, opts ...grpc.CallOption) (CmController_CheckpointWithProgressClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CmController_ServiceDesc.Streams[0], CmController_CheckpointWithProgress_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cmControllerCheckpointWithProgressClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CmController_CheckpointWithProgressClient interface {
+	Recv() (*CheckpointProgress, error)
+	grpc.ClientStream
+}
+
+type cmControllerCheckpointWithProgressClient struct {
+	grpc.ClientStream
+}
+
+func (x *cmControllerCheckpointWithProgressClient) Recv() (*CheckpointProgress, error) {
+	m := new(CheckpointProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cmControllerClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (*Service, error) {
+	out := new(Service)
+	err := c.cc.Invoke(ctx, CmController_Subscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Unsubscribe(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_Unsubscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_Start_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Stop(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_Stop_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Remove(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_Remove_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetContainerInfo(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*structpb.Struct, error) {
+	out := new(structpb.Struct)
+	err := c.cc.Invoke(ctx, CmController_GetContainerInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetService(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Service, error) {
+	out := new(Service)
+	err := c.cc.Invoke(ctx, CmController_GetService_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) ListServices(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ServiceList, error) {
+	out := new(ServiceList)
+	err := c.cc.Invoke(ctx, CmController_ListServices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetServiceHealth(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*ServiceHealth, error) {
+	out := new(ServiceHealth)
+	err := c.cc.Invoke(ctx, CmController_GetServiceHealth_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) UpdateResources(ctx context.Context, in *UpdateResourcesRequest, opts ...grpc.CallOption) (*UpdateResourcesReply, error) {
+	out := new(UpdateResourcesReply)
+	err := c.cc.Invoke(ctx, CmController_UpdateResources_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetServiceStats(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*ServiceStats, error) {
+	out := new(ServiceStats)
+	err := c.cc.Invoke(ctx, CmController_GetServiceStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) StreamServiceStats(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (CmController_StreamServiceStatsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CmController_ServiceDesc.Streams[1], CmController_StreamServiceStats_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cmControllerStreamServiceStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CmController_StreamServiceStatsClient interface {
+	Recv() (*ServiceStats, error)
+	grpc.ClientStream
+}
+
+type cmControllerStreamServiceStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *cmControllerStreamServiceStatsClient) Recv() (*ServiceStats, error) {
+	m := new(ServiceStats)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cmControllerClient) GetAllServicesStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AggregateStats, error) {
+	out := new(AggregateStats)
+	err := c.cc.Invoke(ctx, CmController_GetAllServicesStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetServiceLogs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (CmController_GetServiceLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CmController_ServiceDesc.Streams[2], CmController_GetServiceLogs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cmControllerGetServiceLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CmController_GetServiceLogsClient interface {
+	Recv() (*LogChunk, error)
+	grpc.ClientStream
+}
+
+type cmControllerGetServiceLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *cmControllerGetServiceLogsClient) Recv() (*LogChunk, error) {
+	m := new(LogChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cmControllerClient) PullImage(ctx context.Context, in *PullRequest, opts ...grpc.CallOption) (CmController_PullImageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CmController_ServiceDesc.Streams[3], CmController_PullImage_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cmControllerPullImageClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CmController_PullImageClient interface {
+	Recv() (*PullProgress, error)
+	grpc.ClientStream
+}
+
+type cmControllerPullImageClient struct {
+	grpc.ClientStream
+}
+
+func (x *cmControllerPullImageClient) Recv() (*PullProgress, error) {
+	m := new(PullProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cmControllerClient) ListImages(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ImageList, error) {
+	out := new(ImageList)
+	err := c.cc.Invoke(ctx, CmController_ListImages_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) Preflight(ctx context.Context, in *PreflightRequest, opts ...grpc.CallOption) (*PreflightReport, error) {
+	out := new(PreflightReport)
+	err := c.cc.Invoke(ctx, CmController_Preflight_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetAudit(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*AuditLog, error) {
+	out := new(AuditLog)
+	err := c.cc.Invoke(ctx, CmController_GetAudit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetAdmission(ctx context.Context, in *AdmissionRequest, opts ...grpc.CallOption) (*AdmissionStatus, error) {
+	out := new(AdmissionStatus)
+	err := c.cc.Invoke(ctx, CmController_GetAdmission_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetMetrics(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Metrics, error) {
+	out := new(Metrics)
+	err := c.cc.Invoke(ctx, CmController_GetMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) CreateWebhook(ctx context.Context, in *Webhook, opts ...grpc.CallOption) (*Webhook, error) {
+	out := new(Webhook)
+	err := c.cc.Invoke(ctx, CmController_CreateWebhook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*WebhookList, error) {
+	out := new(WebhookList)
+	err := c.cc.Invoke(ctx, CmController_ListWebhooks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) DeleteWebhook(ctx context.Context, in *WebhookId, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_DeleteWebhook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetDeadLetters(ctx context.Context, in *DeadLettersRequest, opts ...grpc.CallOption) (*DeadLetterList, error) {
+	out := new(DeadLetterList)
+	err := c.cc.Invoke(ctx, CmController_GetDeadLetters_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (CmController_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CmController_ServiceDesc.Streams[4], CmController_WatchEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cmControllerWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CmController_WatchEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type cmControllerWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *cmControllerWatchEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cmControllerClient) ListGroups(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GroupList, error) {
+	out := new(GroupList)
+	err := c.cc.Invoke(ctx, CmController_ListGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetGroup(ctx context.Context, in *GroupName, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, CmController_GetGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) UpdateGroup(ctx context.Context, in *UpdateGroupRequest, opts ...grpc.CallOption) (*Group, error) {
+	out := new(Group)
+	err := c.cc.Invoke(ctx, CmController_UpdateGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) DeleteGroup(ctx context.Context, in *GroupName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_DeleteGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) CheckpointGroup(ctx context.Context, in *GroupCheckpointRequest, opts ...grpc.CallOption) (*GroupCheckpoint, error) {
+	out := new(GroupCheckpoint)
+	err := c.cc.Invoke(ctx, CmController_CheckpointGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) ListDesired(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DesiredList, error) {
+	out := new(DesiredList)
+	err := c.cc.Invoke(ctx, CmController_ListDesired_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetDesired(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*DesiredStatus, error) {
+	out := new(DesiredStatus)
+	err := c.cc.Invoke(ctx, CmController_GetDesired_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) PutDesired(ctx context.Context, in *PutDesiredRequest, opts ...grpc.CallOption) (*DesiredStatus, error) {
+	out := new(DesiredStatus)
+	err := c.cc.Invoke(ctx, CmController_PutDesired_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) DeleteDesired(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_DeleteDesired_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetManifest(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ManifestStatus, error) {
+	out := new(ManifestStatus)
+	err := c.cc.Invoke(ctx, CmController_GetManifest_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetReconcileReport(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ReconcileReport, error) {
+	out := new(ReconcileReport)
+	err := c.cc.Invoke(ctx, CmController_GetReconcileReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) ApplyReconcile(ctx context.Context, in *ReconcileRequest, opts ...grpc.CallOption) (*ReconcileResultList, error) {
+	out := new(ReconcileResultList)
+	err := c.cc.Invoke(ctx, CmController_ApplyReconcile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) ListSecrets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SecretList, error) {
+	out := new(SecretList)
+	err := c.cc.Invoke(ctx, CmController_ListSecrets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) PutSecret(ctx context.Context, in *PutSecretRequest, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_PutSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) DeleteSecret(ctx context.Context, in *SecretName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_DeleteSecret_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetWorkerTemplates(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ParamTemplates, error) {
+	out := new(ParamTemplates)
+	err := c.cc.Invoke(ctx, CmController_GetWorkerTemplates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) PutWorkerTemplates(ctx context.Context, in *ParamTemplates, opts ...grpc.CallOption) (*ParamTemplates, error) {
+	out := new(ParamTemplates)
+	err := c.cc.Invoke(ctx, CmController_PutWorkerTemplates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) DeleteWorkerTemplates(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_DeleteWorkerTemplates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetServiceTemplates(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*ParamTemplates, error) {
+	out := new(ParamTemplates)
+	err := c.cc.Invoke(ctx, CmController_GetServiceTemplates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) PutServiceTemplates(ctx context.Context, in *PutServiceTemplatesRequest, opts ...grpc.CallOption) (*ParamTemplates, error) {
+	out := new(ParamTemplates)
+	err := c.cc.Invoke(ctx, CmController_PutServiceTemplates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) DeleteServiceTemplates(ctx context.Context, in *ServiceName, opts ...grpc.CallOption) (*Message, error) {
+	out := new(Message)
+	err := c.cc.Invoke(ctx, CmController_DeleteServiceTemplates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) GetLogLevel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, CmController_GetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cmControllerClient) SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, CmController_SetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CmControllerServer is the server API for CmController service.
+// All implementations must embed UnimplementedCmControllerServer
+// for forward compatibility
+type CmControllerServer interface {
+	// GET /up
+	Up(context.Context, *emptypb.Empty) (*Message, error)
+	// POST /run/{name}
+	Run(context.Context, *RunRequest) (*OperationReply, error)
+	// POST /checkpoint/{name}
+	Checkpoint(context.Context, *CheckpointRequest) (*OperationReply, error)
+	// POST /checkpoint/{name}, reporting progress until the checkpoint is done
+	CheckpointWithProgress(*CheckpointRequest, CmController_CheckpointWithProgressServer) error
+	// POST /subscribe
+	Subscribe(context.Context, *SubscribeRequest) (*Service, error)
+	// POST /unsubscribe/{name}
+	Unsubscribe(context.Context, *ServiceName) (*Message, error)
+	// POST /start
+	Start(context.Context, *StartRequest) (*Message, error)
+	// POST /stop/{name}
+	Stop(context.Context, *ServiceName) (*Message, error)
+	// DELETE /remove/{name}
+	Remove(context.Context, *ServiceName) (*Message, error)
+	// GET /service/container_info/{name}, docker's inspect output
+	GetContainerInfo(context.Context, *ServiceName) (*structpb.Struct, error)
+	// GET /service/{name}
+	GetService(context.Context, *ServiceName) (*Service, error)
+	// GET /service
+	ListServices(context.Context, *emptypb.Empty) (*ServiceList, error)
+	// GET /service/{name}/health
+	GetServiceHealth(context.Context, *ServiceName) (*ServiceHealth, error)
+	// PATCH /service/{name}/resources
+	UpdateResources(context.Context, *UpdateResourcesRequest) (*UpdateResourcesReply, error)
+	// GET /service/{name}/stats
+	GetServiceStats(context.Context, *ServiceName) (*ServiceStats, error)
+	// GET /service/{name}/stats?stream=true
+	StreamServiceStats(*ServiceName, CmController_StreamServiceStatsServer) error
+	// GET /stats
+	GetAllServicesStats(context.Context, *emptypb.Empty) (*AggregateStats, error)
+	// GET /service/{name}/logs
+	GetServiceLogs(*LogsRequest, CmController_GetServiceLogsServer) error
+	// POST /images/pull
+	PullImage(*PullRequest, CmController_PullImageServer) error
+	// GET /images
+	ListImages(context.Context, *emptypb.Empty) (*ImageList, error)
+	// GET /node/preflight
+	Preflight(context.Context, *PreflightRequest) (*PreflightReport, error)
+	// GET /audit
+	GetAudit(context.Context, *AuditRequest) (*AuditLog, error)
+	// GET /admission
+	GetAdmission(context.Context, *AdmissionRequest) (*AdmissionStatus, error)
+	// GET /metrics
+	GetMetrics(context.Context, *emptypb.Empty) (*Metrics, error)
+	// POST /webhooks
+	CreateWebhook(context.Context, *Webhook) (*Webhook, error)
+	// GET /webhooks
+	ListWebhooks(context.Context, *ListWebhooksRequest) (*WebhookList, error)
+	// DELETE /webhooks/{id}
+	DeleteWebhook(context.Context, *WebhookId) (*Message, error)
+	// GET /webhooks/deadletter
+	GetDeadLetters(context.Context, *DeadLettersRequest) (*DeadLetterList, error)
+	// The events webhooks receive, streamed as they happen
+	WatchEvents(*WatchEventsRequest, CmController_WatchEventsServer) error
+	// GET /group
+	ListGroups(context.Context, *emptypb.Empty) (*GroupList, error)
+	// GET /group/{name}
+	GetGroup(context.Context, *GroupName) (*Group, error)
+	// PUT /group/{name}
+	UpdateGroup(context.Context, *UpdateGroupRequest) (*Group, error)
+	// DELETE /group/{name}
+	DeleteGroup(context.Context, *GroupName) (*Message, error)
+	// POST /group/{name}/checkpoint
+	CheckpointGroup(context.Context, *GroupCheckpointRequest) (*GroupCheckpoint, error)
+	// GET /desired
+	ListDesired(context.Context, *emptypb.Empty) (*DesiredList, error)
+	// GET /desired/{name}
+	GetDesired(context.Context, *ServiceName) (*DesiredStatus, error)
+	// PUT /desired/{name}
+	PutDesired(context.Context, *PutDesiredRequest) (*DesiredStatus, error)
+	// DELETE /desired/{name}
+	DeleteDesired(context.Context, *ServiceName) (*Message, error)
+	// GET /manifest
+	GetManifest(context.Context, *emptypb.Empty) (*ManifestStatus, error)
+	// GET /reconcile
+	GetReconcileReport(context.Context, *emptypb.Empty) (*ReconcileReport, error)
+	// POST /reconcile
+	ApplyReconcile(context.Context, *ReconcileRequest) (*ReconcileResultList, error)
+	// GET /secrets, values are never returned
+	ListSecrets(context.Context, *emptypb.Empty) (*SecretList, error)
+	// PUT /secrets/{name}
+	PutSecret(context.Context, *PutSecretRequest) (*Message, error)
+	// DELETE /secrets/{name}
+	DeleteSecret(context.Context, *SecretName) (*Message, error)
+	// GET /templates
+	GetWorkerTemplates(context.Context, *emptypb.Empty) (*ParamTemplates, error)
+	// PUT /templates
+	PutWorkerTemplates(context.Context, *ParamTemplates) (*ParamTemplates, error)
+	// DELETE /templates
+	DeleteWorkerTemplates(context.Context, *emptypb.Empty) (*Message, error)
+	// GET /service/{name}/templates
+	GetServiceTemplates(context.Context, *ServiceName) (*ParamTemplates, error)
+	// PUT /service/{name}/templates
+	PutServiceTemplates(context.Context, *PutServiceTemplatesRequest) (*ParamTemplates, error)
+	// DELETE /service/{name}/templates
+	DeleteServiceTemplates(context.Context, *ServiceName) (*Message, error)
+	// GET /log/level
+	GetLogLevel(context.Context, *emptypb.Empty) (*LogLevel, error)
+	// PUT /log/level
+	SetLogLevel(context.Context, *LogLevel) (*LogLevel, error)
+	mustEmbedUnimplementedCmControllerServer()
+}
+
+// UnimplementedCmControllerServer must be embedded to have forward compatible implementations.
+type UnimplementedCmControllerServer struct {
+}
+
+func (UnimplementedCmControllerServer) Up(context.Context, *emptypb.Empty) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Up not implemented")
+}
+func (UnimplementedCmControllerServer) Run(context.Context, *RunRequest) (*OperationReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedCmControllerServer) Checkpoint(context.Context, *CheckpointRequest) (*OperationReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Checkpoint not implemented")
+}
+func (UnimplementedCmControllerServer) CheckpointWithProgress(*CheckpointRequest, CmController_CheckpointWithProgressServer) error {
+	return status.Errorf(codes.Unimplemented, "method CheckpointWithProgress not implemented")
+}
+func (UnimplementedCmControllerServer) Subscribe(context.Context, *SubscribeRequest) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedCmControllerServer) Unsubscribe(context.Context, *ServiceName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unsubscribe not implemented")
+}
+func (UnimplementedCmControllerServer) Start(context.Context, *StartRequest) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedCmControllerServer) Stop(context.Context, *ServiceName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedCmControllerServer) Remove(context.Context, *ServiceName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedCmControllerServer) GetContainerInfo(context.Context, *ServiceName) (*structpb.Struct, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetContainerInfo not implemented")
+}
+func (UnimplementedCmControllerServer) GetService(context.Context, *ServiceName) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetService not implemented")
+}
+func (UnimplementedCmControllerServer) ListServices(context.Context, *emptypb.Empty) (*ServiceList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (UnimplementedCmControllerServer) GetServiceHealth(context.Context, *ServiceName) (*ServiceHealth, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServiceHealth not implemented")
+}
+func (UnimplementedCmControllerServer) UpdateResources(context.Context, *UpdateResourcesRequest) (*UpdateResourcesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateResources not implemented")
+}
+func (UnimplementedCmControllerServer) GetServiceStats(context.Context, *ServiceName) (*ServiceStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServiceStats not implemented")
+}
+func (UnimplementedCmControllerServer) StreamServiceStats(*ServiceName, CmController_StreamServiceStatsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamServiceStats not implemented")
+}
+func (UnimplementedCmControllerServer) GetAllServicesStats(context.Context, *emptypb.Empty) (*AggregateStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAllServicesStats not implemented")
+}
+func (UnimplementedCmControllerServer) GetServiceLogs(*LogsRequest, CmController_GetServiceLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetServiceLogs not implemented")
+}
+func (UnimplementedCmControllerServer) PullImage(*PullRequest, CmController_PullImageServer) error {
+	return status.Errorf(codes.Unimplemented, "method PullImage not implemented")
+}
+func (UnimplementedCmControllerServer) ListImages(context.Context, *emptypb.Empty) (*ImageList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListImages not implemented")
+}
+func (UnimplementedCmControllerServer) Preflight(context.Context, *PreflightRequest) (*PreflightReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Preflight not implemented")
+}
+func (UnimplementedCmControllerServer) GetAudit(context.Context, *AuditRequest) (*AuditLog, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAudit not implemented")
+}
+func (UnimplementedCmControllerServer) GetAdmission(context.Context, *AdmissionRequest) (*AdmissionStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAdmission not implemented")
+}
+func (UnimplementedCmControllerServer) GetMetrics(context.Context, *emptypb.Empty) (*Metrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
+func (UnimplementedCmControllerServer) CreateWebhook(context.Context, *Webhook) (*Webhook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWebhook not implemented")
+}
+func (UnimplementedCmControllerServer) ListWebhooks(context.Context, *ListWebhooksRequest) (*WebhookList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedCmControllerServer) DeleteWebhook(context.Context, *WebhookId) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedCmControllerServer) GetDeadLetters(context.Context, *DeadLettersRequest) (*DeadLetterList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeadLetters not implemented")
+}
+func (UnimplementedCmControllerServer) WatchEvents(*WatchEventsRequest, CmController_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedCmControllerServer) ListGroups(context.Context, *emptypb.Empty) (*GroupList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListGroups not implemented")
+}
+func (UnimplementedCmControllerServer) GetGroup(context.Context, *GroupName) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroup not implemented")
+}
+func (UnimplementedCmControllerServer) UpdateGroup(context.Context, *UpdateGroupRequest) (*Group, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateGroup not implemented")
+}
+func (UnimplementedCmControllerServer) DeleteGroup(context.Context, *GroupName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteGroup not implemented")
+}
+func (UnimplementedCmControllerServer) CheckpointGroup(context.Context, *GroupCheckpointRequest) (*GroupCheckpoint, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckpointGroup not implemented")
+}
+func (UnimplementedCmControllerServer) ListDesired(context.Context, *emptypb.Empty) (*DesiredList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDesired not implemented")
+}
+func (UnimplementedCmControllerServer) GetDesired(context.Context, *ServiceName) (*DesiredStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDesired not implemented")
+}
+func (UnimplementedCmControllerServer) PutDesired(context.Context, *PutDesiredRequest) (*DesiredStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutDesired not implemented")
+}
+func (UnimplementedCmControllerServer) DeleteDesired(context.Context, *ServiceName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDesired not implemented")
+}
+func (UnimplementedCmControllerServer) GetManifest(context.Context, *emptypb.Empty) (*ManifestStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetManifest not implemented")
+}
+func (UnimplementedCmControllerServer) GetReconcileReport(context.Context, *emptypb.Empty) (*ReconcileReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReconcileReport not implemented")
+}
+func (UnimplementedCmControllerServer) ApplyReconcile(context.Context, *ReconcileRequest) (*ReconcileResultList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyReconcile not implemented")
+}
+func (UnimplementedCmControllerServer) ListSecrets(context.Context, *emptypb.Empty) (*SecretList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSecrets not implemented")
+}
+func (UnimplementedCmControllerServer) PutSecret(context.Context, *PutSecretRequest) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutSecret not implemented")
+}
+func (UnimplementedCmControllerServer) DeleteSecret(context.Context, *SecretName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSecret not implemented")
+}
+func (UnimplementedCmControllerServer) GetWorkerTemplates(context.Context, *emptypb.Empty) (*ParamTemplates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkerTemplates not implemented")
+}
+func (UnimplementedCmControllerServer) PutWorkerTemplates(context.Context, *ParamTemplates) (*ParamTemplates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutWorkerTemplates not implemented")
+}
+func (UnimplementedCmControllerServer) DeleteWorkerTemplates(context.Context, *emptypb.Empty) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWorkerTemplates not implemented")
+}
+func (UnimplementedCmControllerServer) GetServiceTemplates(context.Context, *ServiceName) (*ParamTemplates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetServiceTemplates not implemented")
+}
+func (UnimplementedCmControllerServer) PutServiceTemplates(context.Context, *PutServiceTemplatesRequest) (*ParamTemplates, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutServiceTemplates not implemented")
+}
+func (UnimplementedCmControllerServer) DeleteServiceTemplates(context.Context, *ServiceName) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteServiceTemplates not implemented")
+}
+func (UnimplementedCmControllerServer) GetLogLevel(context.Context, *emptypb.Empty) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogLevel not implemented")
+}
+func (UnimplementedCmControllerServer) SetLogLevel(context.Context, *LogLevel) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+func (UnimplementedCmControllerServer) mustEmbedUnimplementedCmControllerServer() {}
+
+// UnsafeCmControllerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CmControllerServer will
+// result in compilation errors.
+type UnsafeCmControllerServer interface {
+	mustEmbedUnimplementedCmControllerServer()
+}
+
+func RegisterCmControllerServer(s grpc.ServiceRegistrar, srv CmControllerServer) {
+	s.RegisterService(&CmController_ServiceDesc, srv)
+}
+
+func _CmController_Up_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Up(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Up_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Up(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Run(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Run_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Run(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Checkpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Checkpoint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Checkpoint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Checkpoint(ctx, req.(*CheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_CheckpointWithProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CheckpointRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CmControllerServer).CheckpointWithProgress(m, &cmControllerCheckpointWithProgressServer{stream})
+}
+
+type CmController_CheckpointWithProgressServer interface {
+	Send(*CheckpointProgress) error
+	grpc.ServerStream
+}
+
+type cmControllerCheckpointWithProgressServer struct {
+	grpc.ServerStream
+}
+
+func (x *cmControllerCheckpointWithProgressServer) Send(m *CheckpointProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CmController_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Subscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Subscribe(ctx, req.(*SubscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Unsubscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Unsubscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Unsubscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Unsubscribe(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Start_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Start(ctx, req.(*StartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Stop(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Remove(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetContainerInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetContainerInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetContainerInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetContainerInfo(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetService_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetService(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ListServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ListServices(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetServiceHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetServiceHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetServiceHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetServiceHealth(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_UpdateResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).UpdateResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_UpdateResources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).UpdateResources(ctx, req.(*UpdateResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetServiceStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetServiceStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetServiceStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetServiceStats(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_StreamServiceStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ServiceName)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CmControllerServer).StreamServiceStats(m, &cmControllerStreamServiceStatsServer{stream})
+}
+
+type CmController_StreamServiceStatsServer interface {
+	Send(*ServiceStats) error
+	grpc.ServerStream
+}
+
+type cmControllerStreamServiceStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *cmControllerStreamServiceStatsServer) Send(m *ServiceStats) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CmController_GetAllServicesStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetAllServicesStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetAllServicesStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetAllServicesStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetServiceLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CmControllerServer).GetServiceLogs(m, &cmControllerGetServiceLogsServer{stream})
+}
+
+type CmController_GetServiceLogsServer interface {
+	Send(*LogChunk) error
+	grpc.ServerStream
+}
+
+type cmControllerGetServiceLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *cmControllerGetServiceLogsServer) Send(m *LogChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CmController_PullImage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PullRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CmControllerServer).PullImage(m, &cmControllerPullImageServer{stream})
+}
+
+type CmController_PullImageServer interface {
+	Send(*PullProgress) error
+	grpc.ServerStream
+}
+
+type cmControllerPullImageServer struct {
+	grpc.ServerStream
+}
+
+func (x *cmControllerPullImageServer) Send(m *PullProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CmController_ListImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ListImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ListImages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ListImages(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_Preflight_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PreflightRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).Preflight(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_Preflight_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).Preflight(ctx, req.(*PreflightRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetAudit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetAudit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetAudit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetAudit(ctx, req.(*AuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetAdmission_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdmissionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetAdmission(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetAdmission_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetAdmission(ctx, req.(*AdmissionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetMetrics(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_CreateWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Webhook)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).CreateWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_CreateWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).CreateWebhook(ctx, req.(*Webhook))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ListWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ListWebhooks(ctx, req.(*ListWebhooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_DeleteWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).DeleteWebhook(ctx, req.(*WebhookId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeadLettersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetDeadLetters(ctx, req.(*DeadLettersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CmControllerServer).WatchEvents(m, &cmControllerWatchEventsServer{stream})
+}
+
+type CmController_WatchEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type cmControllerWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *cmControllerWatchEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CmController_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ListGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ListGroups(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetGroup(ctx, req.(*GroupName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_UpdateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).UpdateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_UpdateGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).UpdateGroup(ctx, req.(*UpdateGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_DeleteGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).DeleteGroup(ctx, req.(*GroupName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_CheckpointGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupCheckpointRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).CheckpointGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_CheckpointGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).CheckpointGroup(ctx, req.(*GroupCheckpointRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_ListDesired_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ListDesired(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ListDesired_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ListDesired(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetDesired_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetDesired(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetDesired_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetDesired(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_PutDesired_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutDesiredRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).PutDesired(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_PutDesired_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).PutDesired(ctx, req.(*PutDesiredRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_DeleteDesired_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).DeleteDesired(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_DeleteDesired_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).DeleteDesired(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetManifest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetManifest(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetReconcileReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetReconcileReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetReconcileReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetReconcileReport(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_ApplyReconcile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ApplyReconcile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ApplyReconcile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ApplyReconcile(ctx, req.(*ReconcileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_ListSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).ListSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_ListSecrets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).ListSecrets(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_PutSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).PutSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_PutSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).PutSecret(ctx, req.(*PutSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_DeleteSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).DeleteSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_DeleteSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).DeleteSecret(ctx, req.(*SecretName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetWorkerTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetWorkerTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetWorkerTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetWorkerTemplates(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_PutWorkerTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParamTemplates)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).PutWorkerTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_PutWorkerTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).PutWorkerTemplates(ctx, req.(*ParamTemplates))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_DeleteWorkerTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).DeleteWorkerTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_DeleteWorkerTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).DeleteWorkerTemplates(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetServiceTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetServiceTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetServiceTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetServiceTemplates(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_PutServiceTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutServiceTemplatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).PutServiceTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_PutServiceTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).PutServiceTemplates(ctx, req.(*PutServiceTemplatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_DeleteServiceTemplates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServiceName)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).DeleteServiceTemplates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_DeleteServiceTemplates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).DeleteServiceTemplates(ctx, req.(*ServiceName))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_GetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).GetLogLevel(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CmController_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CmControllerServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CmController_SetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CmControllerServer).SetLogLevel(ctx, req.(*LogLevel))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CmController_ServiceDesc is the grpc.ServiceDesc for CmController service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CmController_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cm_controller.v1.CmController",
+	HandlerType: (*CmControllerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Up",
+			Handler:    _CmController_Up_Handler,
+		},
+		{
+			MethodName: "Run",
+			Handler:    _CmController_Run_Handler,
+		},
+		{
+			MethodName: "Checkpoint",
+			Handler:    _CmController_Checkpoint_Handler,
+		},
+		{
+			MethodName: "Subscribe",
+			Handler:    _CmController_Subscribe_Handler,
+		},
+		{
+			MethodName: "Unsubscribe",
+			Handler:    _CmController_Unsubscribe_Handler,
+		},
+		{
+			MethodName: "Start",
+			Handler:    _CmController_Start_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _CmController_Stop_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _CmController_Remove_Handler,
+		},
+		{
+			MethodName: "GetContainerInfo",
+			Handler:    _CmController_GetContainerInfo_Handler,
+		},
+		{
+			MethodName: "GetService",
+			Handler:    _CmController_GetService_Handler,
+		},
+		{
+			MethodName: "ListServices",
+			Handler:    _CmController_ListServices_Handler,
+		},
+		{
+			MethodName: "GetServiceHealth",
+			Handler:    _CmController_GetServiceHealth_Handler,
+		},
+		{
+			MethodName: "UpdateResources",
+			Handler:    _CmController_UpdateResources_Handler,
+		},
+		{
+			MethodName: "GetServiceStats",
+			Handler:    _CmController_GetServiceStats_Handler,
+		},
+		{
+			MethodName: "GetAllServicesStats",
+			Handler:    _CmController_GetAllServicesStats_Handler,
+		},
+		{
+			MethodName: "ListImages",
+			Handler:    _CmController_ListImages_Handler,
+		},
+		{
+			MethodName: "Preflight",
+			Handler:    _CmController_Preflight_Handler,
+		},
+		{
+			MethodName: "GetAudit",
+			Handler:    _CmController_GetAudit_Handler,
+		},
+		{
+			MethodName: "GetAdmission",
+			Handler:    _CmController_GetAdmission_Handler,
+		},
+		{
+			MethodName: "GetMetrics",
+			Handler:    _CmController_GetMetrics_Handler,
+		},
+		{
+			MethodName: "CreateWebhook",
+			Handler:    _CmController_CreateWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _CmController_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _CmController_DeleteWebhook_Handler,
+		},
+		{
+			MethodName: "GetDeadLetters",
+			Handler:    _CmController_GetDeadLetters_Handler,
+		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _CmController_ListGroups_Handler,
+		},
+		{
+			MethodName: "GetGroup",
+			Handler:    _CmController_GetGroup_Handler,
+		},
+		{
+			MethodName: "UpdateGroup",
+			Handler:    _CmController_UpdateGroup_Handler,
+		},
+		{
+			MethodName: "DeleteGroup",
+			Handler:    _CmController_DeleteGroup_Handler,
+		},
+		{
+			MethodName: "CheckpointGroup",
+			Handler:    _CmController_CheckpointGroup_Handler,
+		},
+		{
+			MethodName: "ListDesired",
+			Handler:    _CmController_ListDesired_Handler,
+		},
+		{
+			MethodName: "GetDesired",
+			Handler:    _CmController_GetDesired_Handler,
+		},
+		{
+			MethodName: "PutDesired",
+			Handler:    _CmController_PutDesired_Handler,
+		},
+		{
+			MethodName: "DeleteDesired",
+			Handler:    _CmController_DeleteDesired_Handler,
+		},
+		{
+			MethodName: "GetManifest",
+			Handler:    _CmController_GetManifest_Handler,
+		},
+		{
+			MethodName: "GetReconcileReport",
+			Handler:    _CmController_GetReconcileReport_Handler,
+		},
+		{
+			MethodName: "ApplyReconcile",
+			Handler:    _CmController_ApplyReconcile_Handler,
+		},
+		{
+			MethodName: "ListSecrets",
+			Handler:    _CmController_ListSecrets_Handler,
+		},
+		{
+			MethodName: "PutSecret",
+			Handler:    _CmController_PutSecret_Handler,
+		},
+		{
+			MethodName: "DeleteSecret",
+			Handler:    _CmController_DeleteSecret_Handler,
+		},
+		{
+			MethodName: "GetWorkerTemplates",
+			Handler:    _CmController_GetWorkerTemplates_Handler,
+		},
+		{
+			MethodName: "PutWorkerTemplates",
+			Handler:    _CmController_PutWorkerTemplates_Handler,
+		},
+		{
+			MethodName: "DeleteWorkerTemplates",
+			Handler:    _CmController_DeleteWorkerTemplates_Handler,
+		},
+		{
+			MethodName: "GetServiceTemplates",
+			Handler:    _CmController_GetServiceTemplates_Handler,
+		},
+		{
+			MethodName: "PutServiceTemplates",
+			Handler:    _CmController_PutServiceTemplates_Handler,
+		},
+		{
+			MethodName: "DeleteServiceTemplates",
+			Handler:    _CmController_DeleteServiceTemplates_Handler,
+		},
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _CmController_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _CmController_SetLogLevel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CheckpointWithProgress",
+			Handler:       _CmController_CheckpointWithProgress_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamServiceStats",
+			Handler:       _CmController_StreamServiceStats_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetServiceLogs",
+			Handler:       _CmController_GetServiceLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PullImage",
+			Handler:       _CmController_PullImage_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _CmController_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpcapi/cm_controller.proto",
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/types/mount"
 	"github.com/gin-gonic/gin"
@@ -23,13 +27,47 @@ type CheckpointBody struct {
 	Envs          []string `json:"envs"`
 }
 
+type RunBody struct {
+	AppArgs       string   `json:"app_args"`
+	ImgUrl        string   `json:"image_url"`
+	OnAppReady    string   `json:"on_app_ready"`
+	Passphrase    string   `json:"passphrase_file"`
+	Preserve_path string   `json:"preserved_paths"`
+	NoRestore     bool     `json:"no_restore"`
+	AllowBadImage bool     `json:"allow_bad_image"`
+	LeaveStopped  bool     `json:"leave_stopped"`
+	Verbose       int      `json:"verbose"`
+	Envs          []string `json:"envs"`
+}
+
+// Default deadlines of the calls to ff_daemon, a request can set its own with ?timeout=
+var runTimeout = envDuration("FF_RUN_TIMEOUT", 10*time.Minute)
+var checkpointTimeout = envDuration("FF_CHECKPOINT_TIMEOUT", 10*time.Minute)
+
 type StartBody struct {
-	ContainerName string        `json:"container_name"`
-	Image         string        `json:"image"`
-	AppPorts      []string      `json:"app_ports"`
-	Envs          []string      `json:"envs"`
-	Mounts        []mount.Mount `json:"mounts"`
-	Caps          []string      `json:"caps"`
+	ContainerName string         `json:"container_name"`
+	Image         string         `json:"image"`
+	AppPorts      []string       `json:"app_ports"`
+	Envs          []string       `json:"envs"`
+	Mounts        []mount.Mount  `json:"mounts"`
+	Caps          []string       `json:"caps"`
+	Resources     ResourceLimits `json:"resources"`
+	Network       NetworkConfig  `json:"network"`
+}
+
+// Resource limits applied to a service's container, memory values use docker's size format (e.g. "512m")
+type ResourceLimits struct {
+	CpuShares         int64    `json:"cpu_shares"`
+	CpuPeriod         int64    `json:"cpu_period"`
+	CpuQuota          int64    `json:"cpu_quota"`
+	Cpus              string   `json:"cpus"`
+	CpusetCpus        string   `json:"cpuset_cpus"`
+	CpusetMems        string   `json:"cpuset_mems"`
+	Memory            string   `json:"memory"`
+	MemorySwap        string   `json:"memory_swap"`
+	MemoryReservation string   `json:"memory_reservation"`
+	PidsLimit         int64    `json:"pids_limit"`
+	Ulimits           []string `json:"ulimits"`
 }
 
 func upHandler(c *gin.Context) {
@@ -43,12 +81,55 @@ func runHandler(c *gin.Context) {
 		return
 	}
 	containerName := c.Param("name")
-	ffRet, ffMsg := callFastFreeze(0, requestBody, containerName)
-	if ffRet == 1 {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": ffMsg})
+	if requestBody, err = applyParamTemplates(containerName, "run", requestBody); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	priority, err := strconv.Atoi(c.DefaultQuery("priority", "0"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid priority value"})
+		return
+	}
+	timeout, err := operationTimeout(c, runTimeout)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout value"})
+		return
+	}
+	if err := checkSecretRefs(requestBody); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// One run or checkpoint at a time per service, whoever asks for it
+	if err := acquireService(containerName, "run"); err != nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	defer releaseService(containerName)
+	// Restores are not given a cpu budget, they count as a medium one
+	ticket, err := admission.acquire(c.Request.Context(), containerName, "run", cpuBudgetWeight(""), priority)
+	if err != nil {
+		logger.Info("Run abandoned while queued", zap.String("containerName", containerName), zap.Error(err))
+		return
+	}
+	defer admission.release(ticket)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	started := time.Now()
+	ffRet, ffMsg, retries := callFastFreeze(ctx, 0, requestBody, containerName)
+	op := newServiceOperation("run", started, ffRet, ffMsg, retries, ctx)
+	recordServiceOperation(containerName, op)
+	var runBody RunBody
+	_ = json.Unmarshal(requestBody, &runBody)
+	notifyOperation(containerName, op, ffMsg, runBody.ImgUrl)
+	// The parameters ff_daemon was sent, after merging the defaults
+	params := json.RawMessage(requestBody)
+	if ffRet == 2 {
+		abortFastFreeze(c, ctx, containerName, "run", timeout, retries)
+	} else if ffRet == 1 {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": ffMsg, "logs": getServiceLogTail(containerName, failureLogTail), "retries": retries, "params": params})
 	} else {
 		updateServiceStatus(containerName, "running")
-		c.IndentedJSON(http.StatusOK, gin.H{"message": ffMsg})
+		c.IndentedJSON(http.StatusOK, gin.H{"message": ffMsg, "retries": retries, "params": params})
 	}
 }
 
@@ -59,37 +140,87 @@ func checkpointHandler(c *gin.Context) {
 		return
 	}
 	containerName := c.Param("name")
-	ffRet, ffMsg := callFastFreeze(1, requestBody, containerName)
+	if requestBody, err = applyParamTemplates(containerName, "checkpoint", requestBody); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	priority, err := strconv.Atoi(c.DefaultQuery("priority", "0"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid priority value"})
+		return
+	}
+	timeout, err := operationTimeout(c, checkpointTimeout)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid timeout value"})
+		return
+	}
+	if err := checkSecretRefs(requestBody); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := acquireService(containerName, "checkpoint"); err != nil {
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	defer releaseService(containerName)
+	var checkpointBody CheckpointBody
+	_ = json.Unmarshal(requestBody, &checkpointBody)
+	ticket, err := admission.acquire(c.Request.Context(), containerName, "checkpoint", cpuBudgetWeight(checkpointBody.Cpu_budget), priority)
+	if err != nil {
+		logger.Info("Checkpoint abandoned while queued", zap.String("containerName", containerName), zap.Error(err))
+		return
+	}
+	defer admission.release(ticket)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	started := time.Now()
+	ffRet, ffMsg, retries := callFastFreeze(ctx, 1, requestBody, containerName)
+	op := newServiceOperation("checkpoint", started, ffRet, ffMsg, retries, ctx)
+	recordServiceOperation(containerName, op)
+	notifyOperation(containerName, op, ffMsg, checkpointBody.ImgUrl)
+	params := json.RawMessage(requestBody)
 
-	if ffRet == 1 {
-		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": ffMsg})
+	if ffRet == 2 {
+		abortFastFreeze(c, ctx, containerName, "checkpoint", timeout, retries)
+	} else if ffRet == 1 {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": ffMsg, "logs": getServiceLogTail(containerName, failureLogTail), "retries": retries, "params": params})
 	} else {
-		var checkpointBody CheckpointBody
-		_ = json.Unmarshal(requestBody, &checkpointBody)
 		if checkpointBody.LeaveRun {
 			updateServiceStatus(containerName, "running")
 		} else {
 			updateServiceStatus(containerName, "checkpointed")
 		}
-		c.IndentedJSON(http.StatusOK, gin.H{"message": ffMsg})
+		c.IndentedJSON(http.StatusOK, gin.H{"message": ffMsg, "retries": retries, "params": params})
 	}
 }
 
 func subscribeHandler(c *gin.Context) {
-	containerName := c.Query("container_name")
-	containerId := c.Query("container_id")
-	image := c.Query("image")
-	daemonPort := c.Query("daemon_port")
-
-	_, ok := services[containerName]
-	if ok {
-		msg := "Container with the name " + containerName + " already existed!"
-		c.IndentedJSON(http.StatusConflict, gin.H{"message": msg})
+	// A container name or id, container_id is still accepted from older clients
+	nameOrId := c.Query("container_name")
+	if nameOrId == "" {
+		nameOrId = c.Query("container_id")
+	}
+	if nameOrId == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "container_name is required"})
 		return
 	}
-	serviceSubscribe(containerName, containerId, image, daemonPort)
-	msg := "Container with the name " + containerName + " subscribed"
-	c.IndentedJSON(http.StatusOK, gin.H{"message": msg})
+	service, err := subscribeContainer(nameOrId)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, errContainerNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, errAlreadySubscribed), errors.Is(err, errContainerNotRunning):
+			status = http.StatusConflict
+		case errors.Is(err, errNoDaemonPort):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, errDaemonUnreachable):
+			status = http.StatusBadGateway
+		}
+		c.IndentedJSON(status, gin.H{"error": "Failed to subscribe: " + err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, service)
 }
 
 func unsubscribeHandler(c *gin.Context) {
@@ -108,8 +239,16 @@ func startHandler(c *gin.Context) {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to read request body"})
 		return
 	}
+	if err := newStart.Network.validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid network: " + err.Error()})
+		return
+	}
+	if _, err := resolveEnvSecrets(newStart.Envs); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	createServiceDir(newStart.ContainerName)
-	if err := startService(newStart.ContainerName, newStart.Image, newStart.AppPorts, newStart.Envs, newStart.Mounts, newStart.Caps); err != nil {
+	if err := startService(newStart.ContainerName, newStart.Image, newStart.AppPorts, newStart.Envs, newStart.Mounts, newStart.Caps, newStart.Resources, newStart.Network); err != nil {
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the container:" + err.Error()})
 		return
 	}
@@ -118,6 +257,31 @@ func startHandler(c *gin.Context) {
 
 }
 
+func updateResourcesHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	if !isSubscribed(containerName) {
+		msg := "no service name " + containerName + " found!"
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	var limits ResourceLimits
+	if err := c.BindJSON(&limits); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	warnings, err := updateContainerResources(containerName, limits)
+	if errors.Is(err, errInvalidResources) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the container resources:" + err.Error()})
+		return
+	}
+	msg := "Container with the name " + containerName + " resources updated"
+	c.IndentedJSON(http.StatusOK, gin.H{"message": msg, "warnings": warnings})
+}
+
 func stopHandler(c *gin.Context) {
 	containerName := c.Param("name")
 	if err := stopContainer(containerName); err != nil {
@@ -142,20 +306,30 @@ func removeHandler(c *gin.Context) {
 
 func getContainerInfoHandler(c *gin.Context) {
 	containerName := c.Param("name")
-	containerInfo, err := getContainerInfo(services[containerName].ContainerId)
+	service, _ := getService(containerName)
+	containerInfo, err := getContainerInfo(service.ContainerId)
 	if err != nil {
 		fmt.Printf("Err: %s\n", err)
 		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Client cannot get container info!"})
 		return
 	}
+	// docker keeps the resolved envs, they are masked like in the audit log
+	if containerInfo.Config != nil {
+		config := *containerInfo.Config
+		config.Env = make([]string, len(containerInfo.Config.Env))
+		for i, env := range containerInfo.Config.Env {
+			config.Env[i] = redactEnv(env)
+		}
+		containerInfo.Config = &config
+	}
 	c.IndentedJSON(http.StatusOK, containerInfo)
 }
 
+// Service info is served from the status cached by the prober, so it never waits on ff_daemon
 func getServiceInfoHandler(c *gin.Context) {
 	containerName := c.Param("name")
-	if _, ok := services[containerName]; ok {
-		services[containerName].getUpdateServiceStatus()
-		c.IndentedJSON(http.StatusOK, services[containerName])
+	if service, ok := getService(containerName); ok {
+		c.IndentedJSON(http.StatusOK, service)
 	} else {
 		msg := "no service name " + containerName + " found!"
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": msg})
@@ -164,39 +338,92 @@ func getServiceInfoHandler(c *gin.Context) {
 }
 
 func getAllServicesInfoHandler(c *gin.Context) {
-	allServices := make([]Service, len(services))
-	i := 0
-	for _, service := range services {
-		stat := service.getUpdateServiceStatus()
-		if stat == "" {
-			continue
-		}
-		allServices[i] = service
-		i++
+	c.IndentedJSON(http.StatusOK, listServices())
+}
+
+// Deadline of an ff_daemon call, ?timeout= (e.g. "90s") overrides the configured default
+func operationTimeout(c *gin.Context, def time.Duration) (time.Duration, error) {
+	value := c.Query("timeout")
+	if value == "" {
+		return def, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, errors.New("invalid timeout")
 	}
-	c.IndentedJSON(http.StatusOK, allServices)
+	return timeout, nil
 }
 
-func callFastFreeze(mode int, requestBody []byte, containerName string) (int, string) {
+// Answer a run/checkpoint whose ff_daemon call was cut short, the daemon may still be working on it
+func abortFastFreeze(c *gin.Context, ctx context.Context, containerName string, operation string, timeout time.Duration, retries int) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Warn("ff_daemon call timed out", zap.String("containerName", containerName), zap.String("operation", operation), zap.Duration("timeout", timeout))
+		msg := fmt.Sprintf("%s did not complete within %s, the service needs to be reconciled", operation, timeout)
+		c.IndentedJSON(http.StatusGatewayTimeout, gin.H{"message": msg, "needs_reconcile": true, "retries": retries})
+		return
+	}
+	// The client went away, nobody is left to answer
+	logger.Warn("ff_daemon call canceled by the client", zap.String("containerName", containerName), zap.String("operation", operation))
+}
+
+// Returns 0 on success, 1 on failure and 2 when ctx ended (deadline or client gone) before ff_daemon answered,
+// along with ff_daemon's answer and the number of retries made
+func callFastFreeze(ctx context.Context, mode int, requestBody []byte, containerName string) (int, string, int) {
 	var daemonPort string
-	service, ok := services[containerName]
+	service, ok := getService(containerName)
 	if ok {
 		daemonPort = service.DaemonPort
 	} else {
-		return 1, "Container not in the team, Try Subscribe or Start it first"
+		return 1, "Container not in the team, Try Subscribe or Start it first", 0
+	}
+	requestBody, secretFiles, err := resolveSecretRefs(containerName, requestBody)
+	if err != nil {
+		logger.Error("Error resolving secrets", zap.String("containerName", containerName), zap.Error(err))
+		return 1, "Cannot resolve secrets: " + err.Error(), 0
 	}
+	defer removeSecretFiles(secretFiles)
 	url := "http://127.0.0.1:" + daemonPort
+	operation := "ff_run"
 	if mode == 0 {
 		url += "/run"
 	} else {
 		url += "/checkpoint"
+		operation = "ff_checkpoint"
 	}
 	fmt.Println(url)
+
+	var ffRet int
+	var ffMsg string
+	retries, err := ffRetry.do(ctx, operation, containerName, func() (bool, error) {
+		var err error
+		ffRet, ffMsg, err = postFastFreeze(ctx, url, requestBody)
+		if err == nil || ctx.Err() != nil {
+			return false, err
+		}
+		// Never reached ff_daemon (e.g. not listening yet right after docker run)
+		if isDialError(err) {
+			return true, err
+		}
+		// ff_daemon may have started the operation: a checkpoint is never sent twice,
+		// a run only while the application is still in standby
+		if mode == 0 && isConnectionError(err) && serviceInStandby(containerName) {
+			return true, err
+		}
+		return false, err
+	})
+	if err != nil && ctx.Err() != nil {
+		return 2, ctx.Err().Error(), retries
+	}
+	return ffRet, ffMsg, retries
+}
+
+// One POST to ff_daemon, the error is set for transport failures and non 200 answers
+func postFastFreeze(ctx context.Context, url string, requestBody []byte) (int, string, error) {
 	// Create an HTTP Post request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		logger.Error("Error creating the request", zap.Error(err))
-		return 1, "Error creating the request"
+		return 1, "Error creating the request", err
 	}
 	req.Close = true
 	req.Header.Set("Content-Type", "application/json")
@@ -207,7 +434,7 @@ func callFastFreeze(mode int, requestBody []byte, containerName string) (int, st
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Error("Error sending the request", zap.Error(err))
-		return 1, "Error sending the request"
+		return 1, "Error sending the request", err
 	}
 	defer resp.Body.Close()
 
@@ -215,13 +442,24 @@ func callFastFreeze(mode int, requestBody []byte, containerName string) (int, st
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error("Error reading the response", zap.Error(err))
-		return 1, "Error reading the response"
+		return 1, "Error reading the response", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		logger.Error("Error response from ff_daemon", zap.Int("statusCode", resp.StatusCode), zap.String("body", string(body)))
-		return 1, string(body)
+		return 1, string(body), errors.New(string(body))
 	}
 
-	return 0, string(body)
+	return 0, string(body), nil
+}
+
+// Refresh the service status from ff_daemon and tell whether no application was started yet
+func serviceInStandby(containerName string) bool {
+	service, ok := getService(containerName)
+	if !ok {
+		return false
+	}
+	service.getUpdateServiceStatus()
+	service, ok = getService(containerName)
+	return ok && service.Status == "standby"
 }
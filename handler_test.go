@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Router over the simulated docker and ff_daemon of the test
+func simulatedRouter(t *testing.T) (*gin.Engine, *simDockerClient) {
+	t.Helper()
+	sim := useSimulation(t)
+	r := gin.New()
+	r.POST("/cm_controller/v1/run/:name", runHandler)
+	r.POST("/cm_controller/v1/checkpoint/:name", checkpointHandler)
+	r.POST("/cm_controller/v1/start", startHandler)
+	r.GET("/cm_controller/v1/service/:name", getServiceInfoHandler)
+	return r, sim
+}
+
+func serve(r *gin.Engine, method string, path string, body string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, "/cm_controller/v1"+path, strings.NewReader(body)))
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// Start a simulated service, stopped and unsubscribed with the simulation
+func startSimulated(t *testing.T, r *gin.Engine, name string) {
+	t.Helper()
+	if code, response := serve(r, "POST", "/start", `{"container_name":"`+name+`","image":"img"}`); code != http.StatusOK {
+		t.Fatalf("start: %d %v", code, response)
+	}
+}
+
+func TestRunCheckpointSimulated(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	startSimulated(t, r, "handler-svc")
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		failNext   string
+		wantCode   int
+		wantStatus string
+	}{
+		{"checkpoint before run", "/checkpoint/handler-svc", `{}`, "", http.StatusInternalServerError, "standby"},
+		{"run", "/run/handler-svc", `{}`, "", http.StatusOK, "running"},
+		{"run twice", "/run/handler-svc", `{}`, "", http.StatusInternalServerError, "running"},
+		{"body is not an object", "/checkpoint/handler-svc", `[1]`, "", http.StatusBadRequest, "running"},
+		{"checkpoint failure", "/checkpoint/handler-svc", `{"image_url":"file:/tmp/cp"}`, "checkpoint", http.StatusInternalServerError, "running"},
+		{"checkpoint leaving it running", "/checkpoint/handler-svc", `{"image_url":"file:/tmp/cp","leave_running":true}`, "", http.StatusOK, "running"},
+		{"checkpoint", "/checkpoint/handler-svc", `{"image_url":"file:/tmp/cp"}`, "", http.StatusOK, "checkpointed"},
+	}
+	for _, tt := range tests {
+		if tt.failNext != "" {
+			sim.mu.Lock()
+			sim.config.FailNext[tt.failNext] = 1
+			sim.mu.Unlock()
+		}
+		code, response := serve(r, "POST", tt.path, tt.body)
+		if code != tt.wantCode {
+			t.Errorf("%s: code %d %v, want %d", tt.name, code, response, tt.wantCode)
+			continue
+		}
+		if tt.wantStatus == "" {
+			continue
+		}
+		if _, service := serve(r, "GET", "/service/handler-svc", ""); service["status"] != tt.wantStatus {
+			t.Errorf("%s: status %v, want %s", tt.name, service["status"], tt.wantStatus)
+		}
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", time.Minute, false},
+		{"?timeout=90s", 90 * time.Second, false},
+		{"?timeout=1h30m", 90 * time.Minute, false},
+		{"?timeout=0s", 0, true},
+		{"?timeout=-5s", 0, true},
+		{"?timeout=90", 0, true},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/cm_controller/v1/run/svc"+tt.query, nil)
+		got, err := operationTimeout(c, time.Minute)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q: operationTimeout() = %s, %v, want %s, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckpointTimeoutSimulated(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	startSimulated(t, r, "timeout-svc")
+	if code, response := serve(r, "POST", "/run/timeout-svc", `{}`); code != http.StatusOK {
+		t.Fatalf("run: %d %v", code, response)
+	}
+	sim.mu.Lock()
+	sim.config.CheckpointLatencyMs = 2000
+	sim.mu.Unlock()
+
+	code, response := serve(r, "POST", "/checkpoint/timeout-svc?timeout=50ms", `{"image_url":"file:/tmp/cp"}`)
+	if code != http.StatusGatewayTimeout || response["needs_reconcile"] != true {
+		t.Fatalf("checkpoint past its timeout: %d %v", code, response)
+	}
+	_, service := serve(r, "GET", "/service/timeout-svc", "")
+	operation, _ := service["last_operation"].(map[string]interface{})
+	if service["needs_reconcile"] != true || operation["operation"] != "checkpoint" || operation["result"] != "timeout" {
+		t.Errorf("service after the timeout %v", service)
+	}
+	if code, _ := serve(r, "POST", "/checkpoint/timeout-svc?timeout=soon", `{}`); code != http.StatusBadRequest {
+		t.Errorf("invalid timeout: code %d", code)
+	}
+}
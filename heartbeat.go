@@ -2,16 +2,26 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 
 	"go.uber.org/zap"
 )
 
+type Heartbeat struct {
+	WorkerId  string          `json:"worker_id"`
+	Preflight PreflightReport `json:"preflight"`
+}
+
 func sendHeartbeat() {
 
 	managerURL := "http://" + managerAddr + "/cm_manager/v1.0/heartbeat"
 
-	payload := []byte(`{"worker_id":"` + workerId + `"}`)
+	payload, err := json.Marshal(Heartbeat{workerId, getNodePreflight()})
+	if err != nil {
+		logger.Error("Error encoding heartbeat", zap.Error(err))
+		return
+	}
 
 	resp, err := http.Post(managerURL, "application/json", bytes.NewBuffer(payload))
 	if err != nil {
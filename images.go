@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type PullBody struct {
+	Image        string `json:"image"`
+	RegistryAuth string `json:"registry_auth"`
+}
+
+// Progress message of docker's image pull stream
+type PullMessage struct {
+	Status         string          `json:"status,omitempty"`
+	ID             string          `json:"id,omitempty"`
+	Progress       string          `json:"progress,omitempty"`
+	ProgressDetail json.RawMessage `json:"progressDetail,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+type ImageInfo struct {
+	ID          string   `json:"id"`
+	RepoTags    []string `json:"repo_tags"`
+	RepoDigests []string `json:"repo_digests"`
+	Size        int64    `json:"size"`
+	Created     int64    `json:"created"`
+	FFCapable   bool     `json:"ff_capable"`
+}
+
+// ff_daemon presence per image id, images are immutable so the result never goes stale
+var ffCapableCache = make(map[string]bool)
+var ffCapableMu sync.Mutex
+
+// Pull an image, every progress message is passed to fn
+func pullImage(ctx context.Context, imageName string, registryAuth string, fn func(PullMessage) error) error {
+	logger.Debug("Pulling image", zap.String("image", imageName))
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("image", imageName), zap.Error(err))
+		return err
+	}
+	defer cli.Close()
+
+	reader, err := cli.ImagePull(ctx, imageName, types.ImagePullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		logger.Error("Error pulling image", zap.String("image", imageName), zap.Error(err))
+		return err
+	}
+	defer reader.Close()
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg PullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			logger.Error("Error reading pull progress", zap.String("image", imageName), zap.Error(err))
+			return err
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			logger.Error("Error pulling image", zap.String("image", imageName), zap.String("error", msg.Error))
+			return errPullFailed{msg.Error}
+		}
+	}
+	logger.Info("Image pulled", zap.String("image", imageName))
+	return nil
+}
+
+type errPullFailed struct {
+	message string
+}
+
+func (e errPullFailed) Error() string {
+	return e.message
+}
+
+func listImages(ctx context.Context) ([]ImageInfo, error) {
+	logger.Debug("Listing images")
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.Error(err))
+		return nil, err
+	}
+	defer cli.Close()
+
+	summaries, err := cli.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		logger.Error("Error listing images", zap.Error(err))
+		return nil, err
+	}
+	images := make([]ImageInfo, 0, len(summaries))
+	for _, summary := range summaries {
+		ffCapable, err := imageHasFFDaemon(summary.ID)
+		if err != nil {
+			logger.Error("Error checking ff_daemon in image", zap.String("image", summary.ID), zap.Error(err))
+		}
+		images = append(images, ImageInfo{summary.ID, summary.RepoTags, summary.RepoDigests, summary.Size, summary.Created, ffCapable})
+	}
+	return images, nil
+}
+
+// Check whether ff_daemon is on the image's PATH by stating it in a created (never started) container
+func imageHasFFDaemon(imageName string) (bool, error) {
+	cli, err := newDockerClient()
+	if err != nil {
+		return false, err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	imageInfo, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		return false, err
+	}
+	ffCapableMu.Lock()
+	ffCapable, ok := ffCapableCache[imageInfo.ID]
+	ffCapableMu.Unlock()
+	if ok {
+		return ffCapable, nil
+	}
+
+	paths := []string{"/usr/local/bin", "/usr/bin", "/bin"}
+	if imageInfo.Config != nil {
+		for _, env := range imageInfo.Config.Env {
+			if strings.HasPrefix(env, "PATH=") {
+				paths = strings.Split(strings.TrimPrefix(env, "PATH="), ":")
+			}
+		}
+	}
+
+	created, err := cli.ContainerCreate(ctx, &container.Config{Image: imageInfo.ID, Cmd: []string{"ff_daemon"}}, nil, nil, nil, "")
+	if err != nil {
+		return false, err
+	}
+	defer cli.ContainerRemove(ctx, created.ID, types.ContainerRemoveOptions{Force: true})
+
+	ffCapable = false
+	for _, path := range paths {
+		if _, err := cli.ContainerStatPath(ctx, created.ID, strings.TrimSuffix(path, "/")+"/ff_daemon"); err == nil {
+			ffCapable = true
+			break
+		}
+	}
+	ffCapableMu.Lock()
+	ffCapableCache[imageInfo.ID] = ffCapable
+	ffCapableMu.Unlock()
+	logger.Debug("Checked ff_daemon in image", zap.String("image", imageName), zap.Bool("ffCapable", ffCapable))
+	return ffCapable, nil
+}
+
+func pullImageHandler(c *gin.Context) {
+	var pullBody PullBody
+	if err := c.BindJSON(&pullBody); err != nil || pullBody.Image == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	if c.DefaultQuery("stream", "true") != "true" {
+		err := pullImage(c.Request.Context(), pullBody.Image, pullBody.RegistryAuth, func(PullMessage) error { return nil })
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to pull the image:" + err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Image " + pullBody.Image + " pulled successfully"})
+		return
+	}
+
+	// Progress is streamed as docker's json messages, one per line; a failure shows up as a message with "error"
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	err := pullImage(c.Request.Context(), pullBody.Image, pullBody.RegistryAuth, func(msg PullMessage) error {
+		if err := encoder.Encode(msg); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	// Errors reported inside the stream were already forwarded
+	var pullErr errPullFailed
+	if err != nil && !errors.As(err, &pullErr) {
+		encoder.Encode(PullMessage{Error: err.Error()})
+	}
+}
+
+func listImagesHandler(c *gin.Context) {
+	images, err := listImages(c.Request.Context())
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Client cannot list images!"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, images)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPullImageHandler(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.POST("/cm_controller/v1/images/pull", pullImageHandler)
+
+	tests := []struct {
+		name      string
+		query     string
+		body      string
+		failNext  bool
+		wantCode  int
+		wantError bool
+	}{
+		{"no image", "", `{}`, false, http.StatusBadRequest, true},
+		{"streamed", "", `{"image":"app:1"}`, false, http.StatusOK, false},
+		{"streamed failure", "", `{"image":"app:2"}`, true, http.StatusOK, true},
+		{"not streamed", "?stream=false", `{"image":"app:3"}`, false, http.StatusOK, false},
+		{"not streamed failure", "?stream=false", `{"image":"app:4"}`, true, http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		if tt.failNext {
+			sim.mu.Lock()
+			sim.config.FailNext["pull"] = 1
+			sim.mu.Unlock()
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/cm_controller/v1/images/pull"+tt.query, strings.NewReader(tt.body)))
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: code %d %s, want %d", tt.name, w.Code, w.Body, tt.wantCode)
+			continue
+		}
+		// Streamed pulls answer one json progress message per line, the error comes last
+		body := w.Body.String()
+		var last PullMessage
+		scanner := bufio.NewScanner(strings.NewReader(body))
+		for scanner.Scan() {
+			if err := json.Unmarshal(scanner.Bytes(), &last); err != nil && tt.query == "" && tt.wantCode == http.StatusOK {
+				t.Errorf("%s: progress line %q: %v", tt.name, scanner.Text(), err)
+			}
+		}
+		hasError := last.Error != "" || strings.Contains(body, `"error"`)
+		if hasError != tt.wantError {
+			t.Errorf("%s: answer %s, want error %v", tt.name, body, tt.wantError)
+		}
+	}
+}
+
+func TestListImages(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.GET("/cm_controller/v1/images", listImagesHandler)
+	if err := pullImage(context.Background(), "app:1", "", func(PullMessage) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/images", nil))
+	var images []ImageInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &images); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("list: code %d %s", w.Code, w.Body)
+	}
+	if len(images) != 1 || len(images[0].RepoTags) != 1 || images[0].RepoTags[0] != "app:1" || !images[0].FFCapable {
+		t.Errorf("images %+v", images)
+	}
+	// The ff_daemon check must not leave its temporary container behind
+	sim.mu.Lock()
+	containers := len(sim.containers)
+	sim.mu.Unlock()
+	if containers != 0 {
+		t.Errorf("%d containers left after the ff_daemon check", containers)
+	}
+
+	if _, err := imageHasFFDaemon("missing:1"); err == nil {
+		t.Error("ff_daemon check of a missing image did not fail")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Local API socket, like docker.sock: given with --unix-socket, alone (--no-tcp) or next to TCP :8787
+var unixSocketPath string
+var unixSocketMode os.FileMode = 0660
+var unixSocketGroup string
+var noTcp bool
+
+func parseSocketMode(value string) error {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return errors.New("Invalid socket mode " + value + ", expected octal permissions like 0660")
+	}
+	unixSocketMode = os.FileMode(mode)
+	return nil
+}
+
+// Group id of a group name or of a numeric gid
+func lookupGroupId(group string) (int, error) {
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		if g, err = user.LookupGroupId(group); err != nil {
+			return 0, fmt.Errorf("unknown group %s", group)
+		}
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// Remove a socket file left by a controller that did not exit cleanly, but never one still served
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.New(path + " exists and is not a socket")
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return errors.New(path + " is in use by another process")
+	}
+	logger.Info("Removing stale API socket", zap.String("path", path))
+	return os.Remove(path)
+}
+
+func listenUnixSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	// Created owner-only, then opened up to the configured mode once the group is set
+	originalUmask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(originalUmask)
+	if err != nil {
+		return nil, err
+	}
+	if unixSocketGroup != "" {
+		gid, err := lookupGroupId(unixSocketGroup)
+		if err == nil {
+			err = os.Chown(path, -1, gid)
+		}
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve the API on the unix socket, removing the socket file when the controller is stopped
+func serveUnixSocket(r *gin.Engine) error {
+	listener, err := listenUnixSocket(unixSocketPath)
+	if err != nil {
+		return err
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-stop
+		logger.Info("Stopping, removing API socket", zap.String("path", unixSocketPath), zap.String("signal", sig.String()))
+		listener.Close()
+		os.Exit(0)
+	}()
+	logger.Info("Serving the API on a unix socket", zap.String("path", unixSocketPath), zap.String("mode", fmt.Sprintf("%04o", unixSocketMode)), zap.String("group", unixSocketGroup))
+	go func() {
+		if err := r.RunListener(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Error("Unix socket server stopped", zap.String("path", unixSocketPath), zap.Error(err))
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Short dir for sockets, a unix socket path is limited to about 100 bytes
+func socketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "cm-sock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestParseSocketMode(t *testing.T) {
+	previous := unixSocketMode
+	t.Cleanup(func() { unixSocketMode = previous })
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"0660", 0660, false},
+		{"600", 0600, false},
+		{"0777", 0777, false},
+		{"1777", 0, true},
+		{"0880", 0, true},
+		{"rw-rw----", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		unixSocketMode = 0
+		err := parseSocketMode(tt.value)
+		if (err != nil) != tt.wantErr || unixSocketMode != tt.want {
+			t.Errorf("%q: mode %o, err %v, want %o, error %v", tt.value, unixSocketMode, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := socketDir(t)
+	stale := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Left behind like by a controller that was killed
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	live := filepath.Join(dir, "live.sock")
+	liveListener, err := net.Listen("unix", live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer liveListener.Close()
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0600)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantErr  string
+		wantGone bool
+	}{
+		{"missing", filepath.Join(dir, "missing.sock"), "", true},
+		{"stale", stale, "", true},
+		{"in use", live, "in use by another process", false},
+		{"not a socket", file, "is not a socket", false},
+	}
+	for _, tt := range tests {
+		err := removeStaleSocket(tt.path)
+		if (tt.wantErr == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: err %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if _, err := os.Lstat(tt.path); os.IsNotExist(err) != tt.wantGone {
+			t.Errorf("%s: removed %v, want %v", tt.name, os.IsNotExist(err), tt.wantGone)
+		}
+	}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	previousMode, previousGroup := unixSocketMode, unixSocketGroup
+	t.Cleanup(func() { unixSocketMode, unixSocketGroup = previousMode, previousGroup })
+	dir := socketDir(t)
+	path := filepath.Join(dir, "run", "cm.sock")
+	unixSocketMode = 0640
+	unixSocketGroup = strconv.Itoa(os.Getgid())
+
+	listener, err := listenUnixSocket(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0640 || info.Mode()&os.ModeSocket == 0 {
+		t.Fatalf("socket %v: %v", info.Mode(), err)
+	}
+
+	r := gin.New()
+	r.GET("/cm_controller/v1/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	go r.RunListener(listener)
+	client := http.Client{Transport: &http.Transport{Dial: func(string, string) (net.Conn, error) { return net.Dial("unix", path) }}}
+	resp, err := client.Get("http://unix/cm_controller/v1/ping")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request over the socket: %v", err)
+	}
+	resp.Body.Close()
+
+	// Served by this controller, a second one does not take it over
+	if _, err := listenUnixSocket(path); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("second listen: %v", err)
+	}
+	unixSocketGroup = "no-such-group-cm"
+	if _, err := listenUnixSocket(filepath.Join(dir, "other.sock")); err == nil || !strings.Contains(err.Error(), "unknown group") {
+		t.Errorf("unknown group: %v", err)
+	}
+}
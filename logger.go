@@ -4,15 +4,20 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"sync"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var once sync.Once
 var logger *zap.Logger
 
+// Level shared by every core, changed at runtime through PUT /log/level
+var logLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+
 func getGlobalLogger() *zap.Logger {
 	once.Do(func() {
 		logger = initLogger()
@@ -41,11 +46,14 @@ func initLogger() *zap.Logger {
 	// Configuring file encoder
 	fileEncoder := zapcore.NewJSONEncoder(encoderConfig)
 
-	// Creating console and file write syncers
+	// Creating console and file write syncers, the file is rotated by size and age
 	consoleDebugging := zapcore.Lock(os.Stdout)
-	file, err := os.Create("cm_controller.log")
-	if err != nil {
-		panic(err)
+	file := newRotatingFile(envString("LOG_FILE", "cm_controller.log"))
+	// Without append mode the previous run's log is rotated out instead of continued
+	if !envBool("LOG_APPEND", true) {
+		if err := file.Rotate(); err != nil {
+			panic(err)
+		}
 	}
 	fileDebugging := zapcore.AddSync(file)
 
@@ -62,16 +70,143 @@ func initLogger() *zap.Logger {
 
 		level = levelFromEnv
 	}
-	logLevel := zap.NewAtomicLevelAt(level)
+	logLevel.SetLevel(level)
 
 	// Creating core
-	core := zapcore.NewTee(
+	cores := []zapcore.Core{
 		zapcore.NewCore(consoleEncoder, consoleDebugging, logLevel),
 		zapcore.NewCore(fileEncoder, fileDebugging, logLevel),
-	)
+	}
+	if envBool("LOG_PER_SERVICE", false) {
+		serviceLogs = &serviceLogFiles{dir: envString("LOG_SERVICE_DIR", "logs"), files: make(map[string]*lumberjack.Logger)}
+		cores = append(cores, newServiceCore(fileEncoder, logLevel, serviceLogs))
+	}
+	core := zapcore.NewTee(cores...)
 
-	// Creating logger
-	logger := zap.New(core)
+	// Creating logger, secret values never reach a log
+	logger := zap.New(redactingCore{core})
 
 	return logger
 }
+
+// Log file rotated at LOG_MAX_SIZE_MB, keeping LOG_MAX_BACKUPS files for LOG_MAX_AGE_DAYS
+func newRotatingFile(filename string) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   filename,
+		MaxSize:    envInt("LOG_MAX_SIZE_MB", 100),
+		MaxAge:     envInt("LOG_MAX_AGE_DAYS", 30),
+		MaxBackups: envInt("LOG_MAX_BACKUPS", 10),
+		Compress:   envBool("LOG_COMPRESS", false),
+	}
+}
+
+// Core copying every entry logged with a containerName field to LOG_SERVICE_DIR/<name>.log, kept
+// out of the service dirs the containers mount
+type serviceCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	fields  []zapcore.Field
+	files   *serviceLogFiles
+}
+
+type serviceLogFiles struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*lumberjack.Logger
+}
+
+// Per-service log files, nil unless LOG_PER_SERVICE is set
+var serviceLogs *serviceLogFiles
+
+func newServiceCore(encoder zapcore.Encoder, enabler zapcore.LevelEnabler, files *serviceLogFiles) zapcore.Core {
+	return &serviceCore{enabler, encoder, nil, files}
+}
+
+func (c *serviceCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	return &clone
+}
+
+func (c *serviceCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *serviceCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	all := append(append([]zapcore.Field(nil), c.fields...), fields...)
+	containerName := ""
+	for _, field := range all {
+		if field.Key == "containerName" && field.Type == zapcore.StringType {
+			containerName = field.String
+		}
+	}
+	if containerName == "" {
+		return nil
+	}
+	file := c.files.get(containerName)
+	buf, err := c.encoder.EncodeEntry(entry, all)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	_, err = file.Write(buf.Bytes())
+	return err
+}
+
+func (c *serviceCore) Sync() error {
+	return nil
+}
+
+// Log file of a service, opened on its first entry and kept until the service is unsubscribed
+func (f *serviceLogFiles) get(containerName string) *lumberjack.Logger {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[containerName]
+	if !ok {
+		file = newRotatingFile(f.dir + "/" + containerName + ".log")
+		f.files[containerName] = file
+	}
+	return file
+}
+
+// Close a service's log file, the file itself is kept
+func closeServiceLog(containerName string) {
+	if serviceLogs == nil {
+		return
+	}
+	serviceLogs.mu.Lock()
+	defer serviceLogs.mu.Unlock()
+	if file, ok := serviceLogs.files[containerName]; ok {
+		file.Close()
+		delete(serviceLogs.files, containerName)
+	}
+}
+
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Println(fmt.Errorf("invalid %s, defaulting to %d: %w", name, def, err))
+		return def
+	}
+	return n
+}
+
+func envBool(name string, def bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Println(fmt.Errorf("invalid %s, defaulting to %t: %w", name, def, err))
+		return def
+	}
+	return b
+}
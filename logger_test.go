@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+func TestServiceCore(t *testing.T) {
+	dir := t.TempDir()
+	previous := serviceLogs
+	serviceLogs = &serviceLogFiles{dir: dir, files: make(map[string]*lumberjack.Logger)}
+	t.Cleanup(func() {
+		closeServiceLog("svc-a")
+		closeServiceLog("svc-b")
+		serviceLogs = previous
+	})
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	log := zap.New(newServiceCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), level, serviceLogs))
+
+	log.Info("first of a", zap.String("containerName", "svc-a"))
+	log.Info("no service")
+	log.Debug("below the level", zap.String("containerName", "svc-a"))
+	log.With(zap.String("containerName", "svc-b")).Warn("first of b")
+	log.Info("not a string name", zap.Int("containerName", 1))
+	level.SetLevel(zap.DebugLevel)
+	log.Debug("debug of a", zap.String("containerName", "svc-a"))
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"svc-a.log", []string{"first of a", "debug of a"}},
+		{"svc-b.log", []string{"first of b"}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%s: %q, want %v", tt.file, lines, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(lines[i], want) {
+				t.Errorf("%s line %d: %q, want %q", tt.file, i, lines[i], want)
+			}
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("%d log files, want 2", len(entries))
+	}
+
+	// Closing keeps the file, the next entry opens it again
+	closeServiceLog("svc-a")
+	serviceLogs.mu.Lock()
+	_, open := serviceLogs.files["svc-a"]
+	serviceLogs.mu.Unlock()
+	if open {
+		t.Error("log of svc-a still open")
+	}
+	log.Info("after close", zap.String("containerName", "svc-a"))
+	if data, _ := os.ReadFile(filepath.Join(dir, "svc-a.log")); !strings.Contains(string(data), "first of a") || !strings.Contains(string(data), "after close") {
+		t.Errorf("svc-a.log after close %q", data)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Setenv("LOG_MAX_SIZE_MB", "1")
+	t.Setenv("LOG_MAX_BACKUPS", "2")
+	t.Setenv("LOG_MAX_AGE_DAYS", "not a number")
+	dir := t.TempDir()
+	file := newRotatingFile(filepath.Join(dir, "cm_controller.log"))
+	defer file.Close()
+	if file.MaxSize != 1 || file.MaxBackups != 2 || file.MaxAge != 30 || file.Compress {
+		t.Errorf("rotation settings %+v", file)
+	}
+
+	chunk := []byte(strings.Repeat("x", 255) + "\n")
+	for written := 0; written <= 1<<20; written += len(chunk) {
+		if _, err := file.Write(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("%d files after exceeding the max size, want the log and one backup", len(entries))
+	}
+}
+
+func TestEnvIntBool(t *testing.T) {
+	t.Setenv("TEST_ENV_INT", "7")
+	t.Setenv("TEST_ENV_BAD_INT", "seven")
+	t.Setenv("TEST_ENV_BOOL", "false")
+	t.Setenv("TEST_ENV_BAD_BOOL", "nope")
+	if n := envInt("TEST_ENV_INT", 1); n != 7 {
+		t.Errorf("envInt() = %d, want 7", n)
+	}
+	if n := envInt("TEST_ENV_BAD_INT", 1); n != 1 {
+		t.Errorf("envInt() of an invalid value = %d, want the default", n)
+	}
+	if n := envInt("TEST_ENV_UNSET", 3); n != 3 {
+		t.Errorf("envInt() unset = %d, want the default", n)
+	}
+	if b := envBool("TEST_ENV_BOOL", true); b {
+		t.Error("envBool() = true, want false")
+	}
+	if b := envBool("TEST_ENV_BAD_BOOL", true); !b {
+		t.Error("envBool() of an invalid value = false, want the default")
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	previous := logLevel.Level()
+	t.Cleanup(func() { logLevel.SetLevel(previous) })
+	r := gin.New()
+	r.GET("/cm_controller/v1/log/level", gin.WrapH(logLevel))
+	r.PUT("/cm_controller/v1/log/level", gin.WrapH(logLevel))
+
+	tests := []struct {
+		body      string
+		wantCode  int
+		wantLevel zapcore.Level
+	}{
+		{`{"level":"debug"}`, http.StatusOK, zap.DebugLevel},
+		{`{"level":"loud"}`, http.StatusBadRequest, zap.DebugLevel},
+		{`{"level":"error"}`, http.StatusOK, zap.ErrorLevel},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("PUT", "/cm_controller/v1/log/level", strings.NewReader(tt.body)))
+		if w.Code != tt.wantCode || logLevel.Level() != tt.wantLevel {
+			t.Errorf("%s: code %d, level %s, want %d %s", tt.body, w.Code, logLevel.Level(), tt.wantCode, tt.wantLevel)
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/log/level", nil))
+	if !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("level %s", w.Body)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Number of log lines attached to a failed run/checkpoint response
+const failureLogTail = "50"
+
+// Writer that flushes every write so followed logs reach the client immediately
+type flushWriter struct {
+	w gin.ResponseWriter
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.w.Flush()
+	return n, err
+}
+
+// Copy a service's container stdout/stderr (ff_daemon and the app) to w
+func copyServiceLogs(ctx context.Context, containerName string, options types.ContainerLogsOptions, w io.Writer) error {
+	logger.Debug("Getting container logs", zap.String("containerName", containerName), zap.Bool("follow", options.Follow))
+	containerInfo, err := getContainerInfo(containerName)
+	if err != nil {
+		return err
+	}
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	defer cli.Close()
+
+	reader, err := cli.ContainerLogs(ctx, containerName, options)
+	if err != nil {
+		logger.Error("Error getting container logs", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	defer reader.Close()
+
+	// Containers without a tty have stdout and stderr multiplexed in one stream
+	if containerInfo.Config != nil && containerInfo.Config.Tty {
+		_, err = io.Copy(w, reader)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, reader)
+	}
+	if err != nil && ctx.Err() == nil {
+		logger.Error("Error copying container logs", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Last lines of a service's container logs, empty if they cannot be read
+func getServiceLogTail(containerName string, tail string) string {
+	var buf bytes.Buffer
+	options := types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Tail: tail}
+	if err := copyServiceLogs(context.Background(), containerName, options, &buf); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+func getServiceLogsHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	if !isSubscribed(containerName) {
+		msg := "no service name " + containerName + " found!"
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	options := types.ContainerLogsOptions{
+		ShowStdout: c.DefaultQuery("stdout", "true") == "true",
+		ShowStderr: c.DefaultQuery("stderr", "true") == "true",
+		Since:      c.Query("since"),
+		Until:      c.Query("until"),
+		Tail:       c.DefaultQuery("tail", "all"),
+	}
+	var err error
+	if options.Timestamps, err = strconv.ParseBool(c.DefaultQuery("timestamps", "false")); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid timestamps value"})
+		return
+	}
+	if options.Follow, err = strconv.ParseBool(c.DefaultQuery("follow", "false")); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid follow value"})
+		return
+	}
+
+	if !options.Follow {
+		var buf bytes.Buffer
+		if err := copyServiceLogs(c.Request.Context(), containerName, options, &buf); err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Client cannot get container logs!"})
+			return
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	// Follow mode streams until the container stops or the client disconnects
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	if err := copyServiceLogs(c.Request.Context(), containerName, options, flushWriter{c.Writer}); err != nil {
+		logger.Error("Error following container logs", zap.String("containerName", containerName), zap.Error(err))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetServiceLogs(t *testing.T) {
+	r, _ := simulatedRouter(t)
+	r.GET("/cm_controller/v1/service/:name/logs", getServiceLogsHandler)
+	startSimulated(t, r, "logs-svc")
+	if code, response := serve(r, "POST", "/run/logs-svc", `{}`); code != http.StatusOK {
+		t.Fatalf("run: %d %v", code, response)
+	}
+
+	if tail := getServiceLogTail("logs-svc", "1"); strings.Count(tail, "\n") != 1 || !strings.Contains(tail, "Application started successfully") {
+		t.Errorf("log tail %q", tail)
+	}
+	if tail := getServiceLogTail("missing", "1"); tail != "" {
+		t.Errorf("log tail of a missing service %q", tail)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantLines int
+	}{
+		{"all", "", http.StatusOK, 3},
+		{"tail", "?tail=2", http.StatusOK, 2},
+		{"invalid follow", "?follow=maybe", http.StatusBadRequest, 0},
+		{"invalid timestamps", "?timestamps=maybe", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/service/logs-svc/logs"+tt.query, nil))
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: code %d %s, want %d", tt.name, w.Code, w.Body, tt.wantCode)
+			continue
+		}
+		if tt.wantCode == http.StatusOK && strings.Count(w.Body.String(), "\n") != tt.wantLines {
+			t.Errorf("%s: logs %q, want %d lines", tt.name, w.Body, tt.wantLines)
+		}
+	}
+	if code, _ := serve(r, "GET", "/service/missing/logs", ""); code != http.StatusBadRequest {
+		t.Errorf("unknown service: code %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestFollowServiceLogs(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.GET("/cm_controller/v1/service/:name/logs", getServiceLogsHandler)
+	startSimulated(t, r, "follow-svc")
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/service/follow-svc/logs?follow=true", nil))
+		close(done)
+	}()
+	sim.mu.Lock()
+	c := sim.containers["follow-svc"]
+	sim.mu.Unlock()
+	sim.log(c, "line while following")
+	sim.stop(c)
+
+	// Following ends once the container stops
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("following did not end with the container")
+	}
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "line while following") {
+		t.Errorf("followed logs: code %d %q", w.Code, w.Body)
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +15,9 @@ import (
 var workerId string
 var managerAddr string
 
+// Replace docker and ff_daemon with in-process fakes
+var simulate bool
+
 func main() {
 	logger := getGlobalLogger()
 	err := ctrl_args()
@@ -20,63 +25,199 @@ func main() {
 		logger.Error("Error parsing arguments", zap.Error(err))
 		return
 	}
+	if simulate {
+		initSimulation()
+	}
 	createRootServiceDir()
+	loadSecrets()
+	loadWebhooks()
+	loadGroups()
+	loadTemplates()
+	loadDesired()
+	if manifestPath != "" {
+		applyManifest()
+		watchManifestReload()
+	}
+	runHostPreflight()
 	checkServices()
 	r := gin.Default()
 	// define the routes
 	r.GET("/cm_controller/v1/up", upHandler)
-	r.POST("/cm_controller/v1/run/:name", runHandler)
-	r.POST("/cm_controller/v1/checkpoint/:name", checkpointHandler)
-	r.POST("/cm_controller/v1/subscribe", subscribeHandler)
-	r.POST("/cm_controller/v1/unsubscribe/:name", unsubscribeHandler)
-	r.POST("/cm_controller/v1/start", startHandler)
-	r.POST("/cm_controller/v1/stop/:name", stopHandler)
-	r.DELETE("/cm_controller/v1/remove/:name", removeHandler)
+	r.POST("/cm_controller/v1/run/:name", audit("run"), runHandler)
+	r.POST("/cm_controller/v1/checkpoint/:name", audit("checkpoint"), checkpointHandler)
+	r.POST("/cm_controller/v1/subscribe", audit("subscribe"), subscribeHandler)
+	r.POST("/cm_controller/v1/unsubscribe/:name", audit("unsubscribe"), unsubscribeHandler)
+	r.POST("/cm_controller/v1/start", audit("start"), startHandler)
+	r.POST("/cm_controller/v1/stop/:name", audit("stop"), stopHandler)
+	r.DELETE("/cm_controller/v1/remove/:name", audit("remove"), removeHandler)
 	r.GET("/cm_controller/v1/service/container_info/:name", getContainerInfoHandler)
 	r.GET("/cm_controller/v1/service/:name", getServiceInfoHandler)
 	r.GET("/cm_controller/v1/service", getAllServicesInfoHandler)
+	r.PATCH("/cm_controller/v1/service/:name/resources", audit("resources"), updateResourcesHandler)
+	r.GET("/cm_controller/v1/service/:name/stats", getServiceStatsHandler)
+	r.GET("/cm_controller/v1/stats", getAllServicesStatsHandler)
+	r.GET("/cm_controller/v1/service/:name/logs", getServiceLogsHandler)
+	r.POST("/cm_controller/v1/images/pull", pullImageHandler)
+	r.GET("/cm_controller/v1/images", listImagesHandler)
+	r.GET("/cm_controller/v1/node/preflight", preflightHandler)
+	r.GET("/cm_controller/v1/service/:name/health", getServiceHealthHandler)
+	r.GET("/cm_controller/v1/service/:name/templates", getServiceTemplatesHandler)
+	r.PUT("/cm_controller/v1/service/:name/templates", audit("templates_update"), putServiceTemplatesHandler)
+	r.DELETE("/cm_controller/v1/service/:name/templates", audit("templates_delete"), deleteServiceTemplatesHandler)
+	r.GET("/cm_controller/v1/templates", getWorkerTemplatesHandler)
+	r.PUT("/cm_controller/v1/templates", audit("templates_update"), putWorkerTemplatesHandler)
+	r.DELETE("/cm_controller/v1/templates", audit("templates_delete"), deleteWorkerTemplatesHandler)
+	r.GET("/cm_controller/v1/audit", getAuditHandler)
+	r.GET("/cm_controller/v1/admission", getAdmissionHandler)
+	r.GET("/cm_controller/v1/metrics", getMetricsHandler)
+	r.POST("/cm_controller/v1/webhooks", audit("webhook_register"), createWebhookHandler)
+	r.GET("/cm_controller/v1/webhooks", listWebhooksHandler)
+	r.DELETE("/cm_controller/v1/webhooks/:id", audit("webhook_delete"), deleteWebhookHandler)
+	r.GET("/cm_controller/v1/webhooks/deadletter", getDeadLettersHandler)
+	r.GET("/cm_controller/v1/secrets", listSecretsHandler)
+	r.PUT("/cm_controller/v1/secrets/:name", audit("secret_put"), putSecretHandler)
+	r.DELETE("/cm_controller/v1/secrets/:name", audit("secret_delete"), deleteSecretHandler)
+	r.GET("/cm_controller/v1/group", listGroupsHandler)
+	r.GET("/cm_controller/v1/group/:name", getGroupHandler)
+	r.PUT("/cm_controller/v1/group/:name", audit("group_update"), updateGroupHandler)
+	r.DELETE("/cm_controller/v1/group/:name", audit("group_delete"), deleteGroupHandler)
+	r.POST("/cm_controller/v1/group/:name/checkpoint", audit("group_checkpoint"), groupCheckpointHandler)
+	r.GET("/cm_controller/v1/desired", listDesiredHandler)
+	r.GET("/cm_controller/v1/desired/:name", getDesiredHandler)
+	r.PUT("/cm_controller/v1/desired/:name", audit("desired_update"), putDesiredHandler)
+	r.DELETE("/cm_controller/v1/desired/:name", audit("desired_delete"), deleteDesiredHandler)
+	r.GET("/cm_controller/v1/manifest", getManifestHandler)
+	r.GET("/cm_controller/v1/reconcile", getReconcileHandler)
+	r.POST("/cm_controller/v1/reconcile", audit("reconcile"), postReconcileHandler)
+	r.GET("/cm_controller/v1/log/level", gin.WrapH(logLevel))
+	r.PUT("/cm_controller/v1/log/level", audit("log_level"), gin.WrapH(logLevel))
+	if simulate {
+		r.GET("/cm_controller/v1/simulate", getSimulationHandler)
+		r.PUT("/cm_controller/v1/simulate", updateSimulationHandler)
+		r.POST("/cm_controller/v1/simulate/containers", simulateDockerRunHandler)
+	}
 
-	go func() {
-		err := r.Run(":8787")
-		if err != nil {
-			logger.Error("impossible to start server", zap.Error(err))
+	if unixSocketPath != "" {
+		if err := serveUnixSocket(r); err != nil {
+			logger.Error("impossible to listen on the unix socket", zap.String("path", unixSocketPath), zap.Error(err))
+			return
+		}
+	}
+	if grpcPort != "" {
+		if err := serveGrpc(r); err != nil {
+			logger.Error("impossible to start the gRPC server", zap.String("port", grpcPort), zap.Error(err))
+			return
 		}
-		logger.Info("server started")
-	}()
+	}
+	if !noTcp {
+		go func() {
+			err := r.Run(":8787")
+			if err != nil {
+				logger.Error("impossible to start server", zap.Error(err))
+			}
+			logger.Info("server started")
+		}()
+	}
 	//createRootServiceDir()
 
-	go func() {
-		for {
-			logger.Debug("Sending heartbeat")
-			sendHeartbeat()
-			time.Sleep(3 * time.Second) // Heartbeat every 3 seconds (adjust as needed)
-		}
-	}()
+	go reconcileLoop()
+	if autoAdopt {
+		go watchDockerEvents()
+	}
+
+	// Standalone workers provisioned by a manifest have no manager to report to
+	if managerAddr != "" {
+		go func() {
+			for {
+				logger.Debug("Sending heartbeat")
+				sendHeartbeat()
+				time.Sleep(3 * time.Second) // Heartbeat every 3 seconds (adjust as needed)
+			}
+		}()
+	}
 
 	select {}
 }
 
+const usage = "Usage: ./cm_controller --worker,-w <worker id> --manager,-m <manager address> [--manifest <file>] [--simulate]\n" +
+	"       [--unix-socket <path> [--socket-mode <octal>] [--socket-group <group>] [--no-tcp]] [--grpc-port <port>]\n" +
+	"       --manager can be left out with --manifest to run a standalone worker\n" +
+	"       --unix-socket serves the API on a unix socket too (mode 0660 by default), --no-tcp only there\n" +
+	"       --grpc-port serves the gRPC API (grpcapi/cm_controller.proto) on its own port"
+
 func ctrl_args() error {
 	args := os.Args[1:]
-	if len(args) != 4 {
-		fmt.Println("Usage: ./cm_controller --worker,-w <worker id> --manager,-m <manager address>")
-		return errors.New("Invalid number of arguments")
-	}
 	c := 0
 	for i := 0; i < len(args); i++ {
-		if args[i] == "--worker" || args[i] == "-w" {
-			workerId = args[i+1]
+		if (args[i] == "--worker" || args[i] == "-w") && i+1 < len(args) {
+			i++
+			workerId = args[i]
 			c++
-		}
-		if args[i] == "--manager" || args[i] == "-m" {
-			managerAddr = args[i+1]
+		} else if (args[i] == "--manager" || args[i] == "-m") && i+1 < len(args) {
+			i++
+			managerAddr = args[i]
 			c++
+		} else if args[i] == "--manifest" && i+1 < len(args) {
+			i++
+			manifestPath = args[i]
+		} else if args[i] == "--simulate" {
+			simulate = true
+		} else if args[i] == "--unix-socket" && i+1 < len(args) {
+			i++
+			unixSocketPath = args[i]
+		} else if args[i] == "--socket-mode" && i+1 < len(args) {
+			i++
+			if err := parseSocketMode(args[i]); err != nil {
+				fmt.Println(usage)
+				return err
+			}
+		} else if args[i] == "--socket-group" && i+1 < len(args) {
+			i++
+			unixSocketGroup = args[i]
+		} else if args[i] == "--no-tcp" {
+			noTcp = true
+		} else if args[i] == "--grpc-port" && i+1 < len(args) {
+			i++
+			if _, err := strconv.Atoi(args[i]); err != nil {
+				fmt.Println(usage)
+				return errors.New("Invalid gRPC port " + args[i])
+			}
+			grpcPort = args[i]
+		} else {
+			fmt.Println(usage)
+			return errors.New("Invalid argument " + args[i])
 		}
 	}
-	if c == 2 {
+	if noTcp && unixSocketPath == "" {
+		fmt.Println(usage)
+		return errors.New("--no-tcp needs --unix-socket")
+	}
+	if c == 2 || (c == 1 && workerId != "" && manifestPath != "") {
 		return nil
 	} else {
-		fmt.Println("Usage: ./cm_controller --worker,-w <worker id> --manager,-m <manager address>")
+		fmt.Println(usage)
 		return errors.New("Invalid arguments")
 	}
 }
+
+// Read a string from the environment, falling back to def when unset
+func envString(name string, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// Read a duration (e.g. "5s") from the environment, falling back to def when unset or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Println(fmt.Errorf("invalid %s, defaulting to %s: %w", name, def, err))
+		return def
+	}
+	return d
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// The controller keeps its state files relative to the working directory, tests run in a scratch one
+func TestMain(m *testing.M) {
+	logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "cm_controller-test-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	createRootServiceDir()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Compose-like list of the services a worker runs, given with --manifest
+type Manifest struct {
+	Services map[string]ManifestService `json:"services"`
+}
+
+// StartBody fields, desired state (running by default), run/restore arguments and an optional checkpoint schedule
+type ManifestService struct {
+	DesiredSpec
+	Schedule *CheckpointSchedule `json:"schedule,omitempty"`
+}
+
+// Periodic checkpoint of a running service, leaving it running unless the arguments say otherwise
+type CheckpointSchedule struct {
+	Every string `json:"every"`
+	// Checkpoint arguments, the service's checkpoint arguments when empty
+	Checkpoint json.RawMessage `json:"checkpoint,omitempty"`
+}
+
+type ScheduleStatus struct {
+	Service    string    `json:"service"`
+	Every      string    `json:"every"`
+	LastRun    time.Time `json:"last_run"`
+	LastResult string    `json:"last_result,omitempty"`
+	NextRun    time.Time `json:"next_run"`
+}
+
+type ManifestStatus struct {
+	Path      string           `json:"path"`
+	AppliedAt time.Time        `json:"applied_at"`
+	Error     string           `json:"error,omitempty"`
+	Services  []string         `json:"services"`
+	Schedules []ScheduleStatus `json:"schedules"`
+}
+
+var manifestPath string
+
+var manifestStatus = ManifestStatus{Services: []string{}, Schedules: []ScheduleStatus{}}
+var manifestMu sync.Mutex
+
+// Stops the schedules of the manifest applied last
+var stopSchedules context.CancelFunc = func() {}
+
+// Parse a manifest, the YAML is turned into json so the api's json field names apply
+func loadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Manifest{}, err
+	}
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(jsonData, &manifest); err != nil {
+		return Manifest{}, err
+	}
+	for name, service := range manifest.Services {
+		if service.ContainerName != "" && service.ContainerName != name {
+			return Manifest{}, errors.New("service " + name + ": container_name does not match")
+		}
+		service.ContainerName = name
+		service.Source = "manifest"
+		if service.State == "" {
+			service.State = "running"
+		}
+		if err := service.validate(); err != nil {
+			return Manifest{}, errors.New("service " + name + ": " + err.Error())
+		}
+		if service.Schedule != nil {
+			if every, err := time.ParseDuration(service.Schedule.Every); err != nil || every <= 0 {
+				return Manifest{}, errors.New("service " + name + ": invalid schedule every " + service.Schedule.Every)
+			}
+		}
+		manifest.Services[name] = service
+	}
+	return manifest, nil
+}
+
+// Load the manifest and make its services the desired state, a manifest with errors changes nothing
+func applyManifest() {
+	manifest, err := loadManifest(manifestPath)
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	manifestStatus.Path = manifestPath
+	if err != nil {
+		logger.Error("Error loading manifest, keeping the current services", zap.String("path", manifestPath), zap.Error(err))
+		manifestStatus.Error = err.Error()
+		return
+	}
+
+	desiredMu.Lock()
+	for name, status := range desired {
+		// Services dropped from the manifest are no longer managed, their containers are left as they are
+		if _, ok := manifest.Services[name]; !ok && status.Spec.Source == "manifest" {
+			delete(desired, name)
+		}
+	}
+	names := []string{}
+	for name, service := range manifest.Services {
+		status := &DesiredStatus{Spec: service.DesiredSpec}
+		if previous, ok := desired[name]; ok {
+			status.Observed = previous.Observed
+			status.LastReconcile = previous.LastReconcile
+		}
+		desired[name] = status
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	err = saveDesired()
+	desiredMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving desired state", zap.Error(err))
+	}
+
+	stopSchedules()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopSchedules = cancel
+	manifestStatus.Schedules = []ScheduleStatus{}
+	for name, service := range manifest.Services {
+		if service.Schedule != nil {
+			every, _ := time.ParseDuration(service.Schedule.Every)
+			manifestStatus.Schedules = append(manifestStatus.Schedules, ScheduleStatus{Service: name, Every: service.Schedule.Every, NextRun: time.Now().Add(every)})
+			go runSchedule(ctx, name, service, every)
+		}
+	}
+	manifestStatus.AppliedAt = time.Now()
+	manifestStatus.Error = ""
+	manifestStatus.Services = names
+	logger.Info("Manifest applied", zap.String("path", manifestPath), zap.Int("services", len(names)), zap.Int("schedules", len(manifestStatus.Schedules)))
+	kickReconcile()
+}
+
+func runSchedule(ctx context.Context, name string, service ManifestService, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		result := scheduledCheckpoint(name, service)
+		manifestMu.Lock()
+		for i := range manifestStatus.Schedules {
+			if manifestStatus.Schedules[i].Service == name {
+				manifestStatus.Schedules[i].LastRun = time.Now()
+				manifestStatus.Schedules[i].LastResult = result
+				manifestStatus.Schedules[i].NextRun = time.Now().Add(every)
+			}
+		}
+		manifestMu.Unlock()
+	}
+}
+
+func scheduledCheckpoint(name string, service ManifestService) string {
+	if observed, err := observeService(name); err != nil || observed != "running" {
+		logger.Debug("Scheduled checkpoint skipped, application not running", zap.String("containerName", name))
+		return "skipped"
+	}
+	args := map[string]interface{}{"leave_running": true}
+	raw := service.Schedule.Checkpoint
+	if len(raw) == 0 {
+		raw = service.Checkpoint
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			logger.Error("Invalid scheduled checkpoint arguments", zap.String("containerName", name), zap.Error(err))
+			return "failure"
+		}
+	}
+	requestBody, _ := json.Marshal(args)
+	logger.Info("Scheduled checkpoint", zap.String("containerName", name))
+	if err := fastFreezeOperation(name, 1, requestBody); err != nil {
+		logger.Error("Scheduled checkpoint failed", zap.String("containerName", name), zap.Error(err))
+		return "failure"
+	}
+	return "success"
+}
+
+// Re-apply the manifest whenever the controller gets a SIGHUP
+func watchManifestReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("SIGHUP received, re-applying manifest", zap.String("path", manifestPath))
+			applyManifest()
+		}
+	}()
+}
+
+func getManifestHandler(c *gin.Context) {
+	if manifestPath == "" {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "No manifest given, start the controller with --manifest"})
+		return
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	c.IndentedJSON(http.StatusOK, manifestStatus)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeManifest(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "manifest.yml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Manifest, desired state and schedules of the test, all dropped when it ends
+func useManifest(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previousManifest, previousDesired := manifestPath, desiredPath
+	desiredPath = filepath.Join(dir, "desired.json")
+	t.Cleanup(func() {
+		manifestMu.Lock()
+		stopSchedules()
+		stopSchedules = func() {}
+		manifestStatus = ManifestStatus{Services: []string{}, Schedules: []ScheduleStatus{}}
+		manifestMu.Unlock()
+		desiredMu.Lock()
+		desired = make(map[string]*DesiredStatus)
+		desiredMu.Unlock()
+		manifestPath, desiredPath = previousManifest, previousDesired
+	})
+	return dir
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", `
+services:
+  web:
+    image: nginx
+    app_ports: ["8080:80"]
+    run: {image_url: "s3://bucket/web"}
+    schedule: {every: 10m}
+  batch:
+    image: batch
+    state: checkpointed
+    checkpoint: {image_url: "s3://bucket/batch"}
+`, ""},
+		{"not yaml", "services: [", "yaml"},
+		{"container name mismatch", "services:\n  web: {image: nginx, container_name: other}\n", "container_name does not match"},
+		{"invalid state", "services:\n  web: {image: nginx, state: paused}\n", "service web"},
+		{"invalid schedule", "services:\n  web: {image: nginx, schedule: {every: often}}\n", "invalid schedule every often"},
+		{"negative schedule", "services:\n  web: {image: nginx, schedule: {every: -1m}}\n", "invalid schedule every -1m"},
+	}
+	for _, tt := range tests {
+		manifest, err := loadManifest(writeManifest(t, dir, tt.content))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		web, batch := manifest.Services["web"], manifest.Services["batch"]
+		// The name, the source and the running state default from the manifest
+		if web.ContainerName != "web" || web.Source != "manifest" || web.State != "running" || web.Image != "nginx" || len(web.AppPorts) != 1 {
+			t.Errorf("web %+v", web.DesiredSpec)
+		}
+		if web.Schedule == nil || web.Schedule.Every != "10m" {
+			t.Errorf("web schedule %+v", web.Schedule)
+		}
+		var run map[string]string
+		if err := json.Unmarshal(web.Run, &run); err != nil || run["image_url"] != "s3://bucket/web" {
+			t.Errorf("web run %s: %v", web.Run, err)
+		}
+		if batch.State != "checkpointed" || batch.Schedule != nil || !strings.Contains(string(batch.Checkpoint), "s3://bucket/batch") {
+			t.Errorf("batch %+v", batch)
+		}
+	}
+	if _, err := loadManifest(filepath.Join(dir, "missing.yml")); !os.IsNotExist(err) {
+		t.Errorf("missing manifest: %v", err)
+	}
+}
+
+func TestApplyManifest(t *testing.T) {
+	dir := useManifest(t)
+	desired["from-api"] = &DesiredStatus{Spec: DesiredSpec{StartBody: StartBody{ContainerName: "from-api", Image: "img"}, State: "running", Source: "api"}}
+	manifestPath = writeManifest(t, dir, "services:\n  a: {image: img}\n  b: {image: img, schedule: {every: 1h}}\n")
+	applyManifest()
+
+	manifestPath = writeManifest(t, dir, "services:\n  a: {image: img, state: stopped}\n")
+	applyManifest()
+	// A manifest with errors changes nothing
+	manifestPath = writeManifest(t, dir, "services:\n  a: {image: img, state: gone}\n")
+	applyManifest()
+
+	desiredMu.Lock()
+	var names []string
+	for name := range desired {
+		names = append(names, name)
+	}
+	a := desired["a"]
+	desiredMu.Unlock()
+	// b was dropped from the manifest, the spec set through the api stays
+	if len(names) != 2 || a == nil || desired["from-api"] == nil {
+		t.Fatalf("desired services %v", names)
+	}
+	if a.Spec.State != "stopped" {
+		t.Errorf("a state %s, want stopped", a.Spec.State)
+	}
+	if data, err := os.ReadFile(desiredPath); err != nil || strings.Contains(string(data), `"b"`) {
+		t.Errorf("desired file %s: %v", data, err)
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	if len(manifestStatus.Services) != 1 || manifestStatus.Services[0] != "a" || len(manifestStatus.Schedules) != 0 || !strings.Contains(manifestStatus.Error, "service a") {
+		t.Errorf("manifest status %+v", manifestStatus)
+	}
+}
+
+func TestScheduledCheckpoint(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	startSimulated(t, r, "sched-idle")
+	startSimulated(t, r, "sched-svc")
+	if code, response := serve(r, "POST", "/run/sched-svc", `{}`); code != http.StatusOK {
+		t.Fatalf("run: %d %v", code, response)
+	}
+	tests := []struct {
+		name       string
+		service    string
+		schedule   string
+		checkpoint string
+		want       string
+		wantImage  string
+		wantStatus string
+	}{
+		{"application not running", "sched-idle", "", `{"image_url":"file:/tmp/sched-idle"}`, "skipped", "", "standby"},
+		{"service checkpoint arguments", "sched-svc", "", `{"image_url":"file:/tmp/sched-service"}`, "success", "file:/tmp/sched-service", "running"},
+		{"schedule arguments first", "sched-svc", `{"image_url":"file:/tmp/sched-schedule"}`, `{"image_url":"file:/tmp/sched-service"}`, "success", "file:/tmp/sched-schedule", "running"},
+		{"invalid arguments", "sched-svc", `[1]`, "", "failure", "", "running"},
+	}
+	for _, tt := range tests {
+		service := ManifestService{Schedule: &CheckpointSchedule{Every: "1m", Checkpoint: json.RawMessage(tt.schedule)}}
+		service.Checkpoint = json.RawMessage(tt.checkpoint)
+		if got := scheduledCheckpoint(tt.service, service); got != tt.want {
+			t.Errorf("%s: scheduledCheckpoint() = %s, want %s", tt.name, got, tt.want)
+		}
+		// A scheduled checkpoint leaves the application running
+		if service, _ := getService(tt.service); service.Status != tt.wantStatus {
+			t.Errorf("%s: status %s, want %s", tt.name, service.Status, tt.wantStatus)
+		}
+		if tt.wantImage == "" {
+			continue
+		}
+		sim.mu.Lock()
+		_, ok := sim.checkpoints[tt.wantImage]
+		sim.mu.Unlock()
+		if !ok {
+			t.Errorf("%s: no checkpoint to %s", tt.name, tt.wantImage)
+		}
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	r, _ := simulatedRouter(t)
+	useManifest(t)
+	startSimulated(t, r, "sched-loop")
+	if code, response := serve(r, "POST", "/run/sched-loop", `{}`); code != http.StatusOK {
+		t.Fatalf("run: %d %v", code, response)
+	}
+	dir := t.TempDir()
+	manifestPath = writeManifest(t, dir, "services:\n  sched-loop:\n    image: img\n    schedule: {every: 20ms, checkpoint: {image_url: \"file:"+dir+"/cp\"}}\n")
+	applyManifest()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		manifestMu.Lock()
+		schedules := append([]ScheduleStatus(nil), manifestStatus.Schedules...)
+		manifestMu.Unlock()
+		if len(schedules) != 1 {
+			t.Fatalf("schedules %+v", schedules)
+		}
+		if schedules[0].LastResult != "" {
+			if schedules[0].Service != "sched-loop" || schedules[0].LastResult != "success" || !schedules[0].NextRun.After(schedules[0].LastRun) {
+				t.Errorf("schedule %+v", schedules[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("scheduled checkpoint did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
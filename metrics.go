@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Metrics struct {
+	UptimeSeconds float64                 `json:"uptime_seconds"`
+	Services      int                     `json:"services"`
+	Retries       map[string]RetryMetrics `json:"retries"`
+}
+
+var startTime = time.Now()
+
+func getMetricsHandler(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, Metrics{
+		UptimeSeconds: time.Since(startTime).Seconds(),
+		Services:      len(listServices()),
+		Retries:       getRetryMetrics(),
+	})
+}
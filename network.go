@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Network identity of a service's container. It is kept in networks/<name>.json, out of the
+// service dir, so a container re-created after a remove or a migration gets it back.
+type NetworkConfig struct {
+	Name       string   `json:"name"`
+	IPv4       string   `json:"ipv4"`
+	IPv6       string   `json:"ipv6"`
+	Hostname   string   `json:"hostname"`
+	Dns        []string `json:"dns"`
+	ExtraHosts []string `json:"extra_hosts"`
+	Aliases    []string `json:"aliases"`
+}
+
+const networksDir = "networks/"
+
+func (n NetworkConfig) isEmpty() bool {
+	return reflect.DeepEqual(n, NetworkConfig{})
+}
+
+func (n NetworkConfig) validate() error {
+	// Docker only assigns static addresses and aliases on user-defined networks
+	userDefined := n.Name != "" && n.Name != "bridge" && n.Name != "host" && n.Name != "none"
+	if (n.IPv4 != "" || n.IPv6 != "" || len(n.Aliases) > 0) && !userDefined {
+		return errors.New("ipv4, ipv6 and aliases need a user-defined network")
+	}
+	if n.IPv4 != "" {
+		if ip := net.ParseIP(n.IPv4); ip == nil || ip.To4() == nil {
+			return errors.New("invalid ipv4 " + n.IPv4)
+		}
+	}
+	if n.IPv6 != "" {
+		if ip := net.ParseIP(n.IPv6); ip == nil || ip.To4() != nil {
+			return errors.New("invalid ipv6 " + n.IPv6)
+		}
+	}
+	for _, dns := range n.Dns {
+		if net.ParseIP(dns) == nil {
+			return errors.New("invalid dns server " + dns)
+		}
+	}
+	for _, extraHost := range n.ExtraHosts {
+		// host:ip, the ip may be an ipv6 or docker's host-gateway
+		host, ip, ok := strings.Cut(extraHost, ":")
+		if !ok || host == "" || (ip != "host-gateway" && net.ParseIP(ip) == nil) {
+			return errors.New("invalid extra host " + extraHost + ", expected host:ip")
+		}
+	}
+	if n.Name == "host" && n.Hostname != "" {
+		return errors.New("hostname cannot be set on the host network")
+	}
+	return nil
+}
+
+func (n NetworkConfig) dockerRunArgs() []string {
+	var args []string
+	if n.Name != "" {
+		args = append(args, "--network", n.Name)
+	}
+	if n.IPv4 != "" {
+		args = append(args, "--ip", n.IPv4)
+	}
+	if n.IPv6 != "" {
+		args = append(args, "--ip6", n.IPv6)
+	}
+	if n.Hostname != "" {
+		args = append(args, "--hostname", n.Hostname)
+	}
+	for _, dns := range n.Dns {
+		args = append(args, "--dns", dns)
+	}
+	for _, extraHost := range n.ExtraHosts {
+		args = append(args, "--add-host", extraHost)
+	}
+	for _, alias := range n.Aliases {
+		args = append(args, "--network-alias", alias)
+	}
+	return args
+}
+
+// Stored network config of a service, false when it has none
+func loadServiceNetwork(containerName string) (NetworkConfig, bool) {
+	data, err := os.ReadFile(networksDir + containerName + ".json")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("Error reading network config", zap.String("containerName", containerName), zap.Error(err))
+		}
+		return NetworkConfig{}, false
+	}
+	var network NetworkConfig
+	if err := json.Unmarshal(data, &network); err != nil {
+		logger.Error("Error parsing network config", zap.String("containerName", containerName), zap.Error(err))
+		return NetworkConfig{}, false
+	}
+	return network, true
+}
+
+func saveServiceNetwork(containerName string, network NetworkConfig) error {
+	if err := os.MkdirAll(networksDir, os.ModePerm); err != nil {
+		return err
+	}
+	return writeJSONFile(networksDir+containerName+".json", network)
+}
+
+// Network config a new container of the service is created with: the requested one, which is
+// stored, or else the one stored by an earlier start
+func resolveServiceNetwork(containerName string, requested NetworkConfig) (NetworkConfig, error) {
+	if requested.isEmpty() {
+		if stored, ok := loadServiceNetwork(containerName); ok {
+			logger.Info("Reusing stored network config", zap.String("containerName", containerName), zap.String("network", stored.Name))
+			return stored, nil
+		}
+		return requested, nil
+	}
+	if err := requested.validate(); err != nil {
+		return NetworkConfig{}, err
+	}
+	if err := saveServiceNetwork(containerName, requested); err != nil {
+		logger.Error("Error saving network config", zap.String("containerName", containerName), zap.Error(err))
+		return NetworkConfig{}, err
+	}
+	return requested, nil
+}
+
+// Store the hostname docker picked (the short container id) so a re-created container keeps it
+func pinServiceHostname(containerName string, network NetworkConfig) {
+	containerInfo, err := getContainerInfo(containerName)
+	if err != nil || containerInfo.Config == nil || containerInfo.Config.Hostname == "" {
+		return
+	}
+	network.Hostname = containerInfo.Config.Hostname
+	if err := saveServiceNetwork(containerName, network); err != nil {
+		logger.Error("Error saving network config", zap.String("containerName", containerName), zap.Error(err))
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if entry, ok := services[containerName]; ok {
+		entry.Network = &network
+		services[containerName] = entry
+	}
+}
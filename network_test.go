@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestNetworkConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		network NetworkConfig
+		wantErr bool
+	}{
+		{"empty", NetworkConfig{}, false},
+		{"user-defined with addresses", NetworkConfig{Name: "apps", IPv4: "10.0.0.5", IPv6: "fd00::5", Aliases: []string{"db"}}, false},
+		{"ipv4 on the default bridge", NetworkConfig{IPv4: "172.17.0.5"}, true},
+		{"ipv4 on bridge", NetworkConfig{Name: "bridge", IPv4: "172.17.0.5"}, true},
+		{"aliases on host", NetworkConfig{Name: "host", Aliases: []string{"db"}}, true},
+		{"ipv6 given as ipv4", NetworkConfig{Name: "apps", IPv4: "fd00::5"}, true},
+		{"ipv4 given as ipv6", NetworkConfig{Name: "apps", IPv6: "10.0.0.5"}, true},
+		{"invalid ipv4", NetworkConfig{Name: "apps", IPv4: "10.0.0"}, true},
+		{"dns servers", NetworkConfig{Dns: []string{"1.1.1.1", "2606:4700:4700::1111"}}, false},
+		{"dns name", NetworkConfig{Dns: []string{"dns.example.com"}}, true},
+		{"extra hosts", NetworkConfig{ExtraHosts: []string{"db:10.0.0.5", "gw:host-gateway", "v6:fd00::5"}}, false},
+		{"extra host without ip", NetworkConfig{ExtraHosts: []string{"db"}}, true},
+		{"extra host without name", NetworkConfig{ExtraHosts: []string{":10.0.0.5"}}, true},
+		{"extra host with a name for ip", NetworkConfig{ExtraHosts: []string{"db:db.local"}}, true},
+		{"hostname", NetworkConfig{Name: "apps", Hostname: "svc"}, false},
+		{"hostname on host", NetworkConfig{Name: "host", Hostname: "svc"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.network.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	preflightOk   = "ok"
+	preflightWarn = "warn"
+	preflightFail = "fail"
+)
+
+// CAP_CHECKPOINT_RESTORE is capability 40, added in linux 5.9
+const capCheckpointRestore = 40
+
+type PreflightCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type PreflightReport struct {
+	Ok     bool             `json:"ok"`
+	Time   time.Time        `json:"time"`
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// Last host report, sent along with the heartbeat
+var nodePreflight PreflightReport
+var preflightMu sync.Mutex
+
+func newPreflightReport(checks []PreflightCheck) PreflightReport {
+	report := PreflightReport{Ok: true, Time: time.Now(), Checks: checks}
+	for _, check := range checks {
+		if check.Status == preflightFail {
+			report.Ok = false
+		}
+	}
+	return report
+}
+
+// Error listing every failed check of the report, nil if none failed
+func (r PreflightReport) err() error {
+	var failed []string
+	for _, check := range r.Checks {
+		if check.Status == preflightFail {
+			failed = append(failed, check.Name+": "+check.Detail)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("preflight failed (" + strings.Join(failed, "; ") + ")")
+}
+
+// Check the host for checkpoint/restore readiness and cache the result
+func runHostPreflight() PreflightReport {
+	logger.Debug("Running host preflight")
+	var report PreflightReport
+	if simulate {
+		// The host is never used for checkpoint/restore in simulation
+		report = newPreflightReport([]PreflightCheck{{Name: "simulate", Status: preflightOk, Detail: "host checks skipped"}, checkDocker()})
+	} else {
+		report = newPreflightReport([]PreflightCheck{
+			checkKernelVersion(),
+			checkCapCheckpointRestore(),
+			checkNsLastPid(),
+			checkPtraceScope(),
+			checkCgroupVersion(),
+			checkAppArmor(),
+			checkDocker(),
+		})
+	}
+	preflightMu.Lock()
+	nodePreflight = report
+	preflightMu.Unlock()
+	if !report.Ok {
+		logger.Warn("Host preflight failed", zap.Error(report.err()))
+	}
+	return report
+}
+
+func getNodePreflight() PreflightReport {
+	preflightMu.Lock()
+	defer preflightMu.Unlock()
+	return nodePreflight
+}
+
+// Check that the image can run as a service (ff_daemon is inside)
+func checkImage(imageName string) PreflightCheck {
+	check := PreflightCheck{Name: "image"}
+	ffCapable, err := imageHasFFDaemon(imageName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			check.Status = preflightWarn
+			check.Detail = imageName + " is not on the host, docker run will pull it"
+			return check
+		}
+		check.Status = preflightFail
+		check.Detail = "cannot inspect " + imageName + ": " + err.Error()
+		return check
+	}
+	if !ffCapable {
+		check.Status = preflightFail
+		check.Detail = "ff_daemon not found in " + imageName
+		return check
+	}
+	check.Status = preflightOk
+	check.Detail = "ff_daemon found in " + imageName
+	return check
+}
+
+// Check the image against earlier ff_daemon checks only, a start never creates the temporary container
+func checkKnownImage(imageName string) PreflightCheck {
+	check := PreflightCheck{Name: "image", Status: preflightWarn, Detail: "ff_daemon not checked in " + imageName}
+	cli, err := newDockerClient()
+	if err != nil {
+		return check
+	}
+	defer cli.Close()
+
+	imageInfo, _, err := cli.ImageInspectWithRaw(context.Background(), imageName)
+	if err != nil {
+		return check
+	}
+	ffCapableMu.Lock()
+	ffCapable, ok := ffCapableCache[imageInfo.ID]
+	ffCapableMu.Unlock()
+	if !ok {
+		return check
+	}
+	if !ffCapable {
+		check.Status = preflightFail
+		check.Detail = "ff_daemon not found in " + imageName
+		return check
+	}
+	check.Status = preflightOk
+	check.Detail = "ff_daemon found in " + imageName
+	return check
+}
+
+func checkKernelVersion() PreflightCheck {
+	check := PreflightCheck{Name: "kernel"}
+	var uname syscall.Utsname
+	if err := syscall.Uname(&uname); err != nil {
+		check.Status = preflightFail
+		check.Detail = err.Error()
+		return check
+	}
+	var release strings.Builder
+	for _, c := range uname.Release {
+		if c == 0 {
+			break
+		}
+		release.WriteByte(byte(c))
+	}
+	var major, minor int
+	fmt.Sscanf(release.String(), "%d.%d", &major, &minor)
+	if major > 5 || (major == 5 && minor >= 9) {
+		check.Status = preflightOk
+		check.Detail = release.String()
+	} else {
+		check.Status = preflightWarn
+		check.Detail = release.String() + " is older than 5.9, checkpoint/restore needs CAP_SYS_ADMIN"
+	}
+	return check
+}
+
+func checkCapCheckpointRestore() PreflightCheck {
+	check := PreflightCheck{Name: "cap_checkpoint_restore"}
+	// Older kernels still checkpoint with CAP_SYS_ADMIN, like the kernel check this only warns
+	lastCap, err := readSysctl("kernel/cap_last_cap")
+	if err != nil {
+		check.Status = preflightWarn
+		check.Detail = err.Error() + ", checkpoint/restore needs CAP_SYS_ADMIN"
+		return check
+	}
+	if n, err := strconv.Atoi(lastCap); err != nil || n < capCheckpointRestore {
+		check.Status = preflightWarn
+		check.Detail = "kernel does not support cap_checkpoint_restore (cap_last_cap=" + lastCap + "), checkpoint/restore needs CAP_SYS_ADMIN"
+		return check
+	}
+	check.Status = preflightOk
+	check.Detail = "supported"
+	return check
+}
+
+func checkNsLastPid() PreflightCheck {
+	check := PreflightCheck{Name: "kernel.ns_last_pid"}
+	if _, err := readSysctl("kernel/ns_last_pid"); err != nil {
+		check.Status = preflightFail
+		check.Detail = "missing, kernel built without CONFIG_CHECKPOINT_RESTORE"
+		return check
+	}
+	check.Status = preflightOk
+	check.Detail = "present"
+	return check
+}
+
+func checkPtraceScope() PreflightCheck {
+	check := PreflightCheck{Name: "kernel.yama.ptrace_scope"}
+	scope, err := readSysctl("kernel/yama/ptrace_scope")
+	if err != nil {
+		// No yama, ptrace is not restricted
+		check.Status = preflightOk
+		check.Detail = "yama not enabled"
+		return check
+	}
+	if scope == "2" || scope == "3" {
+		check.Status = preflightFail
+		check.Detail = "ptrace_scope=" + scope + " prevents criu from attaching to the application"
+		return check
+	}
+	check.Status = preflightOk
+	check.Detail = "ptrace_scope=" + scope
+	return check
+}
+
+func checkCgroupVersion() PreflightCheck {
+	check := PreflightCheck{Name: "cgroup", Status: preflightOk}
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		check.Detail = "v2"
+	} else {
+		check.Detail = "v1"
+	}
+	return check
+}
+
+func checkAppArmor() PreflightCheck {
+	check := PreflightCheck{Name: "apparmor", Status: preflightOk}
+	enabled, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	if err != nil || strings.TrimSpace(string(enabled)) != "Y" {
+		check.Detail = "disabled"
+		return check
+	}
+	// Services are started with apparmor=unconfined, adopted containers may not be
+	check.Detail = "enabled, subscribed containers must run unconfined"
+	return check
+}
+
+func checkDocker() PreflightCheck {
+	check := PreflightCheck{Name: "docker"}
+	cli, err := newDockerClient()
+	if err != nil {
+		check.Status = preflightFail
+		check.Detail = err.Error()
+		return check
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		check.Status = preflightFail
+		check.Detail = err.Error()
+		return check
+	}
+	check.Status = preflightOk
+	check.Detail = "api " + ping.APIVersion
+	return check
+}
+
+func readSysctl(name string) (string, error) {
+	value, err := os.ReadFile("/proc/sys/" + name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func preflightHandler(c *gin.Context) {
+	report := runHostPreflight()
+	if image := c.Query("image"); image != "" {
+		report = newPreflightReport(append(report.Checks, checkImage(image)))
+	}
+	c.IndentedJSON(http.StatusOK, report)
+}
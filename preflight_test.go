@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPreflightReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  []PreflightCheck
+		wantOk  bool
+		wantErr string
+	}{
+		{"empty", nil, true, ""},
+		{"warnings only", []PreflightCheck{{"kernel", preflightWarn, "old"}, {"cgroup", preflightOk, "v2"}}, true, ""},
+		{"failures listed", []PreflightCheck{{"a", preflightFail, "x"}, {"b", preflightWarn, "y"}, {"c", preflightFail, "z"}}, false,
+			"preflight failed (a: x; c: z)"},
+	}
+	for _, tt := range tests {
+		report := newPreflightReport(tt.checks)
+		if report.Ok != tt.wantOk {
+			t.Errorf("%s: ok %v, want %v", tt.name, report.Ok, tt.wantOk)
+		}
+		if err := report.err(); (err == nil && tt.wantErr != "") || (err != nil && err.Error() != tt.wantErr) {
+			t.Errorf("%s: err %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckKnownImage(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	sim.mu.Lock()
+	withFF := sim.addImage("with-ff:1")
+	withoutFF := sim.addImage("without-ff:1")
+	sim.addImage("unchecked:1")
+	sim.mu.Unlock()
+	ffCapableMu.Lock()
+	ffCapableCache[withFF.ID] = true
+	ffCapableCache[withoutFF.ID] = false
+	ffCapableMu.Unlock()
+
+	tests := []struct {
+		image      string
+		wantStatus string
+		wantStart  int
+	}{
+		{"with-ff:1", preflightOk, http.StatusOK},
+		{"without-ff:1", preflightFail, http.StatusInternalServerError},
+		{"unchecked:1", preflightWarn, http.StatusOK},
+		{"not-pulled:1", preflightWarn, http.StatusOK},
+	}
+	for i, tt := range tests {
+		if check := checkKnownImage(tt.image); check.Status != tt.wantStatus {
+			t.Errorf("%s: check %+v, want %s", tt.image, check, tt.wantStatus)
+		}
+		name := "preflight-svc-" + string(rune('a'+i))
+		if code, response := serve(r, "POST", "/start", `{"container_name":"`+name+`","image":"`+tt.image+`"}`); code != tt.wantStart {
+			t.Errorf("%s: start code %d %v, want %d", tt.image, code, response, tt.wantStart)
+		}
+	}
+}
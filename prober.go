@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Consecutive failed probes after which ff_daemon is considered dead
+const probeFailureThreshold = 3
+
+var probeInterval = envDuration("PROBE_INTERVAL", 5*time.Second)
+var probeTimeout = envDuration("PROBE_TIMEOUT", 2*time.Second)
+
+// Cancel functions of the running per-service probers
+var probers = make(map[string]context.CancelFunc)
+var probersMu sync.Mutex
+
+// Start probing a service in the background, no-op if it is already probed
+func startProber(containerName string) {
+	probersMu.Lock()
+	defer probersMu.Unlock()
+	if _, ok := probers[containerName]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	probers[containerName] = cancel
+	logger.Debug("Starting prober", zap.String("containerName", containerName))
+	go func() {
+		ticker := time.NewTicker(probeInterval)
+		defer ticker.Stop()
+		for {
+			probeService(ctx, containerName)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func stopProber(containerName string) {
+	probersMu.Lock()
+	defer probersMu.Unlock()
+	if cancel, ok := probers[containerName]; ok {
+		cancel()
+		delete(probers, containerName)
+		logger.Debug("Prober stopped", zap.String("containerName", containerName))
+	}
+}
+
+// Refresh the cached status of a service and check its ff_daemon over the daemon port
+func probeService(ctx context.Context, containerName string) {
+	service, ok := getService(containerName)
+	if !ok {
+		stopProber(containerName)
+		return
+	}
+	previousStatus := service.Status
+	// Unsubscribes the service if its container is gone
+	status, err := service.refreshStatus()
+	if err == nil && status == "" {
+		return
+	}
+	if err != nil {
+		// docker did not answer, the probe fails but the service and its cached status are kept
+		health := service.Health
+		health.LastProbe = time.Now()
+		health.Failures++
+		health.Ready = false
+		health.Live = health.Failures < probeFailureThreshold
+		health.Error = "docker: " + err.Error()
+		updateServiceHealth(containerName, health)
+		return
+	}
+	service, ok = getService(containerName)
+	if !ok {
+		return
+	}
+	if service.Status == "exited" && previousStatus != "exited" {
+		logger.Warn("Container exited", zap.String("containerName", containerName), zap.String("previousStatus", previousStatus))
+		notify("container.exited", containerName, map[string]interface{}{"previous_status": previousStatus})
+	}
+
+	health := service.Health
+	health.LastProbe = time.Now()
+	if service.Status == "exited" || service.Status == "created" || service.Status == "paused" {
+		health.Ready = false
+		health.Live = false
+		health.Error = "container " + service.Status
+		updateServiceHealth(containerName, health)
+		return
+	}
+
+	if err := probeDaemon(ctx, service.DaemonPort); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		health.Failures++
+		health.Ready = false
+		health.Live = health.Failures < probeFailureThreshold
+		health.Error = err.Error()
+		logger.Debug("ff_daemon probe failed", zap.String("containerName", containerName), zap.Int("failures", health.Failures), zap.Error(err))
+	} else {
+		health.Failures = 0
+		health.Ready = true
+		health.Live = true
+		health.LastSuccess = health.LastProbe
+		health.Error = ""
+	}
+	updateServiceHealth(containerName, health)
+}
+
+// Any HTTP response means ff_daemon is up and serving, only transport errors count as failures
+func probeDaemon(ctx context.Context, daemonPort string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://127.0.0.1:"+daemonPort+"/", nil)
+	if err != nil {
+		return err
+	}
+	req.Close = true
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func updateServiceHealth(containerName string, health ServiceHealth) {
+	mu.Lock()
+	defer mu.Unlock()
+	if entry, ok := services[containerName]; ok {
+		entry.Health = health
+		services[containerName] = entry
+	}
+}
+
+func getServiceHealthHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	service, ok := getService(containerName)
+	if !ok {
+		msg := "no service name " + containerName + " found!"
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !service.Health.Ready {
+		c.IndentedJSON(http.StatusServiceUnavailable, service.Health)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, service.Health)
+}
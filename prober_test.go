@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeDaemon(t *testing.T) {
+	// Any answer counts, even an error status
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err := probeDaemon(context.Background(), port); err != nil {
+		t.Errorf("probe of a serving daemon: %v", err)
+	}
+	server.Close()
+	if err := probeDaemon(context.Background(), port); err == nil {
+		t.Error("probe of a closed port succeeded")
+	}
+}
+
+func TestProbeService(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.GET("/cm_controller/v1/service/:name/health", getServiceHealthHandler)
+	startSimulated(t, r, "probe-svc")
+	// Probes are driven by the test
+	stopProber("probe-svc")
+	sim.mu.Lock()
+	c := sim.containers["probe-svc"]
+	sim.mu.Unlock()
+
+	steps := []struct {
+		name        string
+		action      func()
+		wantReady   bool
+		wantLive    bool
+		wantFailure int
+		wantCode    int
+	}{
+		{"daemon up", func() {}, true, true, 0, http.StatusOK},
+		{"daemon down once", func() { c.daemon.server.Close() }, false, true, 1, http.StatusServiceUnavailable},
+		{"daemon down twice", func() {}, false, true, 2, http.StatusServiceUnavailable},
+		{"dead at the threshold", func() {}, false, false, probeFailureThreshold, http.StatusServiceUnavailable},
+		{"container exited", func() { sim.stop(c) }, false, false, probeFailureThreshold, http.StatusServiceUnavailable},
+	}
+	for _, step := range steps {
+		step.action()
+		probeService(context.Background(), "probe-svc")
+		service, _ := getService("probe-svc")
+		health := service.Health
+		if health.Ready != step.wantReady || health.Live != step.wantLive || health.Failures != step.wantFailure {
+			t.Errorf("%s: health %+v", step.name, health)
+		}
+		if code, response := serve(r, "GET", "/service/probe-svc/health", ""); code != step.wantCode {
+			t.Errorf("%s: health code %d %v, want %d", step.name, code, response, step.wantCode)
+		}
+	}
+
+	// A removed container unsubscribes the service
+	sim.mu.Lock()
+	delete(sim.containers, "probe-svc")
+	sim.mu.Unlock()
+	probeService(context.Background(), "probe-svc")
+	if isSubscribed("probe-svc") {
+		t.Error("service of a removed container still subscribed")
+	}
+	if code, _ := serve(r, "GET", "/service/probe-svc/health", ""); code != http.StatusBadRequest {
+		t.Errorf("health of an unsubscribed service: code %d", code)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/errdefs"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Set on every container the controller runs, so ones it lost track of can be found again
+const managedLabel = "cm_controller.managed"
+
+// Something out of step between the service dirs, the registry and docker, with the actions that fix it:
+//
+//	dir_without_container      service dir (and registry entry) left by a container that is gone: cleanup deletes them
+//	container_without_service  managed container the registry does not know: adopt subscribes it, cleanup stops and removes it
+//	port_mismatch              port file, registry and docker disagree on ff_daemon's port: adopt takes docker's
+//	stale_fifo                 comms status missing, of the wrong type or a dangling link: cleanup re-creates it
+type ReconcileFinding struct {
+	Kind        string   `json:"kind"`
+	Service     string   `json:"service"`
+	ContainerId string   `json:"container_id,omitempty"`
+	Detail      string   `json:"detail"`
+	Actions     []string `json:"actions"`
+}
+
+type ReconcileReport struct {
+	Findings []ReconcileFinding `json:"findings"`
+	Errors   []string           `json:"errors,omitempty"`
+}
+
+// Action applied to the current findings, narrowed to one kind and/or one service
+type ReconcileBody struct {
+	Action  string `json:"action"`
+	Kind    string `json:"kind"`
+	Service string `json:"service"`
+}
+
+type ReconcileResult struct {
+	Finding ReconcileFinding `json:"finding"`
+	Action  string           `json:"action"`
+	Result  string           `json:"result"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// One reconcile report or action at a time, so an action never works on a report another one changed
+var reconcileMu sync.Mutex
+
+func reconcileReport() ReconcileReport {
+	report := ReconcileReport{Findings: []ReconcileFinding{}}
+	cli, err := newDockerClient()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	ctx := context.Background()
+
+	dirEntries, err := os.ReadDir("services/")
+	if err != nil {
+		report.Errors = append(report.Errors, "reading services dir: "+err.Error())
+	}
+	dirs := make(map[string]bool)
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		name := dirEntry.Name()
+		dirs[name] = true
+		var containerInfo types.ContainerJSON
+		err := retryDocker(ctx, "docker_inspect", name, func() error {
+			var err error
+			containerInfo, err = cli.ContainerInspect(ctx, name)
+			return err
+		})
+		if errdefs.IsNotFound(err) {
+			detail := "no container " + name
+			if isSubscribed(name) {
+				detail += ", the service is still subscribed"
+			}
+			report.Findings = append(report.Findings, ReconcileFinding{Kind: "dir_without_container", Service: name, Detail: detail, Actions: []string{"cleanup"}})
+			continue
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, "inspecting "+name+": "+err.Error())
+			continue
+		}
+		if finding, ok := checkServicePort(name, containerInfo); ok {
+			report.Findings = append(report.Findings, finding)
+		}
+		if finding, ok := checkStatusFifo(name, containerInfo.ID); ok {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	var containers []types.Container
+	err = retryDocker(ctx, "docker_list", "", func() error {
+		var err error
+		containers, err = cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filters.NewArgs(filters.Arg("label", managedLabel+"=true"))})
+		return err
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, "listing managed containers: "+err.Error())
+	}
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(c.Names[0], "/")
+		if isSubscribed(name) {
+			continue
+		}
+		detail := "managed container in state " + c.State + " is not subscribed"
+		if !dirs[name] {
+			detail += " and has no service dir"
+		}
+		actions := []string{"cleanup"}
+		if c.State == "running" {
+			actions = []string{"adopt", "cleanup"}
+		}
+		report.Findings = append(report.Findings, ReconcileFinding{Kind: "container_without_service", Service: name, ContainerId: c.ID, Detail: detail, Actions: actions})
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Service < report.Findings[j].Service
+	})
+	return report
+}
+
+// Host port docker publishes ff_daemon on, "" when the container is not running
+func dockerDaemonPort(containerInfo types.ContainerJSON) string {
+	if containerInfo.State == nil || !containerInfo.State.Running {
+		return ""
+	}
+	_, hostPort := daemonHostPort(containerInfo)
+	return hostPort
+}
+
+func checkServicePort(name string, containerInfo types.ContainerJSON) (ReconcileFinding, bool) {
+	filePort, err := readServicePort("services/" + name + "/port")
+	if err != nil {
+		filePort = ""
+	}
+	service, subscribed := getService(name)
+	dockerPort := dockerDaemonPort(containerInfo)
+	var mismatches []string
+	if subscribed && filePort != service.DaemonPort {
+		mismatches = append(mismatches, "port file "+filePort+" but registry "+service.DaemonPort)
+	}
+	if dockerPort != "" && filePort != dockerPort {
+		mismatches = append(mismatches, "port file "+filePort+" but docker publishes "+dockerPort)
+	}
+	if subscribed && dockerPort != "" && service.DaemonPort != dockerPort {
+		mismatches = append(mismatches, "registry "+service.DaemonPort+" but docker publishes "+dockerPort)
+	}
+	if len(mismatches) == 0 {
+		return ReconcileFinding{}, false
+	}
+	finding := ReconcileFinding{Kind: "port_mismatch", Service: name, ContainerId: containerInfo.ID, Detail: strings.Join(mismatches, "; "), Actions: []string{}}
+	if dockerPort != "" {
+		finding.Actions = []string{"adopt"}
+	}
+	return finding, true
+}
+
+func checkStatusFifo(name string, containerId string) (ReconcileFinding, bool) {
+	commsPath := "services/" + name + "/comms"
+	statusPath := commsPath + "/status"
+	finding := ReconcileFinding{Kind: "stale_fifo", Service: name, ContainerId: containerId, Actions: []string{"cleanup"}}
+	if target, err := os.Readlink(commsPath); err == nil {
+		if _, err := os.Stat(target); err != nil {
+			finding.Detail = "comms links to missing " + target
+			return finding, true
+		}
+	}
+	info, err := os.Stat(statusPath)
+	if os.IsNotExist(err) {
+		finding.Detail = "status file missing"
+		return finding, true
+	}
+	if err != nil {
+		finding.Detail = "status file unreadable: " + err.Error()
+		return finding, true
+	}
+	if !info.Mode().IsRegular() && info.Mode()&os.ModeNamedPipe == 0 {
+		finding.Detail = "status is a " + info.Mode().Type().String() + ", not a pipe"
+		return finding, true
+	}
+	return ReconcileFinding{}, false
+}
+
+func applyReconcileAction(finding ReconcileFinding, action string) error {
+	switch finding.Kind + "/" + action {
+	case "dir_without_container/cleanup":
+		if isSubscribed(finding.Service) {
+			return serviceUnsubscribe(finding.Service)
+		}
+		return deleteServiceDir(finding.Service)
+	case "container_without_service/adopt":
+		_, err := subscribeContainer(finding.ContainerId)
+		return err
+	case "container_without_service/cleanup":
+		if err := stopContainer(finding.ContainerId); err != nil {
+			return err
+		}
+		if err := removeContainer(finding.ContainerId); err != nil {
+			return err
+		}
+		return deleteServiceDir(finding.Service)
+	case "port_mismatch/adopt":
+		containerInfo, err := getContainerInfo(finding.ContainerId)
+		if err != nil {
+			return err
+		}
+		port := dockerDaemonPort(containerInfo)
+		if port == "" {
+			return errors.New("docker publishes no ff_daemon port")
+		}
+		if err := os.WriteFile("services/"+finding.Service+"/port", []byte(port), 0666); err != nil {
+			return err
+		}
+		mu.Lock()
+		if entry, ok := services[finding.Service]; ok {
+			entry.DaemonPort = port
+			services[finding.Service] = entry
+		}
+		mu.Unlock()
+		return nil
+	case "stale_fifo/cleanup":
+		commsPath := "services/" + finding.Service + "/comms"
+		// A link from setupComms is only replaced when it dangles, through a valid one the status file
+		// is re-created inside the target the container sees
+		if info, err := os.Lstat(commsPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(commsPath); err != nil {
+				if err := os.Remove(commsPath); err != nil {
+					return err
+				}
+				return createServiceDir(finding.Service)
+			}
+		}
+		if err := os.RemoveAll(commsPath + "/status"); err != nil {
+			return err
+		}
+		return createServiceDir(finding.Service)
+	}
+	return errors.New("action " + action + " does not apply to " + finding.Kind)
+}
+
+func getReconcileHandler(c *gin.Context) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	c.IndentedJSON(http.StatusOK, reconcileReport())
+}
+
+func postReconcileHandler(c *gin.Context) {
+	var body ReconcileBody
+	if err := c.BindJSON(&body); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if body.Action != "cleanup" && body.Action != "adopt" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "action must be cleanup or adopt"})
+		return
+	}
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	results := []ReconcileResult{}
+	for _, finding := range reconcileReport().Findings {
+		if (body.Kind != "" && finding.Kind != body.Kind) || (body.Service != "" && finding.Service != body.Service) {
+			continue
+		}
+		result := ReconcileResult{Finding: finding, Action: body.Action, Result: "skipped"}
+		if contains(finding.Actions, body.Action) {
+			if err := applyReconcileAction(finding, body.Action); err != nil {
+				result.Result = "failed"
+				result.Error = err.Error()
+				logger.Error("Reconcile action failed", zap.String("containerName", finding.Service), zap.String("kind", finding.Kind), zap.String("action", body.Action), zap.Error(err))
+			} else {
+				result.Result = "done"
+				logger.Info("Reconcile action applied", zap.String("containerName", finding.Service), zap.String("kind", finding.Kind), zap.String("action", body.Action))
+			}
+		}
+		results = append(results, result)
+	}
+	c.IndentedJSON(http.StatusOK, results)
+}
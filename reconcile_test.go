@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/gin-gonic/gin"
+)
+
+// Inspect result of a running container publishing daemonPort (when not "") for 7878/tcp
+func inspectPublishing(daemonPort string, networkMode string) types.ContainerJSON {
+	ports := nat.PortMap{}
+	if daemonPort != "" {
+		ports["7878/tcp"] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: daemonPort}}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         "c1",
+			State:      &types.ContainerState{Status: "running", Running: true},
+			HostConfig: &container.HostConfig{NetworkMode: container.NetworkMode(networkMode)},
+		},
+		Config:          &container.Config{Labels: map[string]string{}},
+		NetworkSettings: &types.NetworkSettings{NetworkSettingsBase: types.NetworkSettingsBase{Ports: ports}},
+	}
+}
+
+func TestCheckServicePort(t *testing.T) {
+	const name = "reconcile-port"
+	tests := []struct {
+		name         string
+		filePort     string
+		registry     string
+		dockerPort   string
+		networkMode  string
+		wantDetail   string
+		wantActions  []string
+		wantFinding  bool
+		notSubscribe bool
+	}{
+		{name: "consistent", filePort: "7900", registry: "7900", dockerPort: "7900", networkMode: "bridge"},
+		{name: "unsubscribed and consistent", filePort: "7900", dockerPort: "7900", networkMode: "bridge", notSubscribe: true},
+		{name: "host network uses the container port", filePort: "7878", registry: "7878", networkMode: "host"},
+		{name: "file differs from registry", filePort: "7901", registry: "7900", networkMode: "bridge",
+			wantFinding: true, wantDetail: "port file 7901 but registry 7900", wantActions: []string{}},
+		{name: "docker publishes another port", filePort: "7900", registry: "7900", dockerPort: "7905", networkMode: "bridge",
+			wantFinding: true, wantDetail: "port file 7900 but docker publishes 7905; registry 7900 but docker publishes 7905", wantActions: []string{"adopt"}},
+		{name: "missing port file", registry: "7900", dockerPort: "7900", networkMode: "bridge",
+			wantFinding: true, wantDetail: "port file  but registry 7900; port file  but docker publishes 7900", wantActions: []string{"adopt"}},
+		{name: "unsubscribed with another port", filePort: "7900", dockerPort: "7905", networkMode: "bridge", notSubscribe: true,
+			wantFinding: true, wantDetail: "port file 7900 but docker publishes 7905", wantActions: []string{"adopt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := "services/" + name
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if tt.filePort != "" {
+				if err := os.WriteFile(dir+"/port", []byte(tt.filePort), 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !tt.notSubscribe {
+				mu.Lock()
+				services[name] = Service{ContainerName: name, DaemonPort: tt.registry}
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					delete(services, name)
+					mu.Unlock()
+				}()
+			}
+
+			finding, found := checkServicePort(name, inspectPublishing(tt.dockerPort, tt.networkMode))
+			if found != tt.wantFinding {
+				t.Fatalf("found %v (%+v), want %v", found, finding, tt.wantFinding)
+			}
+			if !found {
+				return
+			}
+			if finding.Kind != "port_mismatch" || finding.Service != name || finding.ContainerId != "c1" {
+				t.Errorf("finding %+v", finding)
+			}
+			if finding.Detail != tt.wantDetail {
+				t.Errorf("detail %q, want %q", finding.Detail, tt.wantDetail)
+			}
+			if !reflect.DeepEqual(finding.Actions, tt.wantActions) {
+				t.Errorf("actions %v, want %v", finding.Actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func reconcileRequest(t *testing.T, r *gin.Engine, body string) (int, []ReconcileResult) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/cm_controller/v1/reconcile", strings.NewReader(body)))
+	var results []ReconcileResult
+	json.Unmarshal(w.Body.Bytes(), &results)
+	return w.Code, results
+}
+
+func TestReconcileHandlers(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.GET("/cm_controller/v1/reconcile", getReconcileHandler)
+	r.POST("/cm_controller/v1/reconcile", postReconcileHandler)
+	createServiceDir("reconcile-gone")
+	for _, args := range [][]string{
+		{"--name", "reconcile-orphan", "-p", "17921:7878", "--label", managedLabel + "=true"},
+		{"--name", "reconcile-exited", "-p", "17922:7878", "--label", managedLabel + "=true"},
+		{"--name", "reconcile-unmanaged", "-p", "17923:7878"},
+	} {
+		if _, err := sim.run(append(append([]string{"docker", "run", "-d"}, args...), "img")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sim.ContainerStop(context.Background(), "reconcile-exited", container.StopOptions{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/reconcile", nil))
+	var report ReconcileReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	findings := map[string]ReconcileFinding{}
+	for _, finding := range report.Findings {
+		if strings.HasPrefix(finding.Service, "reconcile-") {
+			findings[finding.Service] = finding
+		}
+	}
+	wantFindings := map[string]ReconcileFinding{
+		"reconcile-gone":   {Kind: "dir_without_container", Service: "reconcile-gone", Detail: "no container reconcile-gone", Actions: []string{"cleanup"}},
+		"reconcile-orphan": {Kind: "container_without_service", Service: "reconcile-orphan", Detail: "managed container in state running is not subscribed and has no service dir", Actions: []string{"adopt", "cleanup"}},
+		"reconcile-exited": {Kind: "container_without_service", Service: "reconcile-exited", Detail: "managed container in state exited is not subscribed and has no service dir", Actions: []string{"cleanup"}},
+	}
+	if w.Code != http.StatusOK || len(findings) != len(wantFindings) {
+		t.Fatalf("report %d %+v", w.Code, report)
+	}
+	for name, want := range wantFindings {
+		got := findings[name]
+		got.ContainerId = ""
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: finding %+v, want %+v", name, got, want)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantResults []string
+	}{
+		{"unknown action", `{"action":"delete"}`, http.StatusBadRequest, nil},
+		{"action not offered", `{"action":"adopt","service":"reconcile-exited"}`, http.StatusOK, []string{"skipped"}},
+		{"adopt", `{"action":"adopt","kind":"container_without_service","service":"reconcile-orphan"}`, http.StatusOK, []string{"done"}},
+		{"cleanup exited container", `{"action":"cleanup","service":"reconcile-exited"}`, http.StatusOK, []string{"done"}},
+		{"cleanup dir", `{"action":"cleanup","kind":"dir_without_container","service":"reconcile-gone"}`, http.StatusOK, []string{"done"}},
+		{"nothing left", `{"action":"cleanup","service":"reconcile-gone"}`, http.StatusOK, []string{}},
+	}
+	for _, tt := range tests {
+		code, results := reconcileRequest(t, r, tt.body)
+		if code != tt.wantCode || len(results) != len(tt.wantResults) {
+			t.Errorf("%s: code %d %+v, want %d %v", tt.name, code, results, tt.wantCode, tt.wantResults)
+			continue
+		}
+		for i, result := range results {
+			if result.Result != tt.wantResults[i] {
+				t.Errorf("%s: result %+v, want %s", tt.name, result, tt.wantResults[i])
+			}
+		}
+	}
+
+	if !isSubscribed("reconcile-orphan") {
+		t.Error("reconcile-orphan not adopted")
+	}
+	if _, err := os.Stat("services/reconcile-gone"); !os.IsNotExist(err) {
+		t.Errorf("reconcile-gone dir left: %v", err)
+	}
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if _, ok := sim.containers["reconcile-exited"]; ok {
+		t.Error("reconcile-exited container left")
+	}
+	if _, ok := sim.containers["reconcile-unmanaged"]; !ok {
+		t.Error("unmanaged container removed")
+	}
+}
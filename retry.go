@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"go.uber.org/zap"
+)
+
+// How many times a transient failure is retried, waiting Backoff and doubling up to MaxBackoff
+type RetryPolicy struct {
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+var ffRetry = RetryPolicy{
+	Retries:    envInt("FF_RETRY_ATTEMPTS", 5),
+	Backoff:    envDuration("FF_RETRY_BACKOFF", 200*time.Millisecond),
+	MaxBackoff: envDuration("FF_RETRY_MAX_BACKOFF", 5*time.Second),
+}
+
+var dockerRetry = RetryPolicy{
+	Retries:    envInt("DOCKER_RETRY_ATTEMPTS", 3),
+	Backoff:    envDuration("DOCKER_RETRY_BACKOFF", 500*time.Millisecond),
+	MaxBackoff: envDuration("DOCKER_RETRY_MAX_BACKOFF", 5*time.Second),
+}
+
+// Retry counters of one operation (ff_run, ff_checkpoint, docker_stop...)
+type RetryMetrics struct {
+	Calls     uint64 `json:"calls"`
+	Retries   uint64 `json:"retries"`
+	Recovered uint64 `json:"recovered"`
+	Exhausted uint64 `json:"exhausted"`
+}
+
+var retryMetrics = make(map[string]*RetryMetrics)
+var retryMetricsMu sync.Mutex
+
+// Call fn until it succeeds, fails for good or the retries run out; fn tells whether its error is worth another try.
+// Returns the number of retries made along with fn's last error.
+func (p RetryPolicy) do(ctx context.Context, operation string, containerName string, fn func() (bool, error)) (int, error) {
+	backoff := p.Backoff
+	retries := 0
+	for {
+		retryable, err := fn()
+		if err == nil || !retryable || retries >= p.Retries {
+			recordRetries(operation, retries, err == nil, err != nil && retryable)
+			return retries, err
+		}
+		// Up to 20% jitter so services failing together do not retry in lockstep
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+		logger.Warn("Transient error, retrying", zap.String("containerName", containerName), zap.String("operation", operation), zap.Int("retry", retries+1), zap.Duration("backoff", wait), zap.Error(err))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			recordRetries(operation, retries, false, false)
+			return retries, err
+		case <-timer.C:
+		}
+		retries++
+		backoff *= 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+func recordRetries(operation string, retries int, succeeded bool, exhausted bool) {
+	retryMetricsMu.Lock()
+	defer retryMetricsMu.Unlock()
+	m, ok := retryMetrics[operation]
+	if !ok {
+		m = &RetryMetrics{}
+		retryMetrics[operation] = m
+	}
+	m.Calls++
+	m.Retries += uint64(retries)
+	if succeeded && retries > 0 {
+		m.Recovered++
+	}
+	if exhausted {
+		m.Exhausted++
+	}
+}
+
+func getRetryMetrics() map[string]RetryMetrics {
+	retryMetricsMu.Lock()
+	defer retryMetricsMu.Unlock()
+	metrics := make(map[string]RetryMetrics, len(retryMetrics))
+	for operation, m := range retryMetrics {
+		metrics[operation] = *m
+	}
+	return metrics
+}
+
+// The connection was never established, so the request cannot have reached the other side
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// The connection broke, the request may or may not have been handled
+func isConnectionError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Docker answered with a 5xx or could not be reached
+func isTransientDockerError(err error) bool {
+	return errdefs.IsSystem(err) || errdefs.IsUnavailable(err) || client.IsErrConnectionFailed(err)
+}
+
+// Run a docker API call with dockerRetry, retrying only transient errors
+func retryDocker(ctx context.Context, operation string, containerName string, fn func() error) error {
+	_, err := dockerRetry.do(ctx, operation, containerName, func() (bool, error) {
+		err := fn()
+		return isTransientDockerError(err), err
+	})
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	type attempt struct {
+		retryable bool
+		err       error
+	}
+	tests := []struct {
+		name        string
+		attempts    []attempt
+		cancel      bool
+		wantCalls   int
+		wantRetries int
+		wantErr     error
+		want        RetryMetrics
+	}{
+		{"first try", []attempt{{false, nil}}, false, 1, 0, nil, RetryMetrics{Calls: 1}},
+		{"recovered", []attempt{{true, errTransient}, {true, errTransient}, {false, nil}}, false, 3, 2, nil, RetryMetrics{Calls: 1, Retries: 2, Recovered: 1}},
+		{"exhausted", []attempt{{true, errTransient}, {true, errTransient}, {true, errTransient}, {true, errTransient}}, false, 4, 3, errTransient, RetryMetrics{Calls: 1, Retries: 3, Exhausted: 1}},
+		{"not retryable", []attempt{{true, errTransient}, {false, errPermanent}}, false, 2, 1, errPermanent, RetryMetrics{Calls: 1, Retries: 1}},
+		{"canceled while waiting", []attempt{{true, errTransient}}, true, 1, 0, errTransient, RetryMetrics{Calls: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := RetryPolicy{Retries: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			operation := "test_" + tt.name
+			retryMetricsMu.Lock()
+			delete(retryMetrics, operation)
+			retryMetricsMu.Unlock()
+			calls := 0
+			retries, err := policy.do(ctx, operation, "svc", func() (bool, error) {
+				a := tt.attempts[calls]
+				calls++
+				if tt.cancel {
+					cancel()
+				}
+				return a.retryable, a.err
+			})
+			if calls != tt.wantCalls || retries != tt.wantRetries || err != tt.wantErr {
+				t.Errorf("calls %d, retries %d, err %v, want %d, %d, %v", calls, retries, err, tt.wantCalls, tt.wantRetries, tt.wantErr)
+			}
+			if got := getRetryMetrics()[operation]; got != tt.want {
+				t.Errorf("metrics %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Secrets are kept AES-256-GCM encrypted in SECRETS_FILE, with the key in SECRETS_KEY_FILE
+// (64 hex characters, created on first start when missing). The key defaults to the user's config
+// dir rather than the working directory, so a copy of the encrypted secrets doesn't take the key along.
+var secretsPath = envString("SECRETS_FILE", "secrets.json")
+var secretsKeyPath = envString("SECRETS_KEY_FILE", defaultSecretsKeyPath())
+
+// Start, run and checkpoint bodies reference a secret by name, e.g. "AWS_SECRET_ACCESS_KEY={{secret:s3}}".
+// In a field ending in _file (passphrase_file) the reference is replaced by the path of a file holding
+// the secret, written to the service's comms dir for the ff_daemon call and removed once it is done.
+var secretRefPattern = regexp.MustCompile(`\{\{secret:([^{}]*)\}\}`)
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Shorter values are not redacted, they would hide too much of the logs
+const minRedactedLength = 4
+
+type storedSecret struct {
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Created    time.Time `json:"created"`
+	Updated    time.Time `json:"updated"`
+}
+
+// A secret as listed by the API, its value is never returned
+type SecretInfo struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+type SecretBody struct {
+	Value string `json:"value"`
+}
+
+var secrets = make(map[string]storedSecret)
+
+// Decrypted values, used to resolve references
+var secretValues = make(map[string]string)
+
+// Every value seen since the controller started, replaced and deleted ones stay redacted as
+// containers may still hold them
+var redactedValues = make(map[string]bool)
+var secretsMu sync.RWMutex
+var secretsCipher cipher.AEAD
+
+var errUnknownSecret = errors.New("unknown secret")
+
+// $XDG_CONFIG_HOME/cm_controller/secrets.key (~/.config on linux), "" without a config dir
+func defaultSecretsKeyPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "cm_controller", "secrets.key")
+}
+
+func loadSecretsKey() (cipher.AEAD, error) {
+	if secretsKeyPath == "" {
+		return nil, errors.New("SECRETS_KEY_FILE is required, there is no user config dir to keep the key in")
+	}
+	keyDir, _ := filepath.Abs(filepath.Dir(secretsKeyPath))
+	if secretsDir, _ := filepath.Abs(filepath.Dir(secretsPath)); keyDir == secretsDir {
+		logger.Warn("The secrets key is kept next to the encrypted secrets, set SECRETS_KEY_FILE to another dir", zap.String("path", secretsKeyPath))
+	}
+	data, err := os.ReadFile(secretsKeyPath)
+	if os.IsNotExist(err) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		data = []byte(hex.EncodeToString(key))
+		if err := os.MkdirAll(keyDir, 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(secretsKeyPath, data, 0400); err != nil {
+			return nil, err
+		}
+		logger.Info("Created secrets key", zap.String("path", secretsKeyPath))
+	} else if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New(secretsKeyPath + " must hold a 256 bit key as 64 hex characters")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func loadSecrets() {
+	aead, err := loadSecretsKey()
+	if err != nil {
+		logger.Error("Error loading secrets key, secrets are disabled", zap.Error(err))
+		return
+	}
+	// A store that cannot be read leaves secrets disabled rather than overwritten by the next PUT
+	var saved map[string]storedSecret
+	data, err := os.ReadFile(secretsPath)
+	if err == nil {
+		err = json.Unmarshal(data, &saved)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		logger.Error("Error reading secrets, secrets are disabled", zap.Error(err))
+		return
+	}
+	// Nothing is logged while secretsMu is held, the logger takes it to redact
+	var undecrypted []string
+	secretsMu.Lock()
+	secretsCipher = aead
+	for name, secret := range saved {
+		// The name is authenticated with the value, so an entry cannot be moved to another name
+		value, err := aead.Open(nil, secret.Nonce, secret.Ciphertext, []byte(name))
+		if err != nil {
+			undecrypted = append(undecrypted, name)
+			continue
+		}
+		secrets[name] = secret
+		secretValues[name] = string(value)
+		redactedValues[string(value)] = true
+	}
+	count := len(secrets)
+	secretsMu.Unlock()
+	for _, name := range undecrypted {
+		logger.Error("Error decrypting secret, is it from another key?", zap.String("secret", name))
+	}
+	logger.Info("Secrets loaded", zap.Int("count", count))
+}
+
+// Callers hold secretsMu
+func saveSecrets() error {
+	return writeJSONFile(secretsPath, secrets)
+}
+
+func putSecret(name string, value string) error {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if secretsCipher == nil {
+		return errors.New("secrets are disabled, the key could not be loaded")
+	}
+	nonce := make([]byte, secretsCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	now := time.Now()
+	previous, existed := secrets[name]
+	secret := storedSecret{Nonce: nonce, Ciphertext: secretsCipher.Seal(nil, nonce, []byte(value), []byte(name)), Created: now, Updated: now}
+	if existed {
+		secret.Created = previous.Created
+	}
+	secrets[name] = secret
+	if err := saveSecrets(); err != nil {
+		if existed {
+			secrets[name] = previous
+		} else {
+			delete(secrets, name)
+		}
+		return err
+	}
+	secretValues[name] = value
+	redactedValues[value] = true
+	return nil
+}
+
+func deleteSecret(name string) error {
+	secretsMu.Lock()
+	secret, ok := secrets[name]
+	if !ok {
+		secretsMu.Unlock()
+		return errUnknownSecret
+	}
+	delete(secrets, name)
+	if err := saveSecrets(); err != nil {
+		secrets[name] = secret
+		secretsMu.Unlock()
+		return err
+	}
+	delete(secretValues, name)
+	secretsMu.Unlock()
+	files, _ := filepath.Glob("services/*/comms/secrets/" + name)
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			logger.Error("Error removing secret file", zap.String("path", file), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func secretValue(name string) (string, error) {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	value, ok := secretValues[name]
+	if !ok {
+		return "", errors.New("unknown secret " + name)
+	}
+	return value, nil
+}
+
+// Replace the secret references in s by the values
+func resolveSecretString(s string) (string, error) {
+	var err error
+	resolved := secretRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		value, e := secretValue(secretRefPattern.FindStringSubmatch(ref)[1])
+		if e != nil && err == nil {
+			err = e
+		}
+		return value
+	})
+	return resolved, err
+}
+
+// Envs holding a secret reach docker run through an env file, one variable per line, so the values
+// can't span several lines
+func resolveEnvSecrets(envs []string) ([]string, error) {
+	resolved := make([]string, len(envs))
+	for i, env := range envs {
+		var err error
+		if resolved[i], err = resolveSecretString(env); err != nil {
+			return nil, err
+		}
+		if resolved[i] != env && strings.ContainsAny(resolved[i], "\r\n") {
+			key, _, _ := strings.Cut(env, "=")
+			return nil, errors.New("env " + key + ": secret values spanning several lines can't be passed in an env")
+		}
+	}
+	return resolved, nil
+}
+
+// Resolve envs into a 0600 file for docker run --env-file, the caller removes it once the container
+// is created
+func writeSecretEnvFile(envs []string) (string, error) {
+	resolved, err := resolveEnvSecrets(envs)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "cm_controller-env-")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(strings.Join(resolved, "\n") + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Write a secret to services/<name>/comms/secrets/<secret> and return its path inside the container,
+// the file written is added to files
+func mountSecretFile(containerName string, name string, files *[]string) (string, error) {
+	value, err := secretValue(name)
+	if err != nil {
+		return "", err
+	}
+	if service, ok := getService(containerName); ok && service.Comms == "none" {
+		return "", errors.New("secret files need the service dir mounted at " + commsMountTarget)
+	}
+	dir := "services/" + containerName + "/comms/secrets"
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := dir + "/" + name
+	os.Remove(path)
+	if err := os.WriteFile(path, []byte(value), 0400); err != nil {
+		return "", err
+	}
+	*files = append(*files, path)
+	return commsMountTarget + "/comms/secrets/" + name, nil
+}
+
+func resolveSecretValue(containerName string, key string, value interface{}, files *[]string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			resolved, err := resolveSecretValue(containerName, k, item, files)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = resolved
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			resolved, err := resolveSecretValue(containerName, key, item, files)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	case string:
+		if match := secretRefPattern.FindStringSubmatch(v); match != nil && match[0] == v && strings.HasSuffix(key, "_file") {
+			return mountSecretFile(containerName, match[1], files)
+		}
+		return resolveSecretString(v)
+	default:
+		return v, nil
+	}
+}
+
+// Resolve the secret references of a run/checkpoint body for containerName, along with the secret
+// files written for it, which the caller removes with removeSecretFiles once ff_daemon answered
+func resolveSecretRefs(containerName string, body []byte) ([]byte, []string, error) {
+	if !secretRefPattern.Match(body) {
+		return body, nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, nil, err
+	}
+	var files []string
+	resolved, err := resolveSecretValue(containerName, "", value, &files)
+	if err == nil {
+		body, err = json.Marshal(resolved)
+	}
+	if err != nil {
+		removeSecretFiles(files)
+		return nil, nil, err
+	}
+	return body, files, nil
+}
+
+// Secret files are plain text, they are not left in the service dir after the call that needed them
+func removeSecretFiles(files []string) {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			logger.Error("Error removing secret file", zap.String("path", file), zap.Error(err))
+		}
+	}
+}
+
+// Check that every secret referenced in body exists, without resolving anything
+func checkSecretRefs(body []byte) error {
+	for _, match := range secretRefPattern.FindAllSubmatch(body, -1) {
+		if _, err := secretValue(string(match[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replace every secret value found in s
+func redactSecrets(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for value := range redactedValues {
+		if len(value) >= minRedactedLength {
+			s = strings.ReplaceAll(s, value, redacted)
+		}
+	}
+	return s
+}
+
+// Whether value is a secret value, however short
+func isSecretValue(value string) bool {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return redactedValues[value]
+}
+
+func hasSecrets() bool {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return len(redactedValues) > 0
+}
+
+// Core wrapper redacting secret values from messages and string, byte string and error fields
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if hasSecrets() {
+		entry.Message = redactSecrets(entry.Message)
+		fields = redactFields(fields)
+	}
+	return c.Core.Write(entry, fields)
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	if !hasSecrets() {
+		return fields
+	}
+	redactedFields := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = redactSecrets(field.String)
+		case zapcore.ByteStringType:
+			if b, ok := field.Interface.([]byte); ok {
+				field.Interface = []byte(redactSecrets(string(b)))
+			}
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zap.String(field.Key, redactSecrets(err.Error()))
+			}
+		}
+		redactedFields[i] = field
+	}
+	return redactedFields
+}
+
+func listSecretsHandler(c *gin.Context) {
+	secretsMu.RLock()
+	list := []SecretInfo{}
+	for name, secret := range secrets {
+		list = append(list, SecretInfo{Name: name, Created: secret.Created, Updated: secret.Updated})
+	}
+	secretsMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	c.IndentedJSON(http.StatusOK, list)
+}
+
+func putSecretHandler(c *gin.Context) {
+	name := c.Param("name")
+	if !secretNamePattern.MatchString(name) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid secret name, use letters, digits, '.', '_' and '-'"})
+		return
+	}
+	var body SecretBody
+	if err := c.BindJSON(&body); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if body.Value == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "value is required"})
+		return
+	}
+	if err := putSecret(name, body.Value); err != nil {
+		logger.Error("Error saving secret", zap.String("secret", name), zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the secret:" + err.Error()})
+		return
+	}
+	logger.Info("Secret saved", zap.String("secret", name))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Secret " + name + " saved"})
+}
+
+func deleteSecretHandler(c *gin.Context) {
+	name := c.Param("name")
+	err := deleteSecret(name)
+	if errors.Is(err, errUnknownSecret) {
+		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no secret " + name + " found!"})
+		return
+	}
+	if err != nil {
+		logger.Error("Error deleting secret", zap.String("secret", name), zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the secret"})
+		return
+	}
+	logger.Info("Secret deleted", zap.String("secret", name))
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Secret " + name + " deleted"})
+}
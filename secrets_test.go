@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Replace the values redacted from logs for the duration of a test
+func withRedactedValues(t *testing.T, values ...string) {
+	t.Helper()
+	secretsMu.Lock()
+	previous := redactedValues
+	redactedValues = make(map[string]bool)
+	for _, value := range values {
+		redactedValues[value] = true
+	}
+	secretsMu.Unlock()
+	t.Cleanup(func() {
+		secretsMu.Lock()
+		redactedValues = previous
+		secretsMu.Unlock()
+	})
+}
+
+// Empty secret store and key in the test's temp dir, dropped when it ends
+func useSecrets(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previousPath, previousKeyPath := secretsPath, secretsKeyPath
+	secretsPath = filepath.Join(dir, "secrets.json")
+	secretsKeyPath = filepath.Join(dir, "key", "secrets.key")
+	withRedactedValues(t)
+	resetSecrets := func() {
+		secretsMu.Lock()
+		secrets = make(map[string]storedSecret)
+		secretValues = make(map[string]string)
+		secretsCipher = nil
+		secretsMu.Unlock()
+	}
+	resetSecrets()
+	t.Cleanup(func() {
+		resetSecrets()
+		secretsPath, secretsKeyPath = previousPath, previousKeyPath
+	})
+	return dir
+}
+
+func TestDefaultSecretsKeyPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/etc/xdg-test")
+	if got := defaultSecretsKeyPath(); got != "/etc/xdg-test/cm_controller/secrets.key" {
+		t.Errorf("defaultSecretsKeyPath() = %s", got)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "")
+	if got := defaultSecretsKeyPath(); got != "" {
+		t.Errorf("defaultSecretsKeyPath() without a config dir = %s, want none", got)
+	}
+}
+
+func TestLoadSecretsKey(t *testing.T) {
+	dir := useSecrets(t)
+	if _, err := loadSecretsKey(); err != nil {
+		t.Fatal(err)
+	}
+	// Created owner-only, in its own dir, and used as it is from then on
+	keyInfo, err := os.Stat(secretsKeyPath)
+	if err != nil || keyInfo.Mode().Perm() != 0400 {
+		t.Fatalf("key file %v: %v", keyInfo, err)
+	}
+	if dirInfo, _ := os.Stat(filepath.Dir(secretsKeyPath)); dirInfo.Mode().Perm() != 0700 {
+		t.Errorf("key dir mode %o", dirInfo.Mode().Perm())
+	}
+	key, _ := os.ReadFile(secretsKeyPath)
+	if _, err := loadSecretsKey(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(secretsKeyPath); string(again) != string(key) {
+		t.Error("key replaced")
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		wantErr string
+	}{
+		{"no key path", "", "", "SECRETS_KEY_FILE is required"},
+		{"not hex", filepath.Join(dir, "bad.key"), "not a key", "64 hex characters"},
+		{"too short", filepath.Join(dir, "short.key"), "abcd", "64 hex characters"},
+	}
+	for _, tt := range tests {
+		secretsKeyPath = tt.path
+		if tt.content != "" {
+			os.WriteFile(tt.path, []byte(tt.content), 0600)
+		}
+		if _, err := loadSecretsKey(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSecretsStore(t *testing.T) {
+	useSecrets(t)
+	loadSecrets()
+	if err := putSecret("s3", "hunter22"); err != nil {
+		t.Fatal(err)
+	}
+	if err := putSecret("pin", "123"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(secretsPath); strings.Contains(string(data), "hunter22") {
+		t.Error("secret stored in clear")
+	}
+	// Reloaded from the file with the same key
+	secretsMu.Lock()
+	secrets = make(map[string]storedSecret)
+	secretValues = make(map[string]string)
+	secretsMu.Unlock()
+	loadSecrets()
+	if value, err := secretValue("s3"); err != nil || value != "hunter22" {
+		t.Fatalf("secretValue() = %q, %v", value, err)
+	}
+	if err := deleteSecret("pin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteSecret("pin"); !errors.Is(err, errUnknownSecret) {
+		t.Errorf("delete again: %v", err)
+	}
+	// A deleted value stays redacted, containers may still hold it
+	if got := redactEnv("PIN=123"); got != "PIN=[REDACTED]" {
+		t.Errorf("deleted secret %s", got)
+	}
+}
+
+func TestRedactEnv(t *testing.T) {
+	withRedactedValues(t, "hunter22", "abc")
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"DB_PASSWORD=x", "DB_PASSWORD=[REDACTED]"},
+		{"api_key=plain", "api_key=[REDACTED]"},
+		{"PIN=abc", "PIN=[REDACTED]"},
+		{"URL=s3://u:hunter22@b", "URL=s3://u:[REDACTED]@b"},
+		{"MODE=abcd", "MODE=abcd"},
+		{"PATH=/bin", "PATH=/bin"},
+		{"TOKEN=", "TOKEN="},
+		{"NOVALUE", "NOVALUE"},
+	}
+	for _, tt := range tests {
+		if got := redactEnv(tt.env); got != tt.want {
+			t.Errorf("redactEnv(%q) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+	args := []string{"docker", "run", "-e", "AWS_SECRET_ACCESS_KEY=x", "-e", "PATH=/bin", "--name", "PIN=abc", "img"}
+	want := []string{"docker", "run", "-e", "AWS_SECRET_ACCESS_KEY=[REDACTED]", "-e", "PATH=/bin", "--name", "PIN=abc", "img"}
+	if got := redactRunArgs(args); !reflect.DeepEqual(got, want) || args[3] != "AWS_SECRET_ACCESS_KEY=x" {
+		t.Errorf("redactRunArgs() = %v, want %v and the args unchanged", got, want)
+	}
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	useSecrets(t)
+	loadSecrets()
+	putSecret("s3", "hunter22")
+	putSecret("pass", "p4ss")
+	createServiceDir("secret-refs")
+	defer deleteServiceDir("secret-refs")
+
+	body, files, err := resolveSecretRefs("secret-refs", []byte(`{"image_url":"s3://u:{{secret:s3}}@b","passphrase_file":"{{secret:pass}}","app_args":"{{secret:pass}}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resolved map[string]string
+	json.Unmarshal(body, &resolved)
+	want := map[string]string{"image_url": "s3://u:hunter22@b", "passphrase_file": commsMountTarget + "/comms/secrets/pass", "app_args": "p4ss"}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolved %v, want %v", resolved, want)
+	}
+	if len(files) != 1 {
+		t.Fatalf("files %v", files)
+	}
+	if data, err := os.ReadFile(files[0]); err != nil || string(data) != "p4ss" {
+		t.Errorf("secret file %q: %v", data, err)
+	}
+	removeSecretFiles(files)
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("secret file left: %v", err)
+	}
+
+	// Files written before an unknown secret are not left behind
+	_, files, err = resolveSecretRefs("secret-refs", []byte(`{"passphrase_file":"{{secret:pass}}","key_file":"{{secret:missing}}"}`))
+	if err == nil || files != nil {
+		t.Errorf("unknown secret: files %v, err %v", files, err)
+	}
+	if entries, _ := os.ReadDir("services/secret-refs/comms/secrets"); len(entries) != 0 {
+		t.Errorf("secret files left %v", entries)
+	}
+}
+
+func TestSecretsInOperations(t *testing.T) {
+	r, sim := simulatedRouter(t)
+	r.GET("/cm_controller/v1/service/container_info/:name", getContainerInfoHandler)
+	useSecrets(t)
+	loadSecrets()
+	putSecret("pass", "p4ss")
+	putSecret("pin", "123")
+	body := `{"container_name":"secret-svc","image":"img","envs":["DB_PASSWORD=plain","PIN={{secret:pin}}","MODE=fast"]}`
+	if code, response := serve(r, "POST", "/start", body); code != http.StatusOK {
+		t.Fatalf("start: %d %v", code, response)
+	}
+	sim.mu.Lock()
+	env := sim.containers["secret-svc"].Env
+	sim.mu.Unlock()
+	if !reflect.DeepEqual(env, []string{"DB_PASSWORD=plain", "MODE=fast", "PIN=123"}) {
+		t.Errorf("container env %v", env)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/service/container_info/secret-svc", nil))
+	var info struct {
+		Config struct {
+			Env []string
+		}
+	}
+	json.Unmarshal(w.Body.Bytes(), &info)
+	if want := []string{"DB_PASSWORD=[REDACTED]", "MODE=fast", "PIN=[REDACTED]"}; !reflect.DeepEqual(info.Config.Env, want) {
+		t.Errorf("container info env %v, want %v", info.Config.Env, want)
+	}
+
+}
+
+func TestSecretFileRemovedAfterCall(t *testing.T) {
+	useSecrets(t)
+	loadSecrets()
+	putSecret("pass", "p4ss")
+	// ff_daemon reads the passphrase file, seen from the host through the service dir mount
+	var read string
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		data, err := os.ReadFile(strings.Replace(body["passphrase_file"], commsMountTarget, "services/secret-call", 1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		read = string(data)
+		w.Write([]byte("Application restored successfully"))
+	}))
+	defer daemon.Close()
+	createServiceDir("secret-call")
+	mu.Lock()
+	services["secret-call"] = Service{ContainerName: "secret-call", DaemonPort: daemon.URL[strings.LastIndex(daemon.URL, ":")+1:]}
+	mu.Unlock()
+	defer serviceUnsubscribe("secret-call")
+
+	ret, msg, _ := callFastFreeze(context.Background(), 0, []byte(`{"passphrase_file":"{{secret:pass}}"}`), "secret-call")
+	if ret != 0 || read != "p4ss" {
+		t.Fatalf("run: %d %s, read %q", ret, msg, read)
+	}
+	if _, err := os.Stat("services/secret-call/comms/secrets/pass"); !os.IsNotExist(err) {
+		t.Errorf("secret file left after the call: %v", err)
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	withRedactedValues(t, "hunter22", "abc")
+	tests := []struct {
+		name  string
+		field zapcore.Field
+		want  zapcore.Field
+	}{
+		{"string", zap.String("url", "s3://user:hunter22@bucket"), zap.String("url", "s3://user:[REDACTED]@bucket")},
+		{"clean string", zap.String("containerName", "svc"), zap.String("containerName", "svc")},
+		{"short values are kept", zap.String("env", "X=abc"), zap.String("env", "X=abc")},
+		{"byte string", zap.ByteString("body", []byte(`{"token":"hunter22"}`)), zap.ByteString("body", []byte(`{"token":"[REDACTED]"}`))},
+		{"error", zap.Error(errors.New("login with hunter22 refused")), zap.String("error", "login with [REDACTED] refused")},
+		{"other types untouched", zap.Int("port", 7878), zap.Int("port", 7878)},
+	}
+	for _, tt := range tests {
+		got := redactFields([]zapcore.Field{tt.field})
+		if !reflect.DeepEqual(got[0], tt.want) {
+			t.Errorf("%s: redactFields() = %+v, want %+v", tt.name, got[0], tt.want)
+		}
+	}
+}
+
+func TestRedactFieldsWithoutSecrets(t *testing.T) {
+	withRedactedValues(t)
+	fields := []zapcore.Field{zap.String("password", "hunter22")}
+	if got := redactFields(fields); !reflect.DeepEqual(got, fields) {
+		t.Errorf("redactFields() = %+v, want the fields unchanged", got)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// In-process ff_daemon of a simulated container, served on the container's host daemon port
+type simDaemon struct {
+	sim       *simDockerClient
+	container *simContainer
+	server    *http.Server
+	listener  net.Listener
+	mu        sync.Mutex
+	status    byte
+}
+
+func startSimDaemon(sim *simDockerClient, c *simContainer) (*simDaemon, error) {
+	listener, err := net.Listen("tcp", ":"+c.DaemonPort)
+	if err != nil {
+		return nil, err
+	}
+	daemon := &simDaemon{sim: sim, container: c, listener: listener}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", daemon.upHandler)
+	mux.HandleFunc("/run", daemon.runHandler)
+	mux.HandleFunc("/checkpoint", daemon.checkpointHandler)
+	daemon.server = &http.Server{Handler: mux}
+	daemon.setStatus('0')
+	sim.log(c, "listening on 0.0.0.0:7878")
+	go func() {
+		if err := daemon.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Simulated ff_daemon stopped", zap.String("containerName", c.Name), zap.Error(err))
+		}
+	}()
+	return daemon, nil
+}
+
+func (d *simDaemon) stop() {
+	d.sim.log(d.container, "terminated")
+	d.server.Close()
+	// Close only reaches the listener once Serve got to it, the port must be free for a restart right away
+	d.listener.Close()
+}
+
+func (d *simDaemon) getStatus() byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// Status byte as the real ff_daemon reports it through comms/status: 0 standby, 1 running, 2 checkpointed
+func (d *simDaemon) setStatus(status byte) {
+	d.mu.Lock()
+	d.status = status
+	d.mu.Unlock()
+	statusPath := "services/" + d.container.Name + "/comms/status"
+	if err := os.WriteFile(statusPath, []byte{status}, 0666); err != nil {
+		logger.Debug("Simulated ff_daemon cannot write status", zap.String("containerName", d.container.Name), zap.Error(err))
+	}
+}
+
+// A paused container does not answer, like a frozen ff_daemon would
+func (d *simDaemon) waitUnpaused(ctx context.Context) error {
+	for {
+		d.sim.mu.Lock()
+		paused := d.container.Status == "paused"
+		d.sim.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		if err := simSleep(ctx, 50); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *simDaemon) upHandler(w http.ResponseWriter, r *http.Request) {
+	if d.waitUnpaused(r.Context()) != nil {
+		return
+	}
+	w.Write([]byte("ff_daemon (simulated)"))
+}
+
+func (d *simDaemon) runHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ImgUrl       string `json:"image_url"`
+		NoRestore    bool   `json:"no_restore"`
+		LeaveStopped bool   `json:"leave_stopped"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid run arguments", http.StatusBadRequest)
+		return
+	}
+	if d.waitUnpaused(r.Context()) != nil {
+		return
+	}
+	if d.getStatus() == '1' {
+		http.Error(w, "Application already running", http.StatusBadRequest)
+		return
+	}
+	d.sim.log(d.container, "run requested (image_url=%s)", body.ImgUrl)
+	if simSleep(r.Context(), d.sim.getConfig().RunLatencyMs) != nil {
+		return
+	}
+	if d.sim.shouldFail("run") {
+		d.sim.log(d.container, "run failed: simulated failure")
+		http.Error(w, "Simulated run failure", http.StatusInternalServerError)
+		return
+	}
+
+	d.sim.mu.Lock()
+	_, restorable := d.sim.checkpoints[body.ImgUrl]
+	d.sim.mu.Unlock()
+	msg := "Application started successfully"
+	if restorable && !body.NoRestore {
+		msg = "Application restored successfully"
+	}
+	if body.LeaveStopped {
+		d.setStatus('0')
+	} else {
+		d.setStatus('1')
+	}
+	d.sim.log(d.container, "%s", msg)
+	w.Write([]byte(msg))
+}
+
+func (d *simDaemon) checkpointHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		LeaveRun bool   `json:"leave_running"`
+		ImgUrl   string `json:"image_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid checkpoint arguments", http.StatusBadRequest)
+		return
+	}
+	if d.waitUnpaused(r.Context()) != nil {
+		return
+	}
+	if d.getStatus() != '1' {
+		http.Error(w, "No application running", http.StatusBadRequest)
+		return
+	}
+	d.sim.log(d.container, "checkpoint requested (image_url=%s)", body.ImgUrl)
+	config := d.sim.getConfig()
+	if simSleep(r.Context(), config.CheckpointLatencyMs) != nil {
+		return
+	}
+	if d.sim.shouldFail("checkpoint") {
+		d.sim.log(d.container, "checkpoint failed: simulated failure")
+		http.Error(w, "Simulated checkpoint failure", http.StatusInternalServerError)
+		return
+	}
+
+	d.sim.mu.Lock()
+	d.sim.checkpoints[body.ImgUrl] = SimCheckpoint{ImageUrl: body.ImgUrl, Service: d.container.Name, Time: time.Now(), Size: config.ImageSize}
+	d.sim.mu.Unlock()
+	if body.LeaveRun {
+		d.setStatus('1')
+	} else {
+		d.setStatus('2')
+	}
+	d.sim.log(d.container, "checkpoint written to %s", body.ImgUrl)
+	w.Write([]byte("Application checkpoint successfully"))
+}
@@ -0,0 +1,794 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	mountTypes "github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+	"github.com/gin-gonic/gin"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+)
+
+// Latencies and failure injection of the simulated docker and ff_daemon
+type SimConfig struct {
+	DockerLatencyMs     int            `json:"docker_latency_ms"`
+	RunLatencyMs        int            `json:"run_latency_ms"`
+	CheckpointLatencyMs int            `json:"checkpoint_latency_ms"`
+	FailureRate         float64        `json:"failure_rate"`
+	FailNext            map[string]int `json:"fail_next"`
+	ImageSize           int64          `json:"image_size"`
+}
+
+// Fake checkpoint image written by a simulated ff_daemon
+type SimCheckpoint struct {
+	ImageUrl string    `json:"image_url"`
+	Service  string    `json:"service"`
+	Time     time.Time `json:"time"`
+	Size     int64     `json:"size"`
+}
+
+type simContainer struct {
+	ID         string
+	Name       string
+	Image      string
+	Created    time.Time
+	Status     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	DaemonPort string
+	Ports      []string
+	Env        []string
+	Labels     map[string]string
+	Hostname   string
+	Network    NetworkConfig
+	Mounts     []types.MountPoint
+	Resources  container.Resources
+	Restart    container.RestartPolicy
+	daemon     *simDaemon
+	logs       bytes.Buffer
+}
+
+// In-process replacement of the docker API client used with --simulate,
+// calls to docker APIs the controller does not use panic on the nil embedded client
+type simDockerClient struct {
+	client.APIClient
+	mu          sync.Mutex
+	config      SimConfig
+	containers  map[string]*simContainer
+	images      map[string]types.ImageSummary
+	checkpoints map[string]SimCheckpoint
+	watchersMu  sync.Mutex
+	watchers    map[chan events.Message]filters.Args
+}
+
+var simDocker *simDockerClient
+
+// Set up the fake docker and ff_daemon, latencies and failure rate come from SIM_* variables
+func initSimulation() {
+	failureRate, _ := strconv.ParseFloat(os.Getenv("SIM_FAILURE_RATE"), 64)
+	simDocker = &simDockerClient{
+		config: SimConfig{
+			DockerLatencyMs:     int(envDuration("SIM_DOCKER_LATENCY", 100*time.Millisecond).Milliseconds()),
+			RunLatencyMs:        int(envDuration("SIM_RUN_LATENCY", time.Second).Milliseconds()),
+			CheckpointLatencyMs: int(envDuration("SIM_CHECKPOINT_LATENCY", 2*time.Second).Milliseconds()),
+			FailureRate:         failureRate,
+			FailNext:            map[string]int{},
+			ImageSize:           64 << 20,
+		},
+		containers:  make(map[string]*simContainer),
+		images:      make(map[string]types.ImageSummary),
+		checkpoints: make(map[string]SimCheckpoint),
+		watchers:    make(map[chan events.Message]filters.Args),
+	}
+	logger.Warn("Running in simulation mode, docker and ff_daemon are simulated")
+}
+
+func (sim *simDockerClient) getConfig() SimConfig {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	config := sim.config
+	config.FailNext = make(map[string]int, len(sim.config.FailNext))
+	for op, n := range sim.config.FailNext {
+		config.FailNext[op] = n
+	}
+	return config
+}
+
+// Whether a simulated op ("docker_run", "pull", "run", "checkpoint") should fail this time
+func (sim *simDockerClient) shouldFail(op string) bool {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.config.FailNext[op] > 0 {
+		sim.config.FailNext[op]--
+		return true
+	}
+	return sim.config.FailureRate > 0 && mrand.Float64() < sim.config.FailureRate
+}
+
+// Sleep for a simulated latency, returning early when ctx is done
+func simSleep(ctx context.Context, ms int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	}
+}
+
+func (sim *simDockerClient) dockerLatency(ctx context.Context) {
+	simSleep(ctx, sim.getConfig().DockerLatencyMs)
+}
+
+func newSimID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Must be called with sim.mu held
+func (sim *simDockerClient) find(nameOrId string) *simContainer {
+	nameOrId = strings.TrimPrefix(nameOrId, "/")
+	if c, ok := sim.containers[nameOrId]; ok {
+		return c
+	}
+	for _, c := range sim.containers {
+		if c.ID == nameOrId || (len(nameOrId) >= 12 && strings.HasPrefix(c.ID, nameOrId)) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (sim *simDockerClient) notFound(nameOrId string) error {
+	return errdefs.NotFound(fmt.Errorf("No such container: %s", nameOrId))
+}
+
+// Must be called with sim.mu held
+func (sim *simDockerClient) addImage(ref string) types.ImageSummary {
+	if image, ok := sim.images[ref]; ok {
+		return image
+	}
+	if !strings.Contains(ref, ":") {
+		ref += ":latest"
+	}
+	image := types.ImageSummary{ID: "sha256:" + newSimID(), RepoTags: []string{ref}, Created: time.Now().Unix(), Size: 512 << 20}
+	sim.images[ref] = image
+	return image
+}
+
+func (sim *simDockerClient) findImage(ref string) (types.ImageSummary, bool) {
+	for _, image := range sim.images {
+		if image.ID == ref {
+			return image, true
+		}
+		for _, tag := range image.RepoTags {
+			if tag == ref || tag == ref+":latest" {
+				return image, true
+			}
+		}
+	}
+	return types.ImageSummary{}, false
+}
+
+// Stand-in for the docker run CLI, only the flags the controller passes are understood
+func (sim *simDockerClient) run(cmdArgs []string) (string, error) {
+	sim.dockerLatency(context.Background())
+	if sim.shouldFail("docker_run") {
+		return "", errors.New("simulated docker run failure")
+	}
+	c := &simContainer{ID: newSimID(), Created: time.Now(), Labels: map[string]string{}}
+	boolFlags := map[string]bool{"-d": true, "--detach": true, "--init": true, "--rm": true, "-t": true, "-i": true}
+	args := cmdArgs[2:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			c.Image = arg
+			break
+		}
+		if boolFlags[arg] {
+			continue
+		}
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "--name":
+			c.Name = value
+		case "-p", "--publish":
+			c.Ports = append(c.Ports, value)
+			if hostPort, containerPort, ok := strings.Cut(value, ":"); ok && containerPort == "7878" {
+				c.DaemonPort = hostPort
+			}
+		case "-e", "--env":
+			c.Env = append(c.Env, value)
+		case "--env-file":
+			data, err := os.ReadFile(value)
+			if err != nil {
+				return "", err
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimLeft(line, " \t"); line != "" && !strings.HasPrefix(line, "#") {
+					c.Env = append(c.Env, line)
+				}
+			}
+		case "--label", "-l":
+			key, labelValue, _ := strings.Cut(value, "=")
+			c.Labels[key] = labelValue
+		case "--hostname", "-h":
+			c.Hostname = value
+		case "--mount":
+			var mount types.MountPoint
+			for _, option := range strings.Split(value, ",") {
+				key, optionValue, _ := strings.Cut(option, "=")
+				switch key {
+				case "type":
+					mount.Type = mountTypes.Type(optionValue)
+				case "source", "src":
+					mount.Source = optionValue
+				case "target", "destination", "dst":
+					mount.Destination = optionValue
+				}
+			}
+			c.Mounts = append(c.Mounts, mount)
+		case "--network", "--net":
+			c.Network.Name = value
+		case "--ip":
+			c.Network.IPv4 = value
+		case "--ip6":
+			c.Network.IPv6 = value
+		case "--dns":
+			c.Network.Dns = append(c.Network.Dns, value)
+		case "--add-host":
+			c.Network.ExtraHosts = append(c.Network.ExtraHosts, value)
+		case "--network-alias":
+			c.Network.Aliases = append(c.Network.Aliases, value)
+		case "--memory", "-m":
+			c.Resources.Memory, _ = parseMemory(value)
+		}
+	}
+	if c.Image == "" {
+		return "", errors.New("simulated docker run: no image given")
+	}
+	if labelPort := c.Labels[daemonPortLabel]; labelPort != "" {
+		c.DaemonPort = ""
+		for _, mapping := range c.Ports {
+			if hostPort, containerPort, ok := strings.Cut(mapping, ":"); ok && containerPort == labelPort {
+				c.DaemonPort = hostPort
+			}
+		}
+	}
+
+	sim.mu.Lock()
+	if sim.find(c.Name) != nil {
+		sim.mu.Unlock()
+		return "", errdefs.Conflict(fmt.Errorf("Conflict. The container name \"/%s\" is already in use", c.Name))
+	}
+	if c.Name == "" {
+		c.Name = "sim_" + c.ID[:12]
+	}
+	if c.Hostname == "" {
+		c.Hostname = c.ID[:12]
+	}
+	sim.addImage(c.Image)
+	sim.containers[c.Name] = c
+	sim.mu.Unlock()
+
+	if err := sim.start(c); err != nil {
+		return "", err
+	}
+	return c.ID, nil
+}
+
+func (sim *simDockerClient) start(c *simContainer) error {
+	sim.mu.Lock()
+	if c.Status == "running" {
+		sim.mu.Unlock()
+		return nil
+	}
+	c.Status = "running"
+	c.StartedAt = time.Now()
+	sim.mu.Unlock()
+	sim.emit(c, "start")
+	if c.DaemonPort == "" {
+		return nil
+	}
+	daemon, err := startSimDaemon(sim, c)
+	if err != nil {
+		sim.mu.Lock()
+		c.Status = "exited"
+		sim.mu.Unlock()
+		return err
+	}
+	sim.mu.Lock()
+	c.daemon = daemon
+	sim.mu.Unlock()
+	return nil
+}
+
+func (sim *simDockerClient) stop(c *simContainer) {
+	sim.mu.Lock()
+	daemon := c.daemon
+	c.daemon = nil
+	stopped := c.Status == "running" || c.Status == "paused"
+	if stopped {
+		c.Status = "exited"
+		c.FinishedAt = time.Now()
+	}
+	sim.mu.Unlock()
+	if daemon != nil {
+		daemon.stop()
+	}
+	if stopped {
+		sim.emit(c, "die")
+	}
+}
+
+// Send a container event to the Events streams whose filters match it
+func (sim *simDockerClient) emit(c *simContainer, action string) {
+	sim.mu.Lock()
+	attributes := map[string]string{"name": c.Name, "image": c.Image}
+	for key, value := range c.Labels {
+		attributes[key] = value
+	}
+	sim.mu.Unlock()
+	message := events.Message{Type: events.ContainerEventType, Action: action, Actor: events.Actor{ID: c.ID, Attributes: attributes}, Time: time.Now().Unix(), TimeNano: time.Now().UnixNano()}
+	sim.watchersMu.Lock()
+	defer sim.watchersMu.Unlock()
+	for ch, args := range sim.watchers {
+		if !args.ExactMatch("type", message.Type) || !args.ExactMatch("event", action) {
+			continue
+		}
+		matches := true
+		for _, label := range args.Get("label") {
+			key, value, hasValue := strings.Cut(label, "=")
+			if v, ok := attributes[key]; !ok || (hasValue && v != value) {
+				matches = false
+			}
+		}
+		if !matches {
+			continue
+		}
+		select {
+		case ch <- message:
+		default:
+			logger.Warn("Simulated events stream full, event dropped", zap.String("containerName", c.Name), zap.String("action", action))
+		}
+	}
+}
+
+// Live events only, Since and Until are ignored
+func (sim *simDockerClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	messages := make(chan events.Message, 64)
+	errs := make(chan error, 1)
+	sim.watchersMu.Lock()
+	sim.watchers[messages] = options.Filters
+	sim.watchersMu.Unlock()
+	go func() {
+		<-ctx.Done()
+		sim.watchersMu.Lock()
+		delete(sim.watchers, messages)
+		sim.watchersMu.Unlock()
+		errs <- ctx.Err()
+	}()
+	return messages, errs
+}
+
+func (sim *simDockerClient) ContainerInspect(ctx context.Context, nameOrId string) (types.ContainerJSON, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	c := sim.find(nameOrId)
+	if c == nil {
+		return types.ContainerJSON{}, sim.notFound(nameOrId)
+	}
+	ports := nat.PortMap{}
+	for _, mapping := range c.Ports {
+		hostPort, containerPort, _ := strings.Cut(mapping, ":")
+		ports[nat.Port(containerPort+"/tcp")] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: hostPort}}
+	}
+	networkName := c.Network.Name
+	if networkName == "" {
+		networkName = "bridge"
+	}
+	ipv4 := c.Network.IPv4
+	if ipv4 == "" {
+		ipv4 = "172.17.0.2"
+	}
+	state := &types.ContainerState{
+		Status:    c.Status,
+		Running:   c.Status == "running" || c.Status == "paused",
+		Paused:    c.Status == "paused",
+		StartedAt: c.StartedAt.Format(time.RFC3339Nano),
+	}
+	if !c.FinishedAt.IsZero() {
+		state.FinishedAt = c.FinishedAt.Format(time.RFC3339Nano)
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         c.ID,
+			Created:    c.Created.Format(time.RFC3339Nano),
+			Name:       "/" + c.Name,
+			Image:      c.Image,
+			State:      state,
+			HostConfig: &container.HostConfig{Resources: c.Resources, RestartPolicy: c.Restart, NetworkMode: container.NetworkMode(networkName), DNS: c.Network.Dns, ExtraHosts: c.Network.ExtraHosts},
+		},
+		Mounts: c.Mounts,
+		Config: &container.Config{Image: c.Image, Env: c.Env, Labels: c.Labels, Hostname: c.Hostname, Tty: true},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{Ports: ports},
+			Networks: map[string]*network.EndpointSettings{
+				networkName: {Aliases: c.Network.Aliases, IPAddress: ipv4, GlobalIPv6Address: c.Network.IPv6},
+			},
+		},
+	}, nil
+}
+
+func (sim *simDockerClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	var list []types.Container
+	for _, c := range sim.containers {
+		if !options.All && c.Status != "running" && c.Status != "paused" {
+			continue
+		}
+		matches := true
+		for _, label := range options.Filters.Get("label") {
+			key, value, hasValue := strings.Cut(label, "=")
+			if v, ok := c.Labels[key]; !ok || (hasValue && v != value) {
+				matches = false
+			}
+		}
+		if !matches {
+			continue
+		}
+		list = append(list, types.Container{ID: c.ID, Names: []string{"/" + c.Name}, Image: c.Image, Created: c.Created.Unix(), Labels: c.Labels, State: c.Status, Status: c.Status})
+	}
+	return list, nil
+}
+
+func (sim *simDockerClient) ContainerStart(ctx context.Context, nameOrId string, options types.ContainerStartOptions) error {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	sim.mu.Unlock()
+	if c == nil {
+		return sim.notFound(nameOrId)
+	}
+	return sim.start(c)
+}
+
+func (sim *simDockerClient) ContainerStop(ctx context.Context, nameOrId string, options container.StopOptions) error {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	sim.mu.Unlock()
+	if c == nil {
+		return sim.notFound(nameOrId)
+	}
+	sim.stop(c)
+	return nil
+}
+
+func (sim *simDockerClient) ContainerRemove(ctx context.Context, nameOrId string, options types.ContainerRemoveOptions) error {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	if c == nil {
+		sim.mu.Unlock()
+		return sim.notFound(nameOrId)
+	}
+	if (c.Status == "running" || c.Status == "paused") && !options.Force {
+		sim.mu.Unlock()
+		return errdefs.Conflict(fmt.Errorf("You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.ID))
+	}
+	delete(sim.containers, c.Name)
+	sim.mu.Unlock()
+	sim.stop(c)
+	sim.emit(c, "destroy")
+	return nil
+}
+
+func (sim *simDockerClient) ContainerPause(ctx context.Context, nameOrId string) error {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	if c == nil {
+		sim.mu.Unlock()
+		return sim.notFound(nameOrId)
+	}
+	if c.Status != "running" {
+		sim.mu.Unlock()
+		return errdefs.Conflict(fmt.Errorf("Container %s is not running", c.ID))
+	}
+	c.Status = "paused"
+	sim.mu.Unlock()
+	sim.emit(c, "pause")
+	return nil
+}
+
+func (sim *simDockerClient) ContainerUnpause(ctx context.Context, nameOrId string) error {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	if c == nil {
+		sim.mu.Unlock()
+		return sim.notFound(nameOrId)
+	}
+	if c.Status != "paused" {
+		sim.mu.Unlock()
+		return errdefs.Conflict(fmt.Errorf("Container %s is not paused", c.ID))
+	}
+	c.Status = "running"
+	sim.mu.Unlock()
+	sim.emit(c, "unpause")
+	return nil
+}
+
+func (sim *simDockerClient) ContainerUpdate(ctx context.Context, nameOrId string, updateConfig container.UpdateConfig) (container.ContainerUpdateOKBody, error) {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	c := sim.find(nameOrId)
+	if c == nil {
+		return container.ContainerUpdateOKBody{}, sim.notFound(nameOrId)
+	}
+	// Like docker, only what the update sets is changed
+	if !reflect.DeepEqual(updateConfig.Resources, container.Resources{}) {
+		c.Resources = updateConfig.Resources
+	}
+	if updateConfig.RestartPolicy.Name != "" {
+		c.Restart = updateConfig.RestartPolicy
+	}
+	return container.ContainerUpdateOKBody{}, nil
+}
+
+func (sim *simDockerClient) ContainerStats(ctx context.Context, nameOrId string, stream bool) (types.ContainerStats, error) {
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	sim.mu.Unlock()
+	if c == nil {
+		return types.ContainerStats{}, sim.notFound(nameOrId)
+	}
+	if !stream {
+		body, _ := json.Marshal(sim.stats(c))
+		return types.ContainerStats{Body: io.NopCloser(bytes.NewReader(body)), OSType: "linux"}, nil
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		encoder := json.NewEncoder(writer)
+		for {
+			if err := encoder.Encode(sim.stats(c)); err != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				writer.Close()
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return types.ContainerStats{Body: reader, OSType: "linux"}, nil
+}
+
+// Plausible stats sample, usage grows while the simulated application runs
+func (sim *simDockerClient) stats(c *simContainer) types.StatsJSON {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	var s types.StatsJSON
+	s.Read = time.Now()
+	s.PreRead = s.Read.Add(-time.Second)
+	uptime := uint64(time.Since(c.StartedAt).Seconds()) + 1
+	s.CPUStats.OnlineCPUs = 4
+	s.CPUStats.SystemUsage = uptime * 4e9
+	s.PreCPUStats.SystemUsage = s.CPUStats.SystemUsage - 4e9
+	s.MemoryStats.Usage = 32 << 20
+	s.MemoryStats.Limit = 8 << 30
+	if c.Resources.Memory > 0 {
+		s.MemoryStats.Limit = uint64(c.Resources.Memory)
+	}
+	if c.daemon != nil && c.daemon.getStatus() == '1' {
+		s.CPUStats.CPUUsage.TotalUsage = uptime * 5e8
+		s.PreCPUStats.CPUUsage.TotalUsage = s.CPUStats.CPUUsage.TotalUsage - uint64(3e8+mrand.Int63n(4e8))
+		s.MemoryStats.Usage += uint64(sim.config.ImageSize)
+	}
+	s.Networks = map[string]types.NetworkStats{"eth0": {RxBytes: uptime * 2048, TxBytes: uptime * 1024}}
+	s.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{{Op: "read", Value: uptime * 4096}, {Op: "write", Value: uptime * 8192}}
+	s.PidsStats.Current = 3
+	return s
+}
+
+func (sim *simDockerClient) ContainerLogs(ctx context.Context, nameOrId string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	sim.mu.Lock()
+	c := sim.find(nameOrId)
+	var existing []byte
+	if c != nil {
+		existing = append(existing, c.logs.Bytes()...)
+	}
+	sim.mu.Unlock()
+	if c == nil {
+		return nil, sim.notFound(nameOrId)
+	}
+	if tail, err := strconv.Atoi(options.Tail); err == nil {
+		lines := strings.SplitAfter(string(existing), "\n")
+		if len(lines) > tail+1 {
+			existing = []byte(strings.Join(lines[len(lines)-tail-1:], ""))
+		}
+	}
+	if !options.Follow {
+		return io.NopCloser(bytes.NewReader(existing)), nil
+	}
+	// Poll the log buffer for new lines until the client goes away or the container stops
+	reader, writer := io.Pipe()
+	go func() {
+		writer.Write(existing)
+		sim.mu.Lock()
+		offset := c.logs.Len()
+		sim.mu.Unlock()
+		for {
+			select {
+			case <-ctx.Done():
+				writer.Close()
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+			sim.mu.Lock()
+			newLogs := append([]byte(nil), c.logs.Bytes()[offset:]...)
+			offset = c.logs.Len()
+			running := c.Status == "running" || c.Status == "paused"
+			sim.mu.Unlock()
+			if _, err := writer.Write(newLogs); err != nil || !running {
+				writer.Close()
+				return
+			}
+		}
+	}()
+	return reader, nil
+}
+
+func (sim *simDockerClient) log(c *simContainer, format string, args ...interface{}) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	fmt.Fprintf(&c.logs, "%s ff_daemon: %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+func (sim *simDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	sim.dockerLatency(ctx)
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	c := &simContainer{ID: newSimID(), Name: containerName, Image: config.Image, Created: time.Now(), Status: "created", Labels: config.Labels}
+	if c.Name == "" {
+		c.Name = "sim_" + c.ID[:12]
+	}
+	if sim.find(c.Name) != nil {
+		return container.CreateResponse{}, errdefs.Conflict(fmt.Errorf("Conflict. The container name \"/%s\" is already in use", c.Name))
+	}
+	sim.containers[c.Name] = c
+	return container.CreateResponse{ID: c.ID}, nil
+}
+
+// Every simulated image ships ff_daemon in /usr/local/bin
+func (sim *simDockerClient) ContainerStatPath(ctx context.Context, nameOrId string, path string) (types.ContainerPathStat, error) {
+	if path != "/usr/local/bin/ff_daemon" {
+		return types.ContainerPathStat{}, errdefs.NotFound(fmt.Errorf("Could not find the file %s in container %s", path, nameOrId))
+	}
+	return types.ContainerPathStat{Name: "ff_daemon", Size: 8 << 20, Mode: 0755, Mtime: time.Now()}, nil
+}
+
+func (sim *simDockerClient) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	if err := simSleep(ctx, sim.getConfig().DockerLatencyMs); err != nil {
+		return nil, err
+	}
+	if sim.shouldFail("pull") {
+		return nil, errdefs.System(errors.New("simulated image pull failure"))
+	}
+	sim.mu.Lock()
+	image := sim.addImage(ref)
+	sim.mu.Unlock()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.Encode(PullMessage{Status: "Pulling from " + ref})
+	encoder.Encode(PullMessage{Status: "Download complete", ID: image.ID[7:19]})
+	encoder.Encode(PullMessage{Status: "Status: Downloaded newer image for " + ref})
+	return io.NopCloser(&buf), nil
+}
+
+func (sim *simDockerClient) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	images := make([]types.ImageSummary, 0, len(sim.images))
+	for _, image := range sim.images {
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func (sim *simDockerClient) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	image, ok := sim.findImage(ref)
+	if !ok {
+		return types.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("No such image: %s", ref))
+	}
+	return types.ImageInspect{ID: image.ID, RepoTags: image.RepoTags, Size: image.Size, Config: &container.Config{Env: []string{"PATH=/usr/local/bin:/usr/bin:/bin"}}}, nil, nil
+}
+
+func (sim *simDockerClient) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{APIVersion: "simulated", OSType: "linux"}, nil
+}
+
+func (sim *simDockerClient) Close() error {
+	return nil
+}
+
+func getSimulationHandler(c *gin.Context) {
+	simDocker.mu.Lock()
+	defer simDocker.mu.Unlock()
+	var containers []gin.H
+	for _, sc := range simDocker.containers {
+		containers = append(containers, gin.H{"id": sc.ID, "name": sc.Name, "image": sc.Image, "status": sc.Status, "daemon_port": sc.DaemonPort})
+	}
+	checkpoints := make([]SimCheckpoint, 0, len(simDocker.checkpoints))
+	for _, checkpoint := range simDocker.checkpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"config": simDocker.config, "containers": containers, "checkpoints": checkpoints})
+}
+
+func updateSimulationHandler(c *gin.Context) {
+	config := simDocker.getConfig()
+	if err := c.BindJSON(&config); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if config.FailNext == nil {
+		config.FailNext = map[string]int{}
+	}
+	simDocker.mu.Lock()
+	simDocker.config = config
+	simDocker.mu.Unlock()
+	logger.Info("Simulation config updated", zap.Any("config", config))
+	c.IndentedJSON(http.StatusOK, config)
+}
+
+// Start a container the way other tooling would, with docker run arguments, outside the controller
+func simulateDockerRunHandler(c *gin.Context) {
+	var body struct {
+		Args []string `json:"args"`
+	}
+	if err := c.BindJSON(&body); err != nil || len(body.Args) == 0 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "args are required"})
+		return
+	}
+	id, err := simDocker.run(append([]string{"docker", "run"}, body.Args...))
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusCreated, gin.H{"id": id})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// Fresh simulated docker and ff_daemon without latencies, torn down when the test ends
+func useSimulation(t *testing.T) *simDockerClient {
+	t.Helper()
+	simulate = true
+	initSimulation()
+	sim := simDocker
+	sim.config.DockerLatencyMs = 0
+	sim.config.RunLatencyMs = 0
+	sim.config.CheckpointLatencyMs = 0
+	t.Cleanup(func() {
+		sim.mu.Lock()
+		var containers []*simContainer
+		for _, c := range sim.containers {
+			containers = append(containers, c)
+		}
+		sim.mu.Unlock()
+		for _, c := range containers {
+			sim.stop(c)
+			if isSubscribed(c.Name) {
+				serviceUnsubscribe(c.Name)
+			}
+		}
+		simulate = false
+	})
+	return sim
+}
+
+func TestSimDockerRun(t *testing.T) {
+	sim := useSimulation(t)
+	envFile := filepath.Join(t.TempDir(), "env")
+	if err := os.WriteFile(envFile, []byte("# comment\nFROM_FILE=1\n\n  INDENTED=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.run([]string{"docker", "run", "-d", "--name", "flags", "-p", "0:80", "--label", daemonPortLabel + "=80",
+		"-e", "A=1", "--env-file", envFile, "--hostname=host", "--mount", "type=bind,source=/src,target=/dst",
+		"--network", "net", "--ip", "10.0.0.2", "--dns", "1.1.1.1", "--add-host", "h:10.0.0.3", "--network-alias", "alias",
+		"--memory", "1g", "--init", "img:1", "--ignored"}); err != nil {
+		t.Fatal(err)
+	}
+	sim.mu.Lock()
+	c := *sim.containers["flags"]
+	sim.mu.Unlock()
+	want := simContainer{Name: "flags", Image: "img:1", Status: "running", DaemonPort: "0", Ports: []string{"0:80"},
+		Env: []string{"A=1", "FROM_FILE=1", "INDENTED=2"}, Labels: map[string]string{daemonPortLabel: "80"}, Hostname: "host",
+		Network:   NetworkConfig{Name: "net", IPv4: "10.0.0.2", Dns: []string{"1.1.1.1"}, ExtraHosts: []string{"h:10.0.0.3"}, Aliases: []string{"alias"}},
+		Resources: c.Resources}
+	if c.Resources.Memory != 1<<30 {
+		t.Errorf("memory %d, want %d", c.Resources.Memory, 1<<30)
+	}
+	got := simContainer{Name: c.Name, Image: c.Image, Status: c.Status, DaemonPort: c.DaemonPort, Ports: c.Ports, Env: c.Env,
+		Labels: c.Labels, Hostname: c.Hostname, Network: c.Network, Resources: c.Resources}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("run parsed %+v, want %+v", got, want)
+	}
+	if len(c.Mounts) != 1 || c.Mounts[0].Type != "bind" || c.Mounts[0].Source != "/src" || c.Mounts[0].Destination != "/dst" {
+		t.Errorf("mounts %+v", c.Mounts)
+	}
+	if _, ok := sim.findImage("img:1"); !ok {
+		t.Error("image not added")
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"name in use", []string{"docker", "run", "-d", "--name", "flags", "img"}, true},
+		{"no image", []string{"docker", "run", "-d", "--name", "noimage"}, true},
+		{"generated name", []string{"docker", "run", "-d", "img"}, false},
+	}
+	for _, tt := range tests {
+		if _, err := sim.run(tt.args); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSimShouldFail(t *testing.T) {
+	sim := useSimulation(t)
+	sim.config.FailNext["docker_run"] = 2
+	for i, want := range []bool{true, true, false} {
+		if got := sim.shouldFail("docker_run"); got != want {
+			t.Errorf("call %d: shouldFail() = %v, want %v", i, got, want)
+		}
+	}
+	if sim.shouldFail("pull") {
+		t.Error("pull failed without injection")
+	}
+	if _, err := sim.run([]string{"docker", "run", "-d", "--name", "x", "img"}); err != nil {
+		t.Fatal(err)
+	}
+	sim.config.FailNext["docker_run"] = 1
+	if _, err := sim.run([]string{"docker", "run", "-d", "--name", "y", "img"}); err == nil {
+		t.Error("injected docker run failure not returned")
+	}
+	if config := sim.getConfig(); config.FailNext["docker_run"] != 0 {
+		t.Errorf("fail_next left %d", config.FailNext["docker_run"])
+	}
+}
+
+func TestSimContainerInspect(t *testing.T) {
+	sim := useSimulation(t)
+	if _, err := sim.run([]string{"docker", "run", "-d", "--name", "inspect", "-p", "8080:80", "img"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	info, err := sim.ContainerInspect(ctx, "inspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "/inspect" || !info.State.Running || info.State.Status != "running" {
+		t.Errorf("inspect %s %+v", info.Name, info.State)
+	}
+	if bindings := info.NetworkSettings.Ports["80/tcp"]; len(bindings) != 1 || bindings[0].HostPort != "8080" {
+		t.Errorf("port bindings %v", info.NetworkSettings.Ports)
+	}
+	if endpoint := info.NetworkSettings.Networks["bridge"]; endpoint == nil || endpoint.IPAddress != "172.17.0.2" {
+		t.Errorf("default network %v", info.NetworkSettings.Networks)
+	}
+	if byId, err := sim.ContainerInspect(ctx, info.ID[:12]); err != nil || byId.ID != info.ID {
+		t.Errorf("inspect by short id: %v", err)
+	}
+
+	if err := sim.ContainerStop(ctx, "inspect", container.StopOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ = sim.ContainerInspect(ctx, "inspect"); info.State.Running || info.State.Status != "exited" || info.State.FinishedAt == "" {
+		t.Errorf("stopped state %+v", info.State)
+	}
+	if _, err := sim.ContainerInspect(ctx, "missing"); !errdefs.IsNotFound(err) {
+		t.Errorf("missing container: %v", err)
+	}
+}
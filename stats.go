@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Resource usage of a service's container, computed from the docker stats API
+type ServiceStats struct {
+	ContainerName string    `json:"container_name"`
+	Read          time.Time `json:"read"`
+	CpuPercent    float64   `json:"cpu_percent"`
+	OnlineCpus    uint32    `json:"online_cpus"`
+	MemoryUsage   uint64    `json:"memory_usage"`
+	MemoryLimit   uint64    `json:"memory_limit"`
+	MemoryPercent float64   `json:"memory_percent"`
+	NetRxBytes    uint64    `json:"net_rx_bytes"`
+	NetTxBytes    uint64    `json:"net_tx_bytes"`
+	BlockRead     uint64    `json:"block_read"`
+	BlockWrite    uint64    `json:"block_write"`
+	Pids          uint64    `json:"pids"`
+}
+
+type AggregateStats struct {
+	Services []ServiceStats `json:"services"`
+	Total    ServiceStats   `json:"total"`
+	Errors   []string       `json:"errors,omitempty"`
+}
+
+func newServiceStats(containerName string, s types.StatsJSON) ServiceStats {
+	stats := ServiceStats{
+		ContainerName: containerName,
+		Read:          s.Read,
+		OnlineCpus:    s.CPUStats.OnlineCPUs,
+		MemoryLimit:   s.MemoryStats.Limit,
+		Pids:          s.PidsStats.Current,
+	}
+	if stats.OnlineCpus == 0 {
+		stats.OnlineCpus = uint32(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	// Same formula as docker stats: container cpu delta over system cpu delta, scaled by cpus
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CpuPercent = cpuDelta / systemDelta * float64(stats.OnlineCpus) * 100
+	}
+
+	// Page cache is reclaimable so it is not counted as usage (cgroup v1 / v2 keys)
+	stats.MemoryUsage = s.MemoryStats.Usage
+	if cache, ok := s.MemoryStats.Stats["total_inactive_file"]; ok && cache < stats.MemoryUsage {
+		stats.MemoryUsage -= cache
+	} else if cache, ok := s.MemoryStats.Stats["inactive_file"]; ok && cache < stats.MemoryUsage {
+		stats.MemoryUsage -= cache
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+
+	for _, network := range s.Networks {
+		stats.NetRxBytes += network.RxBytes
+		stats.NetTxBytes += network.TxBytes
+	}
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	return stats
+}
+
+// Get a single stats sample of a service's container
+func getServiceStats(ctx context.Context, containerName string) (ServiceStats, error) {
+	logger.Debug("Getting container stats", zap.String("containerName", containerName))
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
+		return ServiceStats{}, err
+	}
+	defer cli.Close()
+
+	resp, err := cli.ContainerStats(ctx, containerName, false)
+	if err != nil {
+		logger.Error("Error getting container stats", zap.String("containerName", containerName), zap.Error(err))
+		return ServiceStats{}, err
+	}
+	defer resp.Body.Close()
+
+	var s types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		logger.Error("Error decoding container stats", zap.String("containerName", containerName), zap.Error(err))
+		return ServiceStats{}, err
+	}
+	return newServiceStats(containerName, s), nil
+}
+
+// Stream stats samples of a service's container to fn until ctx is done or fn returns an error
+func streamServiceStats(ctx context.Context, containerName string, fn func(ServiceStats) error) error {
+	logger.Debug("Streaming container stats", zap.String("containerName", containerName))
+	cli, err := newDockerClient()
+	if err != nil {
+		logger.Error("Error creating docker client", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	defer cli.Close()
+
+	resp, err := cli.ContainerStats(ctx, containerName, true)
+	if err != nil {
+		logger.Error("Error getting container stats", zap.String("containerName", containerName), zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var s types.StatsJSON
+		if err := decoder.Decode(&s); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := fn(newServiceStats(containerName, s)); err != nil {
+			return err
+		}
+	}
+}
+
+// Get stats of every subscribed service concurrently and sum them up
+func getAggregateStats(ctx context.Context) AggregateStats {
+	var wg sync.WaitGroup
+	var statsMu sync.Mutex
+	aggregate := AggregateStats{Services: []ServiceStats{}}
+	for _, service := range listServices() {
+		name := service.ContainerName
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			stats, err := getServiceStats(ctx, name)
+			statsMu.Lock()
+			defer statsMu.Unlock()
+			if err != nil {
+				aggregate.Errors = append(aggregate.Errors, name+": "+err.Error())
+				return
+			}
+			aggregate.Services = append(aggregate.Services, stats)
+		}(name)
+	}
+	wg.Wait()
+
+	total := &aggregate.Total
+	for _, stats := range aggregate.Services {
+		total.CpuPercent += stats.CpuPercent
+		total.MemoryUsage += stats.MemoryUsage
+		total.NetRxBytes += stats.NetRxBytes
+		total.NetTxBytes += stats.NetTxBytes
+		total.BlockRead += stats.BlockRead
+		total.BlockWrite += stats.BlockWrite
+		total.Pids += stats.Pids
+		if stats.Read.After(total.Read) {
+			total.Read = stats.Read
+		}
+	}
+	return aggregate
+}
+
+func getServiceStatsHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	if !isSubscribed(containerName) {
+		msg := "no service name " + containerName + " found!"
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if c.Query("stream") != "true" {
+		stats, err := getServiceStats(c.Request.Context(), containerName)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Client cannot get container stats!"})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, stats)
+		return
+	}
+
+	// Stream one json document per line until the client goes away
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	err := streamServiceStats(c.Request.Context(), containerName, func(stats ServiceStats) error {
+		if err := encoder.Encode(stats); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		logger.Error("Error streaming container stats", zap.String("containerName", containerName), zap.Error(err))
+	}
+}
+
+func getAllServicesStatsHandler(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, getAggregateStats(c.Request.Context()))
+}
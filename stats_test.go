@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestNewServiceStats(t *testing.T) {
+	tests := []struct {
+		name string
+		// docker stats API sample
+		sample string
+		want   ServiceStats
+	}{
+		{"cpu over system delta times cpus",
+			`{"cpu_stats":{"cpu_usage":{"total_usage":300},"system_cpu_usage":2000,"online_cpus":4},"precpu_stats":{"cpu_usage":{"total_usage":100},"system_cpu_usage":1000}}`,
+			ServiceStats{CpuPercent: 80, OnlineCpus: 4}},
+		{"cpus from the per cpu usage when not reported",
+			`{"cpu_stats":{"cpu_usage":{"total_usage":200,"percpu_usage":[100,100]},"system_cpu_usage":2000},"precpu_stats":{"cpu_usage":{"total_usage":100},"system_cpu_usage":1000}}`,
+			ServiceStats{CpuPercent: 20, OnlineCpus: 2}},
+		{"first sample has no previous cpu",
+			`{"cpu_stats":{"cpu_usage":{"total_usage":300},"system_cpu_usage":2000,"online_cpus":1}}`,
+			ServiceStats{CpuPercent: 15, OnlineCpus: 1}},
+		{"no system delta",
+			`{"cpu_stats":{"cpu_usage":{"total_usage":300},"system_cpu_usage":1000,"online_cpus":1},"precpu_stats":{"cpu_usage":{"total_usage":100},"system_cpu_usage":1000}}`,
+			ServiceStats{OnlineCpus: 1}},
+		{"cgroup v1 cache is not usage",
+			`{"memory_stats":{"usage":1000,"limit":4000,"stats":{"total_inactive_file":200}}}`,
+			ServiceStats{MemoryUsage: 800, MemoryLimit: 4000, MemoryPercent: 20}},
+		{"cgroup v2 cache is not usage",
+			`{"memory_stats":{"usage":1000,"limit":2000,"stats":{"inactive_file":500}}}`,
+			ServiceStats{MemoryUsage: 500, MemoryLimit: 2000, MemoryPercent: 25}},
+		{"cache above usage is ignored",
+			`{"memory_stats":{"usage":100,"stats":{"inactive_file":500}}}`,
+			ServiceStats{MemoryUsage: 100}},
+		{"networks summed",
+			`{"networks":{"eth0":{"rx_bytes":10,"tx_bytes":20},"eth1":{"rx_bytes":1,"tx_bytes":2}}}`,
+			ServiceStats{NetRxBytes: 11, NetTxBytes: 22}},
+		{"block io by op",
+			`{"blkio_stats":{"io_service_bytes_recursive":[{"op":"Read","value":10},{"op":"write","value":20},{"op":"Read","value":5},{"op":"Total","value":35}]},"pids_stats":{"current":7}}`,
+			ServiceStats{BlockRead: 15, BlockWrite: 20, Pids: 7}},
+	}
+	for _, tt := range tests {
+		var sample types.StatsJSON
+		if err := json.Unmarshal([]byte(tt.sample), &sample); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := newServiceStats("svc", sample)
+		tt.want.ContainerName = "svc"
+		if math.Abs(got.CpuPercent-tt.want.CpuPercent) < 1e-9 {
+			got.CpuPercent = tt.want.CpuPercent
+		}
+		if got != tt.want {
+			t.Errorf("%s: newServiceStats() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Default run and checkpoint parameters, for the whole worker and per service. A request body is
+// merged over the service's defaults, themselves merged over the worker's: objects are merged key
+// by key, any other value replaces the default and a null removes it.
+type ParamTemplates struct {
+	Run        map[string]interface{} `json:"run,omitempty"`
+	Checkpoint map[string]interface{} `json:"checkpoint,omitempty"`
+}
+
+type savedTemplates struct {
+	Worker   ParamTemplates            `json:"worker"`
+	Services map[string]ParamTemplates `json:"services"`
+}
+
+var templatesPath = envString("TEMPLATES_FILE", "templates.json")
+var workerTemplates ParamTemplates
+var serviceTemplates = make(map[string]ParamTemplates)
+var templatesMu sync.Mutex
+
+// Variables replaced in the string values of the merged parameters, {{secret:...}} is left to the secret store
+var templateVarPattern = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+var templateVars = []string{"service", "timestamp", "worker"}
+
+// {{timestamp}} is UTC and safe in image URLs and paths
+const templateTimestampFormat = "20060102T150405Z"
+
+func loadTemplates() {
+	data, err := os.ReadFile(templatesPath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Error reading templates", zap.Error(err))
+		return
+	}
+	var saved savedTemplates
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.Error("Error decoding templates", zap.Error(err))
+		return
+	}
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	workerTemplates = saved.Worker
+	if saved.Services != nil {
+		serviceTemplates = saved.Services
+	}
+}
+
+// Callers hold templatesMu
+func saveTemplates() error {
+	return writeJSONFile(templatesPath, savedTemplates{Worker: workerTemplates, Services: serviceTemplates})
+}
+
+func (t ParamTemplates) isEmpty() bool {
+	return len(t.Run) == 0 && len(t.Checkpoint) == 0
+}
+
+func (t ParamTemplates) validate() error {
+	for _, template := range []map[string]interface{}{t.Run, t.Checkpoint} {
+		if err := checkTemplateVars(template); err != nil {
+			return err
+		}
+	}
+	// Round trip through the bodies so a default of the wrong type is refused now rather than by ff_daemon
+	data, _ := json.Marshal(t.Run)
+	if err := json.Unmarshal(data, &RunBody{}); err != nil {
+		return errors.New("run: " + err.Error())
+	}
+	data, _ = json.Marshal(t.Checkpoint)
+	if err := json.Unmarshal(data, &CheckpointBody{}); err != nil {
+		return errors.New("checkpoint: " + err.Error())
+	}
+	return nil
+}
+
+func checkTemplateVars(value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := checkTemplateVars(item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := checkTemplateVars(item); err != nil {
+				return err
+			}
+		}
+	case string:
+		for _, match := range templateVarPattern.FindAllStringSubmatch(v, -1) {
+			if !contains(templateVars, match[1]) && !strings.HasPrefix(match[1], "secret:") {
+				return errors.New("unknown variable {{" + match[1] + "}}, use {{service}}, {{timestamp}} or {{worker}}")
+			}
+		}
+	}
+	return nil
+}
+
+func (t ParamTemplates) forOperation(operation string) map[string]interface{} {
+	if operation == "checkpoint" {
+		return t.Checkpoint
+	}
+	return t.Run
+}
+
+// Copy of base with over merged into it
+func mergeParams(base map[string]interface{}, over map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		baseObject, baseIsObject := merged[k].(map[string]interface{})
+		overObject, overIsObject := v.(map[string]interface{})
+		if baseIsObject && overIsObject {
+			merged[k] = mergeParams(baseObject, overObject)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func substituteTemplateVars(value interface{}, vars map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = substituteTemplateVars(item, vars)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = substituteTemplateVars(item, vars)
+		}
+		return v
+	case string:
+		return templateVarPattern.ReplaceAllStringFunc(v, func(ref string) string {
+			if value, ok := vars[ref[2:len(ref)-2]]; ok {
+				return value
+			}
+			return ref
+		})
+	default:
+		return v
+	}
+}
+
+// Merge a run/checkpoint body over the service and worker defaults and substitute the variables,
+// the result is what ff_daemon is sent
+func applyParamTemplates(containerName string, operation string, requestBody []byte) ([]byte, error) {
+	params := map[string]interface{}{}
+	if len(strings.TrimSpace(string(requestBody))) > 0 {
+		if err := json.Unmarshal(requestBody, &params); err != nil {
+			return nil, errors.New("the body must be a json object")
+		}
+	}
+	templatesMu.Lock()
+	defaults := mergeParams(workerTemplates.forOperation(operation), serviceTemplates[containerName].forOperation(operation))
+	merged := mergeParams(defaults, params)
+	// Copied so the substitution never changes the stored templates
+	data, err := json.Marshal(merged)
+	templatesMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	vars := map[string]string{
+		"service":   containerName,
+		"timestamp": time.Now().UTC().Format(templateTimestampFormat),
+		"worker":    workerId,
+	}
+	return json.Marshal(substituteTemplateVars(copied, vars))
+}
+
+func getWorkerTemplatesHandler(c *gin.Context) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	c.IndentedJSON(http.StatusOK, workerTemplates)
+}
+
+func putWorkerTemplatesHandler(c *gin.Context) {
+	var templates ParamTemplates
+	if err := c.BindJSON(&templates); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if err := templates.validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid templates: " + err.Error()})
+		return
+	}
+	templatesMu.Lock()
+	previous := workerTemplates
+	workerTemplates = templates
+	err := saveTemplates()
+	if err != nil {
+		workerTemplates = previous
+	}
+	templatesMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving templates", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the templates"})
+		return
+	}
+	logger.Info("Worker templates updated")
+	c.IndentedJSON(http.StatusOK, templates)
+}
+
+func deleteWorkerTemplatesHandler(c *gin.Context) {
+	templatesMu.Lock()
+	previous := workerTemplates
+	workerTemplates = ParamTemplates{}
+	err := saveTemplates()
+	if err != nil {
+		workerTemplates = previous
+	}
+	templatesMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving templates", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the templates"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Worker templates deleted"})
+}
+
+// Service templates are kept by name, so they can be set before the service is started and outlive it
+func getServiceTemplatesHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	c.IndentedJSON(http.StatusOK, serviceTemplates[containerName])
+}
+
+func putServiceTemplatesHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	var templates ParamTemplates
+	if err := c.BindJSON(&templates); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+	if err := templates.validate(); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid templates: " + err.Error()})
+		return
+	}
+	templatesMu.Lock()
+	previous, existed := serviceTemplates[containerName]
+	if templates.isEmpty() {
+		delete(serviceTemplates, containerName)
+	} else {
+		serviceTemplates[containerName] = templates
+	}
+	err := saveTemplates()
+	if err != nil {
+		if existed {
+			serviceTemplates[containerName] = previous
+		} else {
+			delete(serviceTemplates, containerName)
+		}
+	}
+	templatesMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving templates", zap.String("containerName", containerName), zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the templates"})
+		return
+	}
+	logger.Info("Service templates updated", zap.String("containerName", containerName))
+	c.IndentedJSON(http.StatusOK, templates)
+}
+
+func deleteServiceTemplatesHandler(c *gin.Context) {
+	containerName := c.Param("name")
+	templatesMu.Lock()
+	previous, existed := serviceTemplates[containerName]
+	if !existed {
+		templatesMu.Unlock()
+		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no templates for service " + containerName + " found!"})
+		return
+	}
+	delete(serviceTemplates, containerName)
+	err := saveTemplates()
+	if err != nil {
+		serviceTemplates[containerName] = previous
+	}
+	templatesMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving templates", zap.String("containerName", containerName), zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the templates"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Templates of service " + containerName + " deleted"})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func decodeParams(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	if s == "" {
+		return nil
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &params); err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestMergeParams(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		over string
+		want string
+	}{
+		{"both empty", "", "", `{}`},
+		{"defaults only", `{"cpu_budget":"low"}`, "", `{"cpu_budget":"low"}`},
+		{"override", `{"cpu_budget":"low","sync":true}`, `{"cpu_budget":"high"}`, `{"cpu_budget":"high","sync":true}`},
+		{"null removes", `{"cpu_budget":"low","sync":true}`, `{"sync":null}`, `{"cpu_budget":"low"}`},
+		{"null without default", `{}`, `{"sync":null}`, `{}`},
+		{"objects merged by key", `{"app_args":{"a":1,"b":2}}`, `{"app_args":{"b":3,"c":4}}`, `{"app_args":{"a":1,"b":3,"c":4}}`},
+		{"nested null removes", `{"app_args":{"a":1,"b":2}}`, `{"app_args":{"a":null}}`, `{"app_args":{"b":2}}`},
+		{"lists replaced", `{"tags":["a","b"]}`, `{"tags":["c"]}`, `{"tags":["c"]}`},
+		{"object replaces a scalar", `{"app_args":"x"}`, `{"app_args":{"a":1}}`, `{"app_args":{"a":1}}`},
+	}
+	for _, tt := range tests {
+		base := decodeParams(t, tt.base)
+		baseCopy := decodeParams(t, tt.base)
+		got := mergeParams(base, decodeParams(t, tt.over))
+		if want := decodeParams(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: mergeParams() = %v, want %v", tt.name, got, want)
+		}
+		if !reflect.DeepEqual(base, baseCopy) {
+			t.Errorf("%s: mergeParams() changed its base to %v", tt.name, base)
+		}
+	}
+}
+
+func TestSubstituteTemplateVars(t *testing.T) {
+	vars := map[string]string{"service": "svc", "timestamp": "20261019T120000Z", "worker": "w1"}
+	tests := []struct {
+		name   string
+		params string
+		want   string
+	}{
+		{"strings", `{"image_url":"s3://b/{{worker}}/{{service}}-{{timestamp}}"}`, `{"image_url":"s3://b/w1/svc-20261019T120000Z"}`},
+		{"nested and lists", `{"app_args":{"name":"{{service}}"},"tags":["{{worker}}","x"]}`, `{"app_args":{"name":"svc"},"tags":["w1","x"]}`},
+		{"secret references are left", `{"passphrase_file":"{{secret:key}}"}`, `{"passphrase_file":"{{secret:key}}"}`},
+		{"unknown variables are left", `{"image_url":"{{other}}"}`, `{"image_url":"{{other}}"}`},
+		{"other values untouched", `{"sync":true,"cpu":2}`, `{"sync":true,"cpu":2}`},
+	}
+	for _, tt := range tests {
+		got := substituteTemplateVars(decodeParams(t, tt.params), vars)
+		if want := decodeParams(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: substituteTemplateVars() = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+// Empty templates kept in the test's temp dir, with their routes on r
+func useTemplates(t *testing.T, r *gin.Engine) {
+	t.Helper()
+	r.GET("/cm_controller/v1/templates", getWorkerTemplatesHandler)
+	r.PUT("/cm_controller/v1/templates", putWorkerTemplatesHandler)
+	r.DELETE("/cm_controller/v1/templates", deleteWorkerTemplatesHandler)
+	r.GET("/cm_controller/v1/service/:name/templates", getServiceTemplatesHandler)
+	r.PUT("/cm_controller/v1/service/:name/templates", putServiceTemplatesHandler)
+	r.DELETE("/cm_controller/v1/service/:name/templates", deleteServiceTemplatesHandler)
+	previous := templatesPath
+	templatesPath = filepath.Join(t.TempDir(), "templates.json")
+	reset := func() {
+		templatesMu.Lock()
+		workerTemplates = ParamTemplates{}
+		serviceTemplates = make(map[string]ParamTemplates)
+		templatesMu.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		reset()
+		templatesPath = previous
+	})
+}
+
+func TestTemplatesHandlers(t *testing.T) {
+	r := gin.New()
+	useTemplates(t, r)
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{"worker", "PUT", "/templates", `{"run":{"image_url":"s3://b/{{worker}}/{{service}}"},"checkpoint":{"cpu_budget":"low"}}`, http.StatusOK, ""},
+		{"service", "PUT", "/service/tpl-svc/templates", `{"checkpoint":{"image_url":"s3://b/{{service}}-{{timestamp}}","passphrase_file":"{{secret:key}}"}}`, http.StatusOK, ""},
+		{"unknown variable", "PUT", "/service/tpl-svc/templates", `{"run":{"image_url":"{{host}}"}}`, http.StatusBadRequest, "unknown variable {{host}}"},
+		{"wrong type", "PUT", "/templates", `{"checkpoint":{"leave_running":"yes"}}`, http.StatusBadRequest, "checkpoint:"},
+		{"not json", "PUT", "/templates", `run`, http.StatusBadRequest, "Failed to read request body"},
+		{"service not set", "DELETE", "/service/other/templates", "", http.StatusNotFound, "no templates for service other found!"},
+	}
+	for _, tt := range tests {
+		code, response := serve(r, tt.method, tt.path, tt.body)
+		errorMessage, _ := response["error"].(string)
+		if code != tt.wantCode || !strings.Contains(errorMessage, tt.wantErr) {
+			t.Errorf("%s: code %d %v, want %d %q", tt.name, code, response, tt.wantCode, tt.wantErr)
+		}
+	}
+
+	// Refused templates left the saved ones as they were
+	if _, worker := serve(r, "GET", "/templates", ""); !reflect.DeepEqual(worker["checkpoint"], map[string]interface{}{"cpu_budget": "low"}) {
+		t.Errorf("worker templates %v", worker)
+	}
+	var saved savedTemplates
+	data, _ := os.ReadFile(templatesPath)
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Services["tpl-svc"].Checkpoint["passphrase_file"] != "{{secret:key}}" || saved.Worker.Run["image_url"] != "s3://b/{{worker}}/{{service}}" {
+		t.Errorf("templates file %s: %v", data, err)
+	}
+
+	// Putting empty templates drops the service's entry
+	if code, _ := serve(r, "PUT", "/service/tpl-svc/templates", `{}`); code != http.StatusOK {
+		t.Errorf("empty service templates: code %d", code)
+	}
+	if code, _ := serve(r, "DELETE", "/service/tpl-svc/templates", ""); code != http.StatusNotFound {
+		t.Errorf("delete after empty put: code %d", code)
+	}
+	if code, _ := serve(r, "DELETE", "/templates", ""); code != http.StatusOK {
+		t.Errorf("delete worker templates: code %d", code)
+	}
+	if _, worker := serve(r, "GET", "/templates", ""); len(worker) != 0 {
+		t.Errorf("worker templates after delete %v", worker)
+	}
+}
+
+func TestTemplatedOperations(t *testing.T) {
+	r, _ := simulatedRouter(t)
+	useTemplates(t, r)
+	startSimulated(t, r, "tpl-op")
+	if code, response := serve(r, "PUT", "/service/tpl-op/templates", `{"checkpoint":{"image_url":"file:/tmp/{{service}}","cpu_budget":"low"}}`); code != http.StatusOK {
+		t.Fatalf("templates: %d %v", code, response)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantCode   int
+		wantStatus string
+		wantParams map[string]interface{}
+	}{
+		{"run", "/run/tpl-op", `{}`, http.StatusOK, "running", map[string]interface{}{}},
+		{"unknown secret", "/checkpoint/tpl-op", `{"passphrase_file":"{{secret:missing}}"}`, http.StatusBadRequest, "running", nil},
+		{"body is not an object", "/checkpoint/tpl-op", `[1]`, http.StatusBadRequest, "running", nil},
+		{"checkpoint over the templates", "/checkpoint/tpl-op", `{"leave_running":true,"cpu_budget":null}`, http.StatusOK, "running",
+			map[string]interface{}{"image_url": "file:/tmp/tpl-op", "leave_running": true}},
+		{"checkpoint", "/checkpoint/tpl-op", `{}`, http.StatusOK, "checkpointed",
+			map[string]interface{}{"image_url": "file:/tmp/tpl-op", "cpu_budget": "low"}},
+	}
+	for _, tt := range tests {
+		code, response := serve(r, "POST", tt.path, tt.body)
+		if code != tt.wantCode {
+			t.Errorf("%s: code %d %v, want %d", tt.name, code, response, tt.wantCode)
+			continue
+		}
+		if tt.wantParams != nil {
+			if params, _ := response["params"].(map[string]interface{}); !reflect.DeepEqual(params, tt.wantParams) && (len(params) != 0 || len(tt.wantParams) != 0) {
+				t.Errorf("%s: params %v, want %v", tt.name, params, tt.wantParams)
+			}
+		}
+		if _, service := serve(r, "GET", "/service/tpl-op", ""); service["status"] != tt.wantStatus {
+			t.Errorf("%s: status %v, want %s", tt.name, service["status"], tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Events a webhook can subscribe to, all of them when it lists none
+var webhookEvents = []string{"checkpoint.completed", "checkpoint.failed", "run.completed", "run.failed", "container.exited", "service.removed", "group.checkpoint.completed", "group.checkpoint.failed"}
+
+// A URL receiving events, of one service or of all services when Service is empty
+type Webhook struct {
+	Id      string    `json:"id"`
+	Url     string    `json:"url"`
+	Service string    `json:"service,omitempty"`
+	Events  []string  `json:"events,omitempty"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Payload POSTed to the webhooks, signed with HMAC-SHA256 of the webhook's secret in X-CM-Signature
+type WebhookEvent struct {
+	Id       string                 `json:"id"`
+	Event    string                 `json:"event"`
+	Time     time.Time              `json:"time"`
+	WorkerId string                 `json:"worker_id"`
+	Service  string                 `json:"service"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// A delivery given up after all retries, as written to the dead-letter log
+type DeadLetter struct {
+	Time      time.Time    `json:"time"`
+	WebhookId string       `json:"webhook_id"`
+	Url       string       `json:"url"`
+	Event     WebhookEvent `json:"event"`
+	Attempts  int          `json:"attempts"`
+	Error     string       `json:"error"`
+}
+
+var webhooksPath = envString("WEBHOOKS_FILE", "webhooks.json")
+var deadLetterPath = envString("WEBHOOKS_DEADLETTER", "webhooks_deadletter.log")
+var webhookTimeout = envDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+
+var webhookRetry = RetryPolicy{
+	Retries:    envInt("WEBHOOK_RETRY_ATTEMPTS", 5),
+	Backoff:    envDuration("WEBHOOK_RETRY_BACKOFF", time.Second),
+	MaxBackoff: envDuration("WEBHOOK_RETRY_MAX_BACKOFF", time.Minute),
+}
+
+var webhooks = make(map[string]Webhook)
+var webhooksMu sync.Mutex
+var deadLetterMu sync.Mutex
+
+// A non 2xx answer of a webhook receiver
+type webhookStatusError struct {
+	statusCode int
+}
+
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook answered with status %d", e.statusCode)
+}
+
+func randomId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func loadWebhooks() {
+	data, err := os.ReadFile(webhooksPath)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logger.Error("Error reading webhooks file", zap.Error(err))
+		return
+	}
+	var saved []Webhook
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.Error("Error parsing webhooks file", zap.Error(err))
+		return
+	}
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	for _, hook := range saved {
+		webhooks[hook.Id] = hook
+	}
+	logger.Info("Webhooks loaded", zap.Int("count", len(saved)))
+}
+
+// Write all webhooks to the webhooks file, must be called with webhooksMu held
+func saveWebhooks() error {
+	saved := make([]Webhook, 0, len(webhooks))
+	for _, hook := range webhooks {
+		saved = append(saved, hook)
+	}
+	return writeJSONFile(webhooksPath, saved)
+}
+
+func (h Webhook) wants(event string, service string) bool {
+	if h.Service != "" && h.Service != service {
+		return false
+	}
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Send an event to every webhook registered for it, in the background
+func notify(event string, service string, data map[string]interface{}) {
+	payload := WebhookEvent{Id: randomId(), Event: event, Time: time.Now(), WorkerId: workerId, Service: service, Data: data}
+	publishEvent(payload)
+	webhooksMu.Lock()
+	var targets []Webhook
+	for _, hook := range webhooks {
+		if hook.wants(event, service) {
+			targets = append(targets, hook)
+		}
+	}
+	webhooksMu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Error encoding webhook event", zap.String("containerName", service), zap.Error(err))
+		return
+	}
+	for _, hook := range targets {
+		go deliverWebhook(hook, payload, body)
+	}
+}
+
+// In-process event watchers (gRPC WatchEvents), sent every event without retries
+var eventWatchers = make(map[chan WebhookEvent]bool)
+var eventWatchersMu sync.Mutex
+
+func watchEvents() (chan WebhookEvent, func()) {
+	events := make(chan WebhookEvent, 64)
+	eventWatchersMu.Lock()
+	eventWatchers[events] = true
+	eventWatchersMu.Unlock()
+	return events, func() {
+		eventWatchersMu.Lock()
+		delete(eventWatchers, events)
+		eventWatchersMu.Unlock()
+	}
+}
+
+// A watcher that falls behind misses events rather than holding up the operation
+func publishEvent(payload WebhookEvent) {
+	eventWatchersMu.Lock()
+	defer eventWatchersMu.Unlock()
+	for events := range eventWatchers {
+		select {
+		case events <- payload:
+		default:
+			logger.Warn("Event watcher is behind, dropping event", zap.String("containerName", payload.Service), zap.String("event", payload.Event))
+		}
+	}
+}
+
+// run.completed/run.failed or checkpoint.completed/checkpoint.failed for a finished operation
+func notifyOperation(containerName string, op ServiceOperation, message string, imageUrl string) {
+	event := op.Operation + ".completed"
+	if op.Result != "success" {
+		event = op.Operation + ".failed"
+	}
+	notify(event, containerName, map[string]interface{}{
+		"result":    op.Result,
+		"message":   message,
+		"image_url": imageUrl,
+		"retries":   op.Retries,
+		"started":   op.Started,
+		"finished":  op.Finished,
+	})
+}
+
+func deliverWebhook(hook Webhook, payload WebhookEvent, body []byte) {
+	retries, err := webhookRetry.do(context.Background(), "webhook", payload.Service, func() (bool, error) {
+		err := postWebhook(hook, payload, body)
+		var statusErr webhookStatusError
+		// The receiver rejected the event, sending it again will not help
+		if errors.As(err, &statusErr) && statusErr.statusCode >= 400 && statusErr.statusCode < 500 && statusErr.statusCode != http.StatusTooManyRequests {
+			return false, err
+		}
+		return true, err
+	})
+	if err == nil {
+		logger.Debug("Webhook delivered", zap.String("containerName", payload.Service), zap.String("webhook", hook.Id), zap.String("event", payload.Event))
+		return
+	}
+	logger.Error("Webhook delivery failed", zap.String("containerName", payload.Service), zap.String("webhook", hook.Id), zap.String("event", payload.Event), zap.Error(err))
+	writeDeadLetter(DeadLetter{Time: time.Now(), WebhookId: hook.Id, Url: hook.Url, Event: payload, Attempts: retries + 1, Error: err.Error()})
+}
+
+func postWebhook(hook Webhook, payload WebhookEvent, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CM-Event", payload.Event)
+	req.Header.Set("X-CM-Delivery", payload.Id)
+	req.Header.Set("X-CM-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return webhookStatusError{resp.StatusCode}
+	}
+	return nil
+}
+
+func writeDeadLetter(entry DeadLetter) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("Error encoding dead letter", zap.Error(err))
+		return
+	}
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	file, err := os.OpenFile(deadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.Error("Error opening dead-letter log", zap.Error(err))
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		logger.Error("Error writing dead-letter log", zap.Error(err))
+	}
+}
+
+func createWebhookHandler(c *gin.Context) {
+	var hook Webhook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook: " + err.Error()})
+		return
+	}
+	if u, err := url.Parse(hook.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook url"})
+		return
+	}
+	for _, event := range hook.Events {
+		known := false
+		for _, e := range webhookEvents {
+			known = known || e == event
+		}
+		if !known {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Unknown event " + event})
+			return
+		}
+	}
+	hook.Id = randomId()
+	hook.Created = time.Now()
+	if hook.Secret == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		hook.Secret = hex.EncodeToString(b)
+	}
+	webhooksMu.Lock()
+	webhooks[hook.Id] = hook
+	err := saveWebhooks()
+	if err != nil {
+		delete(webhooks, hook.Id)
+	}
+	webhooksMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving webhooks", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the webhook"})
+		return
+	}
+	logger.Info("Webhook registered", zap.String("webhook", hook.Id), zap.String("url", hook.Url), zap.String("service", hook.Service))
+	// The only time the secret is returned
+	c.IndentedJSON(http.StatusCreated, hook)
+}
+
+func listWebhooksHandler(c *gin.Context) {
+	service := c.Query("service")
+	webhooksMu.Lock()
+	list := []Webhook{}
+	for _, hook := range webhooks {
+		if service == "" || hook.Service == service {
+			hook.Secret = ""
+			list = append(list, hook)
+		}
+	}
+	webhooksMu.Unlock()
+	c.IndentedJSON(http.StatusOK, list)
+}
+
+func deleteWebhookHandler(c *gin.Context) {
+	id := c.Param("id")
+	webhooksMu.Lock()
+	hook, ok := webhooks[id]
+	if !ok {
+		webhooksMu.Unlock()
+		c.IndentedJSON(http.StatusNotFound, gin.H{"error": "no webhook " + id + " found!"})
+		return
+	}
+	delete(webhooks, id)
+	err := saveWebhooks()
+	if err != nil {
+		webhooks[id] = hook
+	}
+	webhooksMu.Unlock()
+	if err != nil {
+		logger.Error("Error saving webhooks", zap.Error(err))
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the webhook"})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Webhook " + id + " deleted"})
+}
+
+func getDeadLettersHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "Invalid limit value"})
+		return
+	}
+	webhookId := c.Query("webhook")
+	deadLetterMu.Lock()
+	file, err := os.Open(deadLetterPath)
+	if err != nil {
+		deadLetterMu.Unlock()
+		if os.IsNotExist(err) {
+			c.IndentedJSON(http.StatusOK, []DeadLetter{})
+			return
+		}
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "Cannot read dead-letter log"})
+		return
+	}
+	entries := []DeadLetter{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry DeadLetter
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if webhookId != "" && entry.WebhookId != webhookId {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	file.Close()
+	deadLetterMu.Unlock()
+
+	// Keep the most recent entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	c.IndentedJSON(http.StatusOK, entries)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A webhook receiver answering the given statuses in turn, then 200
+type webhookReceiver struct {
+	*httptest.Server
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []*http.Request
+	bodies     [][]byte
+	received   chan string
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses, received: make(chan string, 16)}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		receiver.deliveries = append(receiver.deliveries, r)
+		receiver.bodies = append(receiver.bodies, body)
+		receiver.mu.Unlock()
+		w.WriteHeader(status)
+		receiver.received <- r.Header.Get("X-CM-Event")
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) attempts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.deliveries)
+}
+
+// Fast retries, and webhooks and dead letters kept in the test's temp dir
+func useWebhooks(t *testing.T) {
+	t.Helper()
+	previousRetry, previousPath, previousDeadLetter := webhookRetry, webhooksPath, deadLetterPath
+	dir := t.TempDir()
+	webhookRetry = RetryPolicy{Retries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	webhooksPath = filepath.Join(dir, "webhooks.json")
+	deadLetterPath = filepath.Join(dir, "webhooks_deadletter.log")
+	webhooksMu.Lock()
+	webhooks = make(map[string]Webhook)
+	webhooksMu.Unlock()
+	t.Cleanup(func() {
+		webhookRetry, webhooksPath, deadLetterPath = previousRetry, previousPath, previousDeadLetter
+		webhooksMu.Lock()
+		webhooks = make(map[string]Webhook)
+		webhooksMu.Unlock()
+	})
+}
+
+func readDeadLetters(t *testing.T) []DeadLetter {
+	t.Helper()
+	file, err := os.Open(deadLetterPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []DeadLetter
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		statuses       []int
+		wantAttempts   int
+		wantDeadLetter bool
+	}{
+		{"delivered", nil, 1, false},
+		{"rejected is not retried", []int{http.StatusBadRequest}, 1, true},
+		{"gone is not retried", []int{http.StatusNotFound}, 1, true},
+		{"too many requests is retried", []int{http.StatusTooManyRequests, http.StatusTooManyRequests}, 3, false},
+		{"server error is retried", []int{http.StatusInternalServerError}, 2, false},
+		{"retries exhausted", []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, 3, true},
+	}
+	for _, tt := range tests {
+		useWebhooks(t)
+		receiver := newWebhookReceiver(t, tt.statuses...)
+		hook := Webhook{Id: "hook", Url: receiver.URL, Secret: "s3cret"}
+		payload := WebhookEvent{Id: "delivery", Event: "run.completed", Service: "svc"}
+		body, _ := json.Marshal(payload)
+		deliverWebhook(hook, payload, body)
+
+		if attempts := receiver.attempts(); attempts != tt.wantAttempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts, tt.wantAttempts)
+		}
+		deadLetters := readDeadLetters(t)
+		if (len(deadLetters) == 1) != tt.wantDeadLetter || len(deadLetters) > 1 {
+			t.Errorf("%s: dead letters %+v, want one %v", tt.name, deadLetters, tt.wantDeadLetter)
+		} else if tt.wantDeadLetter {
+			entry := deadLetters[0]
+			if entry.WebhookId != "hook" || entry.Url != receiver.URL || entry.Event.Id != "delivery" || entry.Attempts != tt.wantAttempts || entry.Error == "" {
+				t.Errorf("%s: dead letter %+v", tt.name, entry)
+			}
+		}
+
+		// Every attempt is signed with the webhook's secret
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		wantSignature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		receiver.mu.Lock()
+		for i, r := range receiver.deliveries {
+			if r.Header.Get("X-CM-Signature") != wantSignature || r.Header.Get("X-CM-Event") != "run.completed" || r.Header.Get("X-CM-Delivery") != "delivery" {
+				t.Errorf("%s: attempt %d headers %v", tt.name, i, r.Header)
+			}
+			if string(receiver.bodies[i]) != string(body) {
+				t.Errorf("%s: attempt %d body %s", tt.name, i, receiver.bodies[i])
+			}
+		}
+		receiver.mu.Unlock()
+	}
+}
+
+func TestWebhookWants(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    Webhook
+		event   string
+		service string
+		want    bool
+	}{
+		{"all events of all services", Webhook{}, "run.failed", "svc", true},
+		{"service matches", Webhook{Service: "svc"}, "run.failed", "svc", true},
+		{"other service", Webhook{Service: "svc"}, "run.failed", "other", false},
+		{"listed event", Webhook{Events: []string{"run.failed", "container.exited"}}, "container.exited", "svc", true},
+		{"unlisted event", Webhook{Events: []string{"run.failed"}}, "run.completed", "svc", false},
+		{"service and event", Webhook{Service: "svc", Events: []string{"run.failed"}}, "run.failed", "other", false},
+	}
+	for _, tt := range tests {
+		if got := tt.hook.wants(tt.event, tt.service); got != tt.want {
+			t.Errorf("%s: wants() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNotifyFiltersWebhooks(t *testing.T) {
+	useWebhooks(t)
+	all := newWebhookReceiver(t)
+	svc := newWebhookReceiver(t)
+	failures := newWebhookReceiver(t)
+	webhooksMu.Lock()
+	webhooks["all"] = Webhook{Id: "all", Url: all.URL}
+	webhooks["svc"] = Webhook{Id: "svc", Url: svc.URL, Service: "svc"}
+	webhooks["failures"] = Webhook{Id: "failures", Url: failures.URL, Events: []string{"run.failed"}}
+	webhooksMu.Unlock()
+
+	notify("run.completed", "other", nil)
+	notify("run.failed", "svc", nil)
+
+	tests := []struct {
+		name     string
+		receiver *webhookReceiver
+		want     []string
+	}{
+		{"all", all, []string{"run.completed", "run.failed"}},
+		{"svc", svc, []string{"run.failed"}},
+		{"failures", failures, []string{"run.failed"}},
+	}
+	for _, tt := range tests {
+		got := map[string]int{}
+		for range tt.want {
+			select {
+			case event := <-tt.receiver.received:
+				got[event]++
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: timed out waiting for %v, got %v", tt.name, tt.want, got)
+			}
+		}
+		for _, event := range tt.want {
+			if got[event] != 1 {
+				t.Errorf("%s: events %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+	// Nothing else arrives once the expected deliveries are in
+	time.Sleep(100 * time.Millisecond)
+	for _, tt := range tests {
+		if attempts := tt.receiver.attempts(); attempts != len(tt.want) {
+			t.Errorf("%s: %d deliveries, want %d", tt.name, attempts, len(tt.want))
+		}
+	}
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	useWebhooks(t)
+	r := gin.New()
+	r.POST("/cm_controller/v1/webhooks", createWebhookHandler)
+	r.GET("/cm_controller/v1/webhooks", listWebhooksHandler)
+	r.DELETE("/cm_controller/v1/webhooks/:id", deleteWebhookHandler)
+	r.GET("/cm_controller/v1/webhooks/deadletter", getDeadLettersHandler)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"no url", `{}`, http.StatusBadRequest},
+		{"not http", `{"url":"ftp://host/hook"}`, http.StatusBadRequest},
+		{"unknown event", `{"url":"http://host/hook","events":["run.started"]}`, http.StatusBadRequest},
+		{"one service", `{"url":"http://host/hook","service":"svc","events":["run.failed"],"secret":"given"}`, http.StatusCreated},
+		{"all services", `{"url":"https://host/hook"}`, http.StatusCreated},
+	}
+	created := map[string]string{}
+	for _, tt := range tests {
+		code, response := serve(r, "POST", "/webhooks", tt.body)
+		if code != tt.wantCode {
+			t.Errorf("%s: code %d %v, want %d", tt.name, code, response, tt.wantCode)
+			continue
+		}
+		if code == http.StatusCreated {
+			id, _ := response["id"].(string)
+			secret, _ := response["secret"].(string)
+			if id == "" || secret == "" {
+				t.Errorf("%s: created %v", tt.name, response)
+			}
+			created[tt.name] = id
+		}
+	}
+	if secret := webhooks[created["one service"]].Secret; secret != "given" {
+		t.Errorf("given secret replaced by %q", secret)
+	}
+	if data, err := os.ReadFile(webhooksPath); err != nil || !strings.Contains(string(data), created["all services"]) {
+		t.Errorf("webhooks file %s: %v", data, err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/webhooks?service=svc", nil))
+	var listed []Webhook
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if len(listed) != 1 || listed[0].Id != created["one service"] || listed[0].Secret != "" {
+		t.Errorf("webhooks of svc %+v", listed)
+	}
+
+	if code, _ := serve(r, "DELETE", "/webhooks/"+created["all services"], ""); code != http.StatusOK {
+		t.Errorf("delete: code %d", code)
+	}
+	if code, _ := serve(r, "DELETE", "/webhooks/"+created["all services"], ""); code != http.StatusNotFound {
+		t.Errorf("delete again: code %d", code)
+	}
+
+	writeDeadLetter(DeadLetter{WebhookId: "a", Attempts: 1})
+	writeDeadLetter(DeadLetter{WebhookId: "b", Attempts: 2})
+	writeDeadLetter(DeadLetter{WebhookId: "a", Attempts: 3})
+	deadLetterTests := []struct {
+		query        string
+		wantAttempts []int
+	}{
+		{"", []int{1, 2, 3}},
+		{"?webhook=a", []int{1, 3}},
+		{"?limit=1", []int{3}},
+	}
+	for _, tt := range deadLetterTests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/cm_controller/v1/webhooks/deadletter"+tt.query, nil))
+		var entries []DeadLetter
+		json.Unmarshal(w.Body.Bytes(), &entries)
+		var attempts []int
+		for _, entry := range entries {
+			attempts = append(attempts, entry.Attempts)
+		}
+		if w.Code != http.StatusOK || len(attempts) != len(tt.wantAttempts) {
+			t.Errorf("%q: code %d, attempts %v, want %v", tt.query, w.Code, attempts, tt.wantAttempts)
+			continue
+		}
+		for i := range attempts {
+			if attempts[i] != tt.wantAttempts[i] {
+				t.Errorf("%q: attempts %v, want %v", tt.query, attempts, tt.wantAttempts)
+				break
+			}
+		}
+	}
+}